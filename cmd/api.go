@@ -2,18 +2,26 @@ package cmd
 
 import (
 	"context"
+	"core/internal/activity"
 	"core/internal/api"
 	"core/internal/api/handlers"
+	"core/internal/audit"
 	"core/internal/authentication"
 	"core/internal/authorization"
 	"core/internal/cache"
 	"core/internal/club"
 	"core/internal/database"
+	"core/internal/event"
 	"core/internal/game"
+	"core/internal/mailer"
 	"core/internal/match"
 	"core/internal/member"
+	"core/internal/oidc"
+	"core/internal/password"
+	"core/internal/ratelimit"
 	"core/internal/rating"
 	"core/internal/statistic"
+	"core/internal/storage"
 	"core/internal/subscription"
 	"core/internal/user"
 	"fmt"
@@ -47,33 +55,110 @@ func StartAPIserver(l *slog.Logger) {
 
 	client := redis.NewClient(&redis.Options{Addr: fmt.Sprintf("redis:%d", config.RedisPort)})
 
-	cacheService := cache.NewService(client, config.DenylistExpiry)
+	cacheService := cache.NewService(client, config.AuthNAccessExpiry)
+
+	var limiter ratelimit.Limiter
+	switch config.RateLimiter {
+	case "", "redis":
+		limiter = ratelimit.NewRedisLimiter(client)
+	case "memory":
+		limiter = ratelimit.NewMemoryLimiter(nil)
+	default:
+		l.Error("Unknown rate limiter", "limiter", config.RateLimiter)
+		os.Exit(1)
+	}
+
+	// Emails are only logged unless an SMTP server is configured
+	var mail mailer.Mailer
+	switch config.Mailer {
+	case "smtp":
+		mail = mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+			From:     config.SMTPFrom,
+		})
+	case "", "log":
+		mail = mailer.NewLogMailer(l, config.MailDir)
+	default:
+		l.Error("Unknown mailer", "mailer", config.Mailer)
+		os.Exit(1)
+	}
+
+	var blobs storage.Storage
+	switch config.Storage {
+	case "", "local":
+		blobs, err = storage.NewLocalStorage(config.StorageDir)
+		if err != nil {
+			l.Error("Failed to initialize storage", "error", err)
+			os.Exit(1)
+		}
+	default:
+		l.Error("Unknown storage", "storage", config.Storage)
+		os.Exit(1)
+	}
+
+	passwordConfig, err := config.passwordConfig()
+	if err != nil {
+		l.Error("Invalid password configuration", "error", err)
+		os.Exit(1)
+	}
+	hasher, err := password.NewHasher(passwordConfig)
+	if err != nil {
+		l.Error("Invalid password configuration", "error", err)
+		os.Exit(1)
+	}
 
 	// Initialize services
+	auditRepository := audit.NewRepository(db)
+	auditService := audit.NewService(auditRepository)
+
 	userRepository := user.NewRepository(db)
-	userService := user.NewService(userRepository, config.Pepper)
+	userService := user.NewService(userRepository, hasher, blobs, config.AccountDeletionGracePeriod)
 
 	memberRepository := member.NewRepository(db)
-	memberService := member.NewService(memberRepository)
+	memberService := member.NewService(memberRepository, cacheService)
 
 	subscriptionRepository := subscription.NewRepository(db)
 	subscriptionService := subscription.NewService(subscriptionRepository)
 
+	activityRepository := activity.NewRepository(db)
+	activityService := activity.NewService(activityRepository, memberService)
+
 	clubRepository := club.NewRepository(db)
-	clubService := club.NewService(clubRepository, memberService, subscriptionService)
+	clubService := club.NewService(l, clubRepository, memberService, subscriptionService, activityService, config.DeletionRetentionPeriod)
 
 	authenticationConfig := authentication.Config{
+		Algorithm:     config.AuthNAlgorithm,
 		Secret:        config.AuthNSecret,
+		KeyRotation:   config.AuthNKeyRotation,
 		AccessExpiry:  config.AuthNAccessExpiry,
 		RefreshExpiry: config.AuthNRefreshExpiry,
-		Pepper:        config.Pepper,
+		TokenSecret:   config.AuthNTokenSecret,
+		AppURL:        config.AppURL,
+		LoginLimit:    config.RateLimits[api.RateLimitLogin].PerAccount,
+		SignupLimit:   config.RateLimits[api.RateLimitSignup].PerAccount,
+		Lockout: ratelimit.Lockout{
+			Threshold: config.LoginLockoutThreshold,
+			Base:      config.LoginLockoutBase,
+			Max:       config.LoginLockoutMax,
+			Window:    config.LoginLockoutWindow,
+		},
+	}
+	providers := make([]oidc.Provider, len(config.Providers))
+	for i, providerConfig := range config.Providers {
+		providers[i] = oidc.NewProvider(providerConfig, nil)
 	}
-	authenticationService := authentication.NewService(authenticationConfig, userService, subscriptionService, cacheService)
 
-	authorizationService := authorization.NewService(memberService)
+	authenticationRepository := authentication.NewRepository(db)
+	authenticationService := authentication.NewService(authenticationConfig, authenticationRepository, userService, memberService, subscriptionService, cacheService, mail, providers, limiter)
+
+	authorizationRepository := authorization.NewRepository(db)
+	authorizationService := authorization.NewService(authorizationRepository, memberService)
 
 	gameRepository := game.NewRepository(db)
-	gameService := game.NewService(gameRepository)
+	gameService := game.NewService(gameRepository, config.DeletionRetentionPeriod)
 
 	ratingRepository := rating.NewRepository(db)
 	ratingService := rating.NewService(ratingRepository)
@@ -82,18 +167,25 @@ func StartAPIserver(l *slog.Logger) {
 	statisticService := statistic.NewService(statisticRepository)
 
 	matchRepository := match.NewRepository(db)
-	matchService := match.NewService(matchRepository, gameService, ratingService, statisticService)
+	matchService := match.NewService(l, matchRepository, gameService, ratingService, statisticService, activityService)
+
+	eventRepository := event.NewRepository(db)
+	eventService := event.NewService(eventRepository, ratingService)
 
 	// Initialize API server
-	handlerConfig := handlers.Config{}
+	handlerConfig := handlers.Config{
+		AccessTokenDuration:  config.AuthNAccessExpiry,
+		RefreshTokenDuration: config.AuthNRefreshExpiry,
+	}
 
 	apiConfig := api.Config{
-		Port:    config.APIPort,
-		Version: config.APIVersion,
+		Port:       config.APIPort,
+		Version:    config.APIVersion,
+		RateLimits: config.RateLimits,
 	}
 
-	handler := handlers.NewHandler(l, handlerConfig, authenticationService, authorizationService, userService, clubService, memberService, matchService, ratingService, gameService, subscriptionService, statisticService)
-	apiServer := api.NewServer(apiConfig, config.APIVersion, l, handler, authenticationService, cacheService)
+	handler := handlers.NewHandler(l, handlerConfig, authenticationService, authorizationService, userService, clubService, memberService, matchService, ratingService, gameService, subscriptionService, statisticService, eventService, auditService, activityService)
+	apiServer := api.NewServer(apiConfig, config.APIVersion, l, handler, authenticationService, authorizationService, cacheService, limiter)
 	if err != nil {
 		l.Error("Failed to create api server", "error", err)
 		os.Exit(1)
@@ -102,6 +194,33 @@ func StartAPIserver(l *slog.Logger) {
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	// Anonymize accounts whose deletion grace period has passed
+	go runPeriodically(ctx, l, "purge deleted users", time.Hour, func(ctx context.Context) error {
+		userIDs, err := userService.PurgeDeletedUsers(ctx)
+		for _, userID := range userIDs {
+			if err := authenticationService.RevokeSessions(ctx, userID, ""); err != nil {
+				l.Error("Failed to revoke sessions of deleted user", "user", userID, "error", err)
+			}
+		}
+		return err
+	})
+
+	// Remove clubs and games whose retention period has passed
+	go runPeriodically(ctx, l, "purge deleted clubs and games", time.Hour, func(ctx context.Context) error {
+		clubs, err := clubService.PurgeDeletedClubs(ctx)
+		if err != nil {
+			return err
+		}
+		games, err := gameService.PurgeDeletedGames(ctx)
+		if err != nil {
+			return err
+		}
+		if clubs > 0 || games > 0 {
+			l.Info("Purged deleted clubs and games", "clubs", clubs, "games", games)
+		}
+		return nil
+	})
+
 	// Start the API server
 	l.Info("API server starting", "port", config.APIPort, "version", config.APIVersion)
 	go func() {
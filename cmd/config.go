@@ -2,6 +2,10 @@ package cmd
 
 import (
 	"bufio"
+	"core/internal/api"
+	"core/internal/oidc"
+	"core/internal/password"
+	"core/internal/ratelimit"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -15,13 +19,69 @@ import (
 type Config struct {
 	DatabaseDSN        string        `mapstructure:"DATABASE_DSN"`
 	RedisPort          int           `mapstructure:"REDIS_PORT"`
-	DenylistExpiry     time.Duration `mapstructure:"DENYLIST_EXPIRY"`
 	APIPort            int           `mapstructure:"API_PORT"`
 	APIVersion         string        `mapstructure:"API_VERSION"`
+	AuthNAlgorithm     string        `mapstructure:"AUTHN_ALGORITHM"`
 	AuthNSecret        string        `mapstructure:"AUTHN_SECRET"`
+	AuthNKeyRotation   time.Duration `mapstructure:"AUTHN_KEY_ROTATION"`
 	AuthNAccessExpiry  time.Duration `mapstructure:"AUTHN_ACCESS_EXPIRY"`
 	AuthNRefreshExpiry time.Duration `mapstructure:"AUTHN_REFRESH_EXPIRY"`
+	AuthNTokenSecret   string        `mapstructure:"AUTHN_TOKEN_SECRET"`
+	PasswordAlgorithm  string        `mapstructure:"PASSWORD_ALGORITHM"`
+	BcryptCost         int           `mapstructure:"PASSWORD_BCRYPT_COST"`
+	Argon2Memory       uint32        `mapstructure:"PASSWORD_ARGON2_MEMORY"`
+	Argon2Iterations   uint32        `mapstructure:"PASSWORD_ARGON2_ITERATIONS"`
+	Argon2Parallelism  uint8         `mapstructure:"PASSWORD_ARGON2_PARALLELISM"`
 	Pepper             string        `mapstructure:"PEPPER"`
+	PepperID           string        `mapstructure:"PEPPER_ID"`
+	// PreviousPeppers lists rotated out peppers as comma separated "id:secret"
+	// pairs, so hashes created with them still verify until rehashed
+	PreviousPeppers string `mapstructure:"PEPPER_PREVIOUS"`
+	AppURL          string `mapstructure:"APP_URL"`
+	Mailer          string `mapstructure:"MAILER"`
+	MailDir         string `mapstructure:"MAIL_DIR"`
+	SMTPHost        string `mapstructure:"SMTP_HOST"`
+	SMTPPort        int    `mapstructure:"SMTP_PORT"`
+	SMTPUsername    string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword    string `mapstructure:"SMTP_PASSWORD"`
+	SMTPFrom        string `mapstructure:"SMTP_FROM"`
+	// OIDCProviders lists provider names, each configured by OIDC_<NAME>_* variables
+	OIDCProviders string `mapstructure:"OIDC_PROVIDERS"`
+	// RateLimiter is redis, or memory for a single instance
+	RateLimiter           string        `mapstructure:"RATE_LIMITER"`
+	LoginLockoutThreshold int           `mapstructure:"LOGIN_LOCKOUT_THRESHOLD"`
+	LoginLockoutBase      time.Duration `mapstructure:"LOGIN_LOCKOUT_BASE"`
+	LoginLockoutMax       time.Duration `mapstructure:"LOGIN_LOCKOUT_MAX"`
+	LoginLockoutWindow    time.Duration `mapstructure:"LOGIN_LOCKOUT_WINDOW"`
+	// Storage is where blobs such as avatars are kept, only local for now
+	Storage    string `mapstructure:"STORAGE"`
+	StorageDir string `mapstructure:"STORAGE_DIR"`
+	// AccountDeletionGracePeriod is how long a deleted account can be restored
+	AccountDeletionGracePeriod time.Duration `mapstructure:"ACCOUNT_DELETION_GRACE_PERIOD"`
+	// DeletionRetentionPeriod is how long deleted clubs and games can be restored
+	DeletionRetentionPeriod time.Duration `mapstructure:"DELETION_RETENTION_PERIOD"`
+
+	Providers  []oidc.Config
+	RateLimits map[string]ratelimit.Rules
+}
+
+// defaultRateLimits apply to the route groups unless overridden by
+// RATE_LIMIT_<GROUP>_IP, _ACCOUNT and _GLOBAL.
+var defaultRateLimits = map[string]ratelimit.Rules{
+	api.RateLimitLogin: {
+		PerIP:      ratelimit.Limit{Requests: 20, Window: time.Minute},
+		PerAccount: ratelimit.Limit{Requests: 10, Window: time.Minute},
+		Global:     ratelimit.Limit{Requests: 1000, Window: time.Minute},
+	},
+	api.RateLimitSignup: {
+		PerIP:      ratelimit.Limit{Requests: 5, Window: time.Hour},
+		PerAccount: ratelimit.Limit{Requests: 3, Window: time.Hour},
+		Global:     ratelimit.Limit{Requests: 200, Window: time.Minute},
+	},
+	api.RateLimitRecovery: {
+		PerIP:  ratelimit.Limit{Requests: 10, Window: 15 * time.Minute},
+		Global: ratelimit.Limit{Requests: 200, Window: time.Minute},
+	},
 }
 
 func loadConfig() (*Config, error) {
@@ -35,9 +95,165 @@ func loadConfig() (*Config, error) {
 		}
 	}
 
+	cfg.Providers, err = loadOIDCProviders(".env", cfg.OIDCProviders)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.RateLimits, err = loadRateLimits(".env")
+	if err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
+// passwordConfig builds the hashing policy, keeping previous peppers
+// available for verification.
+func (c *Config) passwordConfig() (password.Config, error) {
+	pepperID := c.PepperID
+	if pepperID == "" {
+		pepperID = "1"
+	}
+
+	peppers := map[string]string{pepperID: c.Pepper}
+	for _, pair := range strings.Split(c.PreviousPeppers, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok || id == "" || secret == "" {
+			return password.Config{}, fmt.Errorf("previous pepper must be formatted as id:secret")
+		}
+		if _, exists := peppers[id]; exists {
+			return password.Config{}, fmt.Errorf("pepper id %s is configured twice", id)
+		}
+		peppers[id] = secret
+	}
+
+	return password.Config{
+		Algorithm:  c.PasswordAlgorithm,
+		BcryptCost: c.BcryptCost,
+		Argon2: password.Argon2Params{
+			Memory:      c.Argon2Memory,
+			Iterations:  c.Argon2Iterations,
+			Parallelism: c.Argon2Parallelism,
+		},
+		Peppers:  peppers,
+		PepperID: pepperID,
+	}, nil
+}
+
+// loadOIDCProviders reads the settings of each comma separated provider name
+// from OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and the
+// optional space separated _SCOPES.
+func loadOIDCProviders(configPath string, names string) ([]oidc.Config, error) {
+	if strings.TrimSpace(names) == "" {
+		return nil, nil
+	}
+
+	lookup, err := envLookup(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var providers []oidc.Config
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := oidc.Config{
+			Name:         name,
+			Issuer:       lookup(prefix + "ISSUER"),
+			ClientID:     lookup(prefix + "CLIENT_ID"),
+			ClientSecret: lookup(prefix + "CLIENT_SECRET"),
+			RedirectURL:  lookup(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(lookup(prefix + "SCOPES")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return nil, fmt.Errorf("oidc provider %s needs an issuer, client id and redirect url", name)
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+// loadRateLimits overrides the default limits of each route group, given as
+// "<requests>/<window>", e.g. "10/1m". Zero requests disable a limit.
+func loadRateLimits(configPath string) (map[string]ratelimit.Rules, error) {
+	lookup, err := envLookup(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimits := make(map[string]ratelimit.Rules, len(defaultRateLimits))
+	for group, rules := range defaultRateLimits {
+		prefix := "RATE_LIMIT_" + strings.ToUpper(group) + "_"
+		for suffix, limit := range map[string]*ratelimit.Limit{
+			"IP":      &rules.PerIP,
+			"ACCOUNT": &rules.PerAccount,
+			"GLOBAL":  &rules.Global,
+		} {
+			value := lookup(prefix + suffix)
+			if value == "" {
+				continue
+			}
+
+			parsed, err := parseLimit(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s%s: %w", prefix, suffix, err)
+			}
+			*limit = parsed
+		}
+
+		rateLimits[group] = rules
+	}
+
+	return rateLimits, nil
+}
+
+func parseLimit(value string) (ratelimit.Limit, error) {
+	requests, window, ok := strings.Cut(value, "/")
+	if !ok {
+		return ratelimit.Limit{}, fmt.Errorf("limit must be formatted as requests/window")
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n < 0 {
+		return ratelimit.Limit{}, fmt.Errorf("invalid number of requests %q", requests)
+	}
+
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || d <= 0 {
+		return ratelimit.Limit{}, fmt.Errorf("invalid window %q", window)
+	}
+
+	return ratelimit.Limit{Requests: n, Window: d}, nil
+}
+
+// envLookup returns a function reading variables whose names are only known
+// at runtime, preferring the environment over the .env file.
+func envLookup(configPath string) (func(key string) string, error) {
+	envMap := make(map[string]string)
+	if err := loadEnvFile(configPath, envMap); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load .env file: %w", err)
+	}
+
+	return func(key string) string {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+		return envMap[key]
+	}, nil
+}
+
 // Load reads configuration from .env file and environment variables
 // into the provided config struct. Environment variables take precedence
 // over .env file values. The struct should have `mapstructure` tags.
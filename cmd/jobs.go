@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// runPeriodically runs job every interval until ctx is done, starting right
+// away. Failures are logged and retried on the next tick.
+func runPeriodically(ctx context.Context, l *slog.Logger, name string, interval time.Duration, job func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(ctx); err != nil && ctx.Err() == nil {
+			l.Error("Job failed", "job", name, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package activity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Kind string
+
+const (
+	KindMemberJoined  Kind = "member.joined"
+	KindMatchRecorded Kind = "match.recorded"
+	// KindPersonalBest is a member's longest win streak in a game so far
+	KindPersonalBest    Kind = "streak.personal_best"
+	KindStreakMilestone Kind = "streak.milestone"
+)
+
+const (
+	// NotableRatingSwing is how far a rating has to move in one match to be
+	// pointed out in the feed.
+	NotableRatingSwing = 2.0
+	// minPersonalBestStreak keeps the first few wins from counting as records
+	minPersonalBestStreak = 3
+)
+
+// streakMilestones are the win streaks celebrated in the feed.
+var streakMilestones = []int{5, 10, 25, 50, 100}
+
+// StreakKind returns what a win streak is worth in the feed, if anything.
+// Milestones take precedence over personal bests.
+func StreakKind(streak int, newBest bool) (Kind, bool) {
+	for _, milestone := range streakMilestones {
+		if streak == milestone {
+			return KindStreakMilestone, true
+		}
+	}
+	if newBest && streak >= minPersonalBestStreak {
+		return KindPersonalBest, true
+	}
+
+	return "", false
+}
+
+// Activity is something worth showing in a club's feed. The member and game
+// it concerns are optional, as is the subject, e.g. the recorded match.
+type Activity struct {
+	ID        uuid.UUID  `db:"id"`
+	ClubID    uuid.UUID  `db:"club_id"`
+	Kind      Kind       `db:"kind"`
+	MemberID  *uuid.UUID `db:"member_id"`
+	GameID    *uuid.UUID `db:"game_id"`
+	SubjectID *uuid.UUID `db:"subject_id"`
+	Data      Data       `db:"data"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// Page is a slice of a feed, newest first. NextCursor continues after its
+// last activity and is nil at the end of the feed.
+type Page struct {
+	Activities []Activity
+	NextCursor *uuid.UUID
+}
+
+// Data holds what the feed shows about an activity, stored as JSON.
+type Data map[string]any
+
+func (d Data) Value() (driver.Value, error) {
+	if d == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode data: %w", err)
+	}
+
+	return string(b), nil
+}
+
+func (d *Data) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("cannot scan %T into data", src)
+	}
+
+	return json.Unmarshal(b, d)
+}
@@ -0,0 +1,67 @@
+package activity
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository interface {
+	CreateActivity(ctx context.Context, activity *Activity) error
+	// GetActivities returns the newest activities of the clubs, only those
+	// older than before if given. Activities of deleted games are left out.
+	GetActivities(ctx context.Context, clubIDs []uuid.UUID, before *uuid.UUID, limit int) ([]Activity, error)
+}
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (r *repository) CreateActivity(ctx context.Context, activity *Activity) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO activities (club_id, kind, member_id, game_id, subject_id, data) VALUES ($1, $2, $3, $4, $5, $6)",
+		activity.ClubID, activity.Kind, activity.MemberID, activity.GameID, activity.SubjectID, activity.Data)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) GetActivities(ctx context.Context, clubIDs []uuid.UUID, before *uuid.UUID, limit int) ([]Activity, error) {
+	var activities []Activity
+
+	query := `
+		SELECT *
+		FROM activities
+		WHERE club_id IN (?)
+		AND (game_id IS NULL OR game_id IN (SELECT id FROM games WHERE deleted_at IS NULL))`
+	args := []any{clubIDs}
+	// IDs are time ordered, so they double as the cursor
+	if before != nil {
+		query += " AND id < ?"
+		args = append(args, *before)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	query = r.db.Rebind(query)
+	err = r.db.SelectContext(ctx, &activities, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return activities, nil
+}
@@ -0,0 +1,88 @@
+package activity
+
+import (
+	"context"
+	"core/internal/member"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+type Service interface {
+	// Record adds the activity to its club's feed.
+	Record(ctx context.Context, activity *Activity) error
+	// GetClubFeed returns a page of the club's feed, starting after the
+	// cursor if given.
+	GetClubFeed(ctx context.Context, clubID uuid.UUID, cursor *uuid.UUID, limit int) (*Page, error)
+	// GetUserFeed returns a page of the feeds of all the user's clubs combined.
+	GetUserFeed(ctx context.Context, userID uuid.UUID, cursor *uuid.UUID, limit int) (*Page, error)
+}
+
+type service struct {
+	repo          Repository
+	memberService member.Service
+}
+
+func NewService(repo Repository, memberService member.Service) Service {
+	return &service{
+		repo:          repo,
+		memberService: memberService,
+	}
+}
+
+func (s *service) Record(ctx context.Context, activity *Activity) error {
+	if err := s.repo.CreateActivity(ctx, activity); err != nil {
+		return fmt.Errorf("failed to record %s activity: %w", activity.Kind, err)
+	}
+
+	return nil
+}
+
+func (s *service) GetClubFeed(ctx context.Context, clubID uuid.UUID, cursor *uuid.UUID, limit int) (*Page, error) {
+	return s.getPage(ctx, []uuid.UUID{clubID}, cursor, limit)
+}
+
+func (s *service) GetUserFeed(ctx context.Context, userID uuid.UUID, cursor *uuid.UUID, limit int) (*Page, error) {
+	memberships, err := s.memberService.GetUserMemberships(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get memberships: %w", err)
+	}
+	if len(memberships) == 0 {
+		return &Page{Activities: []Activity{}}, nil
+	}
+
+	clubIDs := make([]uuid.UUID, len(memberships))
+	for i, membership := range memberships {
+		clubIDs[i] = membership.ClubID
+	}
+
+	return s.getPage(ctx, clubIDs, cursor, limit)
+}
+
+func (s *service) getPage(ctx context.Context, clubIDs []uuid.UUID, cursor *uuid.UUID, limit int) (*Page, error) {
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	// One more than asked for tells whether there is a next page
+	activities, err := s.repo.GetActivities(ctx, clubIDs, cursor, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get activities: %w", err)
+	}
+
+	page := &Page{Activities: activities}
+	if len(activities) > limit {
+		page.Activities = activities[:limit]
+		page.NextCursor = &page.Activities[limit-1].ID
+	}
+
+	return page, nil
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/authentication"
+	"core/internal/authorization"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+type apiKeyBody struct {
+	Name      string                `json:"name" minLength:"1" maxLength:"64"`
+	Scopes    []authorization.Scope `json:"scopes" minItems:"1" doc:"e.g. matches:write, stats:read"`
+	ExpiresAt *time.Time            `json:"expiresAt,omitempty"`
+}
+
+type apiKeyResponse struct {
+	ID         uuid.UUID             `json:"id"`
+	Name       string                `json:"name"`
+	Prefix     string                `json:"prefix"`
+	Scopes     []authorization.Scope `json:"scopes"`
+	ExpiresAt  *time.Time            `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time            `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time             `json:"createdAt"`
+	TwoFactor  bool                  `json:"twoFactor" doc:"Whether the key satisfies clubs requiring two-factor authentication"`
+}
+
+func mapAPIKey(key authentication.APIKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		CreatedAt:  key.CreatedAt,
+		TwoFactor:  key.TwoFactor,
+	}
+}
+
+// createAPIKeyResponse carries the key's secret, which can't be shown again.
+type createAPIKeyResponse struct {
+	Body struct {
+		Key   apiKeyResponse `json:"key"`
+		Token string         `json:"token"`
+	}
+}
+
+type getAPIKeysResponse struct {
+	Body struct {
+		Keys []apiKeyResponse `json:"keys"`
+	}
+}
+
+type postPersonalTokenRequest struct {
+	Body apiKeyBody
+}
+
+func (h *Handler) PostPersonalToken(ctx context.Context, req *postPersonalTokenRequest) (*createAPIKeyResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	// The key inherits the session's second factor, as clubs requiring one
+	// would refuse it otherwise
+	key := &authentication.APIKey{
+		UserID:    &principal.UserID,
+		CreatedBy: &principal.UserID,
+		TwoFactor: principal.TwoFactor,
+	}
+
+	return h.createAPIKey(ctx, key, req.Body)
+}
+
+func (h *Handler) GetPersonalTokens(ctx context.Context, req *struct{}) (*getAPIKeysResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	keys, err := h.authentication.GetUserAPIKeys(ctx, principal.UserID)
+	if err != nil {
+		h.l.Error("failed to get personal access tokens", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get tokens, try again later")
+	}
+
+	return mapAPIKeys(keys), nil
+}
+
+type deletePersonalTokenRequest struct {
+	KeyID uuid.UUID `path:"keyId"`
+}
+
+func (h *Handler) DeletePersonalToken(ctx context.Context, req *deletePersonalTokenRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if err := h.authentication.RevokeUserAPIKey(ctx, principal.UserID, req.KeyID); err != nil {
+		if errors.Is(err, authentication.ErrAPIKeyNotFound) {
+			return nil, huma.Error404NotFound("token not found")
+		}
+		h.l.Error("failed to revoke personal access token", "error", err)
+		return nil, huma.Error500InternalServerError("failed to revoke token, try again later")
+	}
+
+	return nil, nil
+}
+
+type postClubAPIKeyRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+	Body   apiKeyBody
+}
+
+// PostClubAPIKey only grants scopes whose permissions the creator holds.
+func (h *Handler) PostClubAPIKey(ctx context.Context, req *postClubAPIKeyRequest) (*createAPIKeyResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	for _, scope := range req.Body.Scopes {
+		if !scope.IsValid() {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("unknown scope %s", scope))
+		}
+
+		if err := h.authorization.Require(ctx, principal.UserID, req.ClubID, scope.Permission()); err != nil {
+			if errors.Is(err, authorization.ErrForbidden) {
+				return nil, huma.Error403Forbidden(fmt.Sprintf("you can't grant the scope %s", scope))
+			}
+			h.l.Error("failed to check permission", "error", err)
+			return nil, huma.Error500InternalServerError("failed to create api key, try again later")
+		}
+	}
+
+	key := &authentication.APIKey{
+		ClubID:    &req.ClubID,
+		CreatedBy: &principal.UserID,
+	}
+
+	return h.createAPIKey(ctx, key, req.Body)
+}
+
+type getClubAPIKeysRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+}
+
+func (h *Handler) GetClubAPIKeys(ctx context.Context, req *getClubAPIKeysRequest) (*getAPIKeysResponse, error) {
+	keys, err := h.authentication.GetClubAPIKeys(ctx, req.ClubID)
+	if err != nil {
+		h.l.Error("failed to get club api keys", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get api keys, try again later")
+	}
+
+	return mapAPIKeys(keys), nil
+}
+
+type deleteClubAPIKeyRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+	KeyID  uuid.UUID `path:"keyId"`
+}
+
+func (h *Handler) DeleteClubAPIKey(ctx context.Context, req *deleteClubAPIKeyRequest) (*struct{}, error) {
+	if err := h.authentication.RevokeClubAPIKey(ctx, req.ClubID, req.KeyID); err != nil {
+		if errors.Is(err, authentication.ErrAPIKeyNotFound) {
+			return nil, huma.Error404NotFound("api key not found")
+		}
+		h.l.Error("failed to revoke club api key", "error", err)
+		return nil, huma.Error500InternalServerError("failed to revoke api key, try again later")
+	}
+
+	return nil, nil
+}
+
+func (h *Handler) createAPIKey(ctx context.Context, key *authentication.APIKey, body apiKeyBody) (*createAPIKeyResponse, error) {
+	if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+		return nil, huma.Error422UnprocessableEntity("expiry must be in the future")
+	}
+
+	key.Name = body.Name
+	key.Scopes = body.Scopes
+	key.ExpiresAt = body.ExpiresAt
+
+	token, err := h.authentication.CreateAPIKey(ctx, key)
+	if err != nil {
+		if errors.Is(err, authentication.ErrInvalidScope) {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		h.l.Error("failed to create api key", "error", err)
+		return nil, huma.Error500InternalServerError("failed to create api key, try again later")
+	}
+
+	resp := &createAPIKeyResponse{}
+	resp.Body.Key = mapAPIKey(*key)
+	resp.Body.Token = token
+
+	return resp, nil
+}
+
+func mapAPIKeys(keys []authentication.APIKey) *getAPIKeysResponse {
+	mappedKeys := make([]apiKeyResponse, len(keys))
+	for i, key := range keys {
+		mappedKeys[i] = mapAPIKey(key)
+	}
+
+	resp := &getAPIKeysResponse{}
+	resp.Body.Keys = mappedKeys
+
+	return resp
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/audit"
+	"errors"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+type getClubAuditLogRequest struct {
+	ClubID   uuid.UUID  `path:"clubId"`
+	Action   string     `query:"action" doc:"Only entries of this action, e.g. member.role_changed"`
+	ActorID  *uuid.UUID `query:"actorId" required:"false" doc:"Only entries of this user"`
+	TargetID *uuid.UUID `query:"targetId" required:"false" doc:"Only entries concerning this member, game or match"`
+	Since    time.Time  `query:"since" doc:"Only entries from this time on"`
+	Until    time.Time  `query:"until" doc:"Only entries before this time"`
+	Limit    int        `query:"limit" minimum:"1" maximum:"100" default:"50"`
+	Offset   int        `query:"offset" minimum:"0"`
+}
+
+type auditEntryResponse struct {
+	ID        uuid.UUID      `json:"id"`
+	ActorID   *uuid.UUID     `json:"actorId,omitempty" doc:"Missing for actions of API keys, the system or deleted users"`
+	Action    audit.Action   `json:"action"`
+	TargetID  *uuid.UUID     `json:"targetId,omitempty"`
+	Details   audit.Details  `json:"details,omitempty"`
+	Before    audit.Snapshot `json:"before,omitempty"`
+	After     audit.Snapshot `json:"after,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+type getClubAuditLogResponse struct {
+	Body struct {
+		Entries []auditEntryResponse `json:"entries"`
+	}
+}
+
+// GetClubAuditLog lists the club's administrative actions, newest first.
+func (h *Handler) GetClubAuditLog(ctx context.Context, req *getClubAuditLogRequest) (*getClubAuditLogResponse, error) {
+	filter := audit.Filter{
+		Action:   audit.Action(req.Action),
+		ActorID:  req.ActorID,
+		TargetID: req.TargetID,
+		Limit:    req.Limit,
+		Offset:   req.Offset,
+	}
+	if !req.Since.IsZero() {
+		filter.Since = &req.Since
+	}
+	if !req.Until.IsZero() {
+		filter.Until = &req.Until
+	}
+
+	entries, err := h.audit.GetEntries(ctx, req.ClubID, filter)
+	if err != nil {
+		if errors.Is(err, audit.ErrInvalidAction) {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		h.l.Error("failed to get audit log", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get audit log, try again later")
+	}
+
+	resp := &getClubAuditLogResponse{}
+	resp.Body.Entries = make([]auditEntryResponse, len(entries))
+	for i, entry := range entries {
+		resp.Body.Entries[i] = auditEntryResponse{
+			ID:        entry.ID,
+			ActorID:   entry.ActorID,
+			Action:    entry.Action,
+			TargetID:  entry.TargetID,
+			Details:   entry.Details,
+			Before:    entry.Before,
+			After:     entry.After,
+			CreatedAt: entry.CreatedAt,
+		}
+	}
+
+	return resp, nil
+}
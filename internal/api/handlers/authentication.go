@@ -2,12 +2,18 @@ package handlers
 
 import (
 	"context"
-	"time"
-
+	"core/internal/api/middleware"
+	"core/internal/authentication"
+	"core/internal/ratelimit"
+	"core/internal/user"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/danielgtaylor/huma/v2"
-	"github.com/google/uuid"
 )
 
 type loginRequest struct {
@@ -17,13 +23,22 @@ type loginRequest struct {
 	}
 }
 
+// loginResponse carries the token cookies, or the challenge to answer at
+// /auth/login/two-factor if the user enabled two-factor authentication.
 type loginResponse struct {
 	SetCookie []http.Cookie `header:"Set-Cookie"`
+	Body      struct {
+		TwoFactorRequired bool   `json:"twoFactorRequired"`
+		Challenge         string `json:"challenge,omitempty"`
+	}
 }
 
 func (h *Handler) Login(ctx context.Context, req *loginRequest) (*loginResponse, error) {
-	correct, accessToken, refreshToken, err := h.authentication.Login(ctx, req.Body.Email, req.Body.Password)
+	correct, result, err := h.authentication.Login(ctx, req.Body.Email, req.Body.Password, authentication.ClientFrom(ctx))
 	if err != nil {
+		if errors.Is(err, ratelimit.ErrLimited) {
+			return nil, tooManyRequests(err, "too many login attempts, try again later")
+		}
 		h.l.Error("failed to login", "error", err)
 		return nil, huma.Error500InternalServerError("failed to login, try again later")
 	}
@@ -31,34 +46,54 @@ func (h *Handler) Login(ctx context.Context, req *loginRequest) (*loginResponse,
 		return nil, huma.Error400BadRequest("invalid email or password")
 	}
 
-	resp := &loginResponse{
-		SetCookie: []http.Cookie{
-			{
-				Name:     "access",
-				Value:    accessToken,
-				Path:     "/",
-				Secure:   true,
-				HttpOnly: true,
-				SameSite: http.SameSiteStrictMode,
-				MaxAge:   int(h.config.AccessTokenDuration.Seconds()),
-			},
-			{
-				Name:     "refresh",
-				Value:    refreshToken,
-				Path:     "/",
-				Secure:   true,
-				HttpOnly: true,
-				SameSite: http.SameSiteStrictMode,
-				MaxAge:   int(h.config.RefreshTokenDuration.Seconds()),
-			},
-		},
+	return h.loginResponse(result)
+}
+
+type loginChallengeRequest struct {
+	Body struct {
+		Challenge string `json:"challenge" minLength:"1"`
+		Code      string `json:"code" minLength:"6" maxLength:"16" doc:"TOTP code or recovery code"`
+	}
+}
+
+func (h *Handler) LoginTwoFactor(ctx context.Context, req *loginChallengeRequest) (*loginResponse, error) {
+	accessToken, refreshToken, err := h.authentication.VerifyLoginChallenge(ctx, req.Body.Challenge, req.Body.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, authentication.ErrInvalidToken):
+			return nil, huma.Error401Unauthorized("login expired, log in again")
+		case errors.Is(err, authentication.ErrInvalidCode):
+			return nil, huma.Error400BadRequest("invalid code")
+		case errors.Is(err, ratelimit.ErrLimited):
+			return nil, tooManyRequests(err, "too many invalid codes, try again later")
+		}
+		h.l.Error("failed to verify login challenge", "error", err)
+		return nil, huma.Error500InternalServerError("failed to login, try again later")
+	}
+
+	return h.loginResponse(&authentication.LoginResult{AccessToken: accessToken, RefreshToken: refreshToken})
+}
+
+func (h *Handler) loginResponse(result *authentication.LoginResult) (*loginResponse, error) {
+	resp := &loginResponse{}
+	if result.Challenge != "" {
+		resp.Body.TwoFactorRequired = true
+		resp.Body.Challenge = result.Challenge
+		return resp, nil
+	}
+
+	cookies, err := h.tokenCookies(result.AccessToken, result.RefreshToken)
+	if err != nil {
+		h.l.Error("failed to create cookies", "error", err)
+		return nil, huma.Error500InternalServerError("failed to login, try again later")
 	}
+	resp.SetCookie = cookies
 
 	return resp, nil
 }
 
 type refreshRequest struct {
-	RefreshToken http.Cookie `json:"refreshToken"`
+	RefreshToken string `cookie:"refresh" required:"true"`
 }
 
 type refreshResponse struct {
@@ -66,41 +101,22 @@ type refreshResponse struct {
 }
 
 func (h *Handler) Refresh(ctx context.Context, req *refreshRequest) (*refreshResponse, error) {
-	valid, _, err := h.authentication.VerifyRefreshToken(ctx, req.RefreshToken.Value)
+	accessToken, refreshToken, err := h.authentication.RefreshTokens(ctx, req.RefreshToken)
 	if err != nil {
-		h.l.Error("failed to verify refresh token", "error", err)
-		return nil, huma.Error500InternalServerError("failed to verify refresh token")
-	}
-
-	if !valid {
-		return nil, huma.Error401Unauthorized("invalid refresh token")
+		if errors.Is(err, authentication.ErrInvalidToken) {
+			return nil, huma.Error401Unauthorized("invalid refresh token, log in again")
+		}
+		h.l.Error("failed to refresh tokens", "error", err)
+		return nil, huma.Error500InternalServerError("failed to refresh tokens")
 	}
 
-	accessToken, refreshToken, err := h.authentication.RefreshTokens(ctx, req.RefreshToken.Value)
+	cookies, err := h.tokenCookies(accessToken, refreshToken)
 	if err != nil {
-		h.l.Error("failed to refresh tokens", "error", err)
+		h.l.Error("failed to create cookies", "error", err)
 		return nil, huma.Error500InternalServerError("failed to refresh tokens")
 	}
 
-	resp := &refreshResponse{
-		SetCookie: []http.Cookie{
-			{
-				Name:     "access",
-				Value:    accessToken,
-				Path:     "/",
-				Expires:  time.Now().Add(time.Hour),
-				Secure:   true,
-				HttpOnly: true,
-			}, {
-				Name:     "refresh",
-				Value:    refreshToken,
-				Path:     "/",
-				Expires:  time.Now().Add(12 * time.Hour),
-				Secure:   true,
-				HttpOnly: true,
-			},
-		},
-	}
+	resp := &refreshResponse{SetCookie: cookies}
 
 	return resp, nil
 }
@@ -116,6 +132,9 @@ type signupRequest struct {
 func (h *Handler) Signup(ctx context.Context, req *signupRequest) (*loginResponse, error) {
 	success, err := h.authentication.Signup(ctx, req.Body.Email, req.Body.Name, req.Body.Password)
 	if err != nil {
+		if errors.Is(err, ratelimit.ErrLimited) {
+			return nil, tooManyRequests(err, "too many signup attempts, try again later")
+		}
 		h.l.Error("failed to signup", "error", err)
 		return nil, huma.Error500InternalServerError("failed to signup, try again later")
 	}
@@ -134,6 +153,11 @@ func (h *Handler) Signup(ctx context.Context, req *signupRequest) (*loginRespons
 		return nil, huma.Error500InternalServerError("failed to create subscription")
 	}
 
+	// The account is usable without it, the user can ask for another one
+	if err := h.authentication.SendVerificationEmail(ctx, u.ID); err != nil {
+		h.l.Error("failed to send verification email", "error", err)
+	}
+
 	loginReq := &loginRequest{}
 	loginReq.Body.Email = req.Body.Email
 	loginReq.Body.Password = req.Body.Password
@@ -149,17 +173,25 @@ type changePasswordRequest struct {
 }
 
 func (h *Handler) ChangePassword(ctx context.Context, req *changePasswordRequest) (*struct{}, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
+	principal, ok := authentication.PrincipalFrom(ctx)
 	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
+		return nil, huma.Error401Unauthorized("not authenticated")
 	}
 
-	if err := h.user.UpdatePassword(ctx, userID, req.Body.OldPassword, req.Body.NewPassword); err != nil {
+	if err := h.user.UpdatePassword(ctx, principal.UserID, req.Body.OldPassword, req.Body.NewPassword); err != nil {
+		if errors.Is(err, user.ErrWrongPassword) {
+			return nil, huma.Error403Forbidden("old password is incorrect")
+		}
 		h.l.Error("failed to change password", "error", err)
 		return nil, huma.Error500InternalServerError("failed to change password, try again later")
 	}
 
+	// Whoever knew the old password must not stay logged in elsewhere
+	if err := h.authentication.RevokeSessions(ctx, principal.UserID, principal.SessionID); err != nil {
+		h.l.Error("failed to revoke other sessions", "error", err)
+		return nil, huma.Error500InternalServerError("password changed, but failed to log out other sessions")
+	}
+
 	return nil, nil
 }
 
@@ -168,39 +200,91 @@ type logoutResponse struct {
 }
 
 func (h *Handler) Logout(ctx context.Context, req *struct{}) (*logoutResponse, error) {
-	token, ok := ctx.Value("token").(string)
+	principal, ok := authentication.PrincipalFrom(ctx)
 	if !ok {
-		h.l.Error("failed to get token from context")
-		return nil, huma.Error500InternalServerError("failed to get token from context")
+		return nil, huma.Error401Unauthorized("not authenticated")
 	}
 
-	if err := h.authentication.Logout(ctx, token); err != nil {
+	if err := h.authentication.Logout(ctx, principal); err != nil {
 		h.l.Error("failed to logout", "error", err)
 		return nil, huma.Error500InternalServerError("failed to logout, try again later")
 	}
 
-	resp := &logoutResponse{
-		SetCookie: []http.Cookie{
-			{
-				Name:     "access",
-				Value:    "",
-				Path:     "/",
-				HttpOnly: true,
-				Secure:   true,
-				SameSite: http.SameSiteStrictMode,
-				MaxAge:   -1,
-			},
-			{
-				Name:     "refresh",
-				Value:    "",
-				Path:     "/",
-				HttpOnly: true,
-				Secure:   true,
-				SameSite: http.SameSiteStrictMode,
-				MaxAge:   -1,
-			},
+	resp := &logoutResponse{SetCookie: clearedCookies()}
+
+	return resp, nil
+}
+
+const refreshCookie = "refresh"
+
+// tokenCookies returns the cookies for a token pair, along with a fresh csrf
+// token that cookie authenticated clients must echo in the X-CSRF-Token header.
+// The csrf cookie is readable by scripts so the client can do so.
+func (h *Handler) tokenCookies(accessToken, refreshToken string) ([]http.Cookie, error) {
+	csrf := make([]byte, 32)
+	if _, err := rand.Read(csrf); err != nil {
+		return nil, fmt.Errorf("failed to generate csrf token: %w", err)
+	}
+
+	return []http.Cookie{
+		{
+			Name:     middleware.AccessCookie,
+			Value:    accessToken,
+			Path:     "/",
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+			MaxAge:   int(h.config.AccessTokenDuration.Seconds()),
+		},
+		{
+			Name:     refreshCookie,
+			Value:    refreshToken,
+			Path:     "/",
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+			MaxAge:   int(h.config.RefreshTokenDuration.Seconds()),
+		},
+		{
+			Name:     middleware.CSRFCookie,
+			Value:    base64.RawURLEncoding.EncodeToString(csrf),
+			Path:     "/",
+			Secure:   true,
+			SameSite: http.SameSiteStrictMode,
+			MaxAge:   int(h.config.RefreshTokenDuration.Seconds()),
 		},
+	}, nil
+}
+
+// clearedCookies expires all cookies set by tokenCookies.
+func clearedCookies() []http.Cookie {
+	return []http.Cookie{
+		expiredCookie(middleware.AccessCookie, true),
+		expiredCookie(refreshCookie, true),
+		expiredCookie(middleware.CSRFCookie, false),
+	}
+}
+
+func expiredCookie(name string, httpOnly bool) http.Cookie {
+	return http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: httpOnly,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   -1,
+	}
+}
+
+// tooManyRequests tells the client when it may try again.
+func tooManyRequests(err error, msg string) error {
+	var limited *ratelimit.LimitedError
+	if !errors.As(err, &limited) {
+		return huma.Error429TooManyRequests(msg)
 	}
 
-	return resp, nil
+	header := http.Header{}
+	header.Set("Retry-After", strconv.Itoa(limited.RetryAfterSeconds()))
+	return huma.ErrorWithHeaders(huma.Error429TooManyRequests(msg), header)
 }
@@ -2,7 +2,13 @@ package handlers
 
 import (
 	"context"
+	"core/internal/authentication"
+	"core/internal/authorization"
+	"core/internal/club"
 	"core/internal/member"
+	"errors"
+	"net/http"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/google/uuid"
@@ -67,13 +73,12 @@ type createClubResponse struct {
 }
 
 func (h *Handler) CreateClub(ctx context.Context, req *createClubRequest) (*createClubResponse, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
+	principal, ok := authentication.PrincipalFrom(ctx)
 	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
+		return nil, huma.Error401Unauthorized("not authenticated")
 	}
 
-	clubID, err := h.club.CreateClub(ctx, req.Body.Name, userID)
+	clubID, err := h.club.CreateClub(ctx, req.Body.Name, principal.UserID)
 	if err != nil {
 		h.l.Error("failed to create club", "error", err)
 		return nil, huma.Error500InternalServerError("failed to create club, try again later")
@@ -90,46 +95,102 @@ type deleteClubRequest struct {
 	ClubID uuid.UUID `path:"clubId"  minimum:"1"`
 }
 
-func (h *Handler) DeleteClub(ctx context.Context, req *deleteClubRequest) (*struct{}, error) {
-	if err := h.club.DeleteClub(ctx, req.ClubID); err != nil {
+type deleteClubResponse struct {
+	Status int
+	Body   struct {
+		PurgeAt time.Time `json:"purgeAt" doc:"Until then owners can restore the club"`
+	}
+}
+
+// DeleteClub hides the club, which is purged after the retention period.
+func (h *Handler) DeleteClub(ctx context.Context, req *deleteClubRequest) (*deleteClubResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	purgeAt, err := h.club.DeleteClub(ctx, principal.UserID, req.ClubID)
+	if err != nil {
+		if errors.Is(err, club.ErrNotFound) {
+			return nil, huma.Error404NotFound("club not found")
+		}
 		h.l.Error("failed to delete club", "error", err)
 		return nil, huma.Error500InternalServerError("failed to delete club, try again later")
 	}
 
+	resp := &deleteClubResponse{Status: http.StatusAccepted}
+	resp.Body.PurgeAt = purgeAt
+
+	return resp, nil
+}
+
+type restoreClubRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+}
+
+// RestoreClub brings back a deleted club. Its members lost their role in it
+// with the deletion, so the service checks the caller owns it.
+func (h *Handler) RestoreClub(ctx context.Context, req *restoreClubRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if err := h.club.RestoreClub(ctx, principal.UserID, req.ClubID); err != nil {
+		switch {
+		case errors.Is(err, club.ErrNotFound):
+			return nil, huma.Error404NotFound("no deleted club found")
+		case errors.Is(err, club.ErrNotOwner):
+			return nil, huma.Error403Forbidden("only owners can restore the club")
+		}
+		h.l.Error("failed to restore club", "error", err)
+		return nil, huma.Error500InternalServerError("failed to restore club, try again later")
+	}
+
 	return nil, nil
 }
 
 type updateClubRequest struct {
 	ClubID uuid.UUID `path:"clubId" minimum:"1"`
 	Body   struct {
-		Name string `json:"name" minLength:"2" maxLength:"64"`
+		Name       string          `json:"name" minLength:"2" maxLength:"64"`
+		Location   *string         `json:"location,omitempty" maxLength:"100"`
+		JoinPolicy club.JoinPolicy `json:"joinPolicy,omitempty" enum:"open,approval,invite"`
 	}
 }
 
 type updateClubResponse struct {
 	Body struct {
-		ID   uuid.UUID `json:"id"`
-		Name string    `json:"name"`
+		ID         uuid.UUID `json:"id"`
+		Name       string    `json:"name"`
+		Location   string    `json:"location"`
+		JoinPolicy string    `json:"joinPolicy"`
 	}
 }
 
 func (h *Handler) UpdateClub(ctx context.Context, req *updateClubRequest) (*updateClubResponse, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
+	principal, ok := authentication.PrincipalFrom(ctx)
 	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
+		return nil, huma.Error401Unauthorized("not authenticated")
 	}
 
-	ok, err := h.authorization.IsAdmin(ctx, userID, req.ClubID)
+	c, err := h.club.GetClub(ctx, req.ClubID)
 	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
+		h.l.Error("failed to get club", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get club")
 	}
-	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to update this club")
+
+	// Omitted fields keep their current value
+	location := c.Location
+	if req.Body.Location != nil {
+		location = *req.Body.Location
+	}
+	policy := c.JoinPolicy
+	if req.Body.JoinPolicy != "" {
+		policy = req.Body.JoinPolicy
 	}
 
-	if err := h.club.UpdateClub(ctx, req.ClubID, req.Body.Name); err != nil {
+	if err := h.club.UpdateClub(ctx, principal.UserID, req.ClubID, req.Body.Name, location, policy); err != nil {
 		h.l.Error("failed to update club", "error", err)
 		return nil, huma.Error500InternalServerError("failed to update club")
 	}
@@ -137,6 +198,43 @@ func (h *Handler) UpdateClub(ctx context.Context, req *updateClubRequest) (*upda
 	resp := &updateClubResponse{}
 	resp.Body.ID = req.ClubID
 	resp.Body.Name = req.Body.Name
+	resp.Body.Location = location
+	resp.Body.JoinPolicy = string(policy)
+
+	return resp, nil
+}
+
+type putClubSecurityRequest struct {
+	ClubID uuid.UUID `path:"clubId" minimum:"1"`
+	Body   struct {
+		RequireTwoFactor bool `json:"requireTwoFactor" doc:"Require admins and owners to use two-factor authentication"`
+	}
+}
+
+type putClubSecurityResponse struct {
+	Body struct {
+		RequireTwoFactor bool `json:"requireTwoFactor"`
+	}
+}
+
+func (h *Handler) PutClubSecurity(ctx context.Context, req *putClubSecurityRequest) (*putClubSecurityResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	// Otherwise the owner would lock themselves out of the setting
+	if req.Body.RequireTwoFactor && !principal.TwoFactor {
+		return nil, huma.Error403Forbidden("log in with two-factor authentication before requiring it")
+	}
+
+	if err := h.club.SetRequireTwoFactor(ctx, principal.UserID, req.ClubID, req.Body.RequireTwoFactor); err != nil {
+		h.l.Error("failed to set two factor requirement", "error", err)
+		return nil, huma.Error500InternalServerError("failed to update club security, try again later")
+	}
+
+	resp := &putClubSecurityResponse{}
+	resp.Body.RequireTwoFactor = req.Body.RequireTwoFactor
 
 	return resp, nil
 }
@@ -145,27 +243,37 @@ type updateMemberRoleRequest struct {
 	ClubID   uuid.UUID `path:"clubId" minimum:"1"`
 	MemberID uuid.UUID `path:"memberId" minimum:"1"`
 	Body     struct {
-		Role member.Role `json:"role" enum:"ADMIN,MANAGER,MEMBER"`
+		Role member.Role `json:"role" enum:"observer,member,manager,admin"`
 	}
 }
 
 func (h *Handler) UpdateMemberRole(ctx context.Context, req *updateMemberRoleRequest) (*struct{}, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
+	principal, ok := authentication.PrincipalFrom(ctx)
 	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
+		return nil, huma.Error401Unauthorized("not authenticated")
 	}
 
-	ok, err := h.authorization.IsAdmin(ctx, userID, req.MemberID)
+	m, err := h.member.GetMember(ctx, req.MemberID)
 	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
+		h.l.Error("failed to get member", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get member")
 	}
-	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to update member role")
+	if m.ClubID != req.ClubID {
+		return nil, huma.Error404NotFound("member not found in this club")
+	}
+	if m.IsGuest() {
+		return nil, huma.Error422UnprocessableEntity("guests can't hold a role")
 	}
 
-	if err := h.member.UpdateRole(ctx, req.MemberID, req.Body.Role); err != nil {
+	if err := h.member.UpdateRole(ctx, principal.UserID, req.MemberID, req.Body.Role); err != nil {
+		switch {
+		case errors.Is(err, member.ErrRoleTooHigh):
+			return nil, huma.Error403Forbidden("you can't change roles above your own")
+		case errors.Is(err, member.ErrOwnerRole):
+			return nil, huma.Error422UnprocessableEntity("ownership can only be transferred")
+		case errors.Is(err, member.ErrLastOwner):
+			return nil, huma.Error409Conflict("the club must keep an owner, transfer ownership first")
+		}
 		h.l.Error("failed to update role", "error", err)
 		return nil, huma.Error500InternalServerError("failed to update role, try again later")
 	}
@@ -173,6 +281,61 @@ func (h *Handler) UpdateMemberRole(ctx context.Context, req *updateMemberRoleReq
 	return nil, nil
 }
 
+type updateMemberNicknameRequest struct {
+	ClubID   uuid.UUID `path:"clubId" minimum:"1"`
+	MemberID uuid.UUID `path:"memberId" minimum:"1"`
+	Body     struct {
+		Nickname string `json:"nickname" maxLength:"50" doc:"Empty to remove the nickname, which guests must keep"`
+	}
+}
+
+// UpdateMemberNickname lets members name themselves within the club, and
+// those managing members name anyone.
+func (h *Handler) UpdateMemberNickname(ctx context.Context, req *updateMemberNicknameRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	m, err := h.member.GetMember(ctx, req.MemberID)
+	if err != nil {
+		if errors.Is(err, member.ErrNotFound) {
+			return nil, huma.Error404NotFound("member not found in this club")
+		}
+		h.l.Error("failed to get member", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get member")
+	}
+	if m.ClubID != req.ClubID {
+		return nil, huma.Error404NotFound("member not found in this club")
+	}
+
+	if m.IsGuest() && req.Body.Nickname == "" {
+		return nil, huma.Error422UnprocessableEntity("guests must keep a nickname")
+	}
+
+	if m.IsGuest() || *m.UserID != principal.UserID {
+		if err := h.authorization.Require(ctx, principal.UserID, req.ClubID, authorization.PermissionManageMembers); err != nil {
+			if errors.Is(err, authorization.ErrForbidden) {
+				return nil, huma.Error403Forbidden("you can only change your own nickname")
+			}
+			h.l.Error("failed to check permission", "error", err)
+			return nil, huma.Error500InternalServerError("failed to update nickname, try again later")
+		}
+	}
+
+	var nickname *string
+	if req.Body.Nickname != "" {
+		nickname = &req.Body.Nickname
+	}
+
+	if err := h.member.UpdateNickname(ctx, principal.UserID, req.MemberID, nickname); err != nil {
+		h.l.Error("failed to update nickname", "error", err)
+		return nil, huma.Error500InternalServerError("failed to update nickname, try again later")
+	}
+
+	return nil, nil
+}
+
 type getMembersInClubRequest struct {
 	ClubId uuid.UUID `path:"clubId" minimum:"1"`
 }
@@ -184,37 +347,54 @@ type getMembersInClubResponse struct {
 }
 
 type membersInClub struct {
-	Id   uuid.UUID `json:"id"`
-	Role string    `json:"role"`
+	Id       uuid.UUID  `json:"id"`
+	UserID   *uuid.UUID `json:"userId,omitempty" doc:"Missing for guests"`
+	Name     string     `json:"name" doc:"The nickname, or else the user's display name"`
+	Nickname *string    `json:"nickname,omitempty"`
+	Guest    bool       `json:"guest"`
+	Role     string     `json:"role"`
 }
 
 func (h *Handler) GetMembersInClub(ctx context.Context, req *getMembersInClubRequest) (*getMembersInClubResponse, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
-	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
-	}
-
-	ok, err := h.authorization.IsMember(ctx, userID, req.ClubId)
+	members, err := h.member.GetMembersInClub(ctx, req.ClubId)
 	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
-	}
-	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to get members in this club")
+		h.l.Error("failed to get members", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get members, try again later")
 	}
 
-	members, err := h.member.GetMembersInClub(ctx, req.ClubId)
+	userIDs := make([]uuid.UUID, 0, len(members))
+	for _, m := range members {
+		if !m.IsGuest() {
+			userIDs = append(userIDs, *m.UserID)
+		}
+	}
+	users, err := h.user.GetUsers(ctx, userIDs)
 	if err != nil {
-		h.l.Error("failed to get members", "error", err)
+		h.l.Error("failed to get users", "error", err)
 		return nil, huma.Error500InternalServerError("failed to get members, try again later")
 	}
+	names := make(map[uuid.UUID]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.PublicName()
+	}
 
 	membersResponse := make([]membersInClub, len(members))
 	for i, m := range members {
+		var name string
+		if !m.IsGuest() {
+			name = names[*m.UserID]
+		}
+		if m.Nickname != nil {
+			name = *m.Nickname
+		}
+
 		membersResponse[i] = membersInClub{
-			Id:   m.ID,
-			Role: string(m.Role),
+			Id:       m.ID,
+			UserID:   m.UserID,
+			Name:     name,
+			Nickname: m.Nickname,
+			Guest:    m.IsGuest(),
+			Role:     string(m.Role),
 		}
 	}
 
@@ -230,25 +410,108 @@ type removeUserFromClubRequest struct {
 }
 
 func (h *Handler) RemoveMemberFromClub(ctx context.Context, req *removeUserFromClubRequest) (*struct{}, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
+	principal, ok := authentication.PrincipalFrom(ctx)
 	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
+		return nil, huma.Error401Unauthorized("not authenticated")
 	}
 
-	ok, err := h.authorization.IsAdmin(ctx, userID, req.MemberId)
+	m, err := h.member.GetMember(ctx, req.MemberId)
 	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
+		h.l.Error("failed to get member", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get member")
 	}
-	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to remove member from club")
+	if m.ClubID != req.ClubId {
+		return nil, huma.Error404NotFound("member not found in this club")
 	}
 
-	if err := h.member.DeleteMember(ctx, req.MemberId); err != nil {
+	if err := h.member.DeleteMember(ctx, principal.UserID, req.MemberId); err != nil {
+		switch {
+		case errors.Is(err, member.ErrRoleTooHigh):
+			return nil, huma.Error403Forbidden("you can't remove members above your own role")
+		case errors.Is(err, member.ErrLastOwner):
+			return nil, huma.Error409Conflict("the club must keep an owner, transfer ownership first")
+		}
 		h.l.Error("failed to remsove member from club", "error", err)
 		return nil, huma.Error500InternalServerError("failed to remove member from club, try again later")
 	}
 
 	return nil, nil
 }
+
+type leaveClubRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+}
+
+func (h *Handler) LeaveClub(ctx context.Context, req *leaveClubRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if err := h.member.LeaveClub(ctx, principal.UserID, req.ClubID); err != nil {
+		switch {
+		case errors.Is(err, member.ErrNotFound):
+			return nil, huma.Error404NotFound("you are not a member of this club")
+		case errors.Is(err, member.ErrLastOwner):
+			return nil, huma.Error409Conflict("transfer ownership before leaving the club")
+		}
+		h.l.Error("failed to leave club", "error", err)
+		return nil, huma.Error500InternalServerError("failed to leave club, try again later")
+	}
+
+	return nil, nil
+}
+
+type searchClubsRequest struct {
+	Name     string `query:"name" maxLength:"64"`
+	Location string `query:"location" maxLength:"100"`
+	Game     string `query:"game" maxLength:"50"`
+	Limit    int    `query:"limit" minimum:"1" maximum:"50" default:"20"`
+	Offset   int    `query:"offset" minimum:"0"`
+}
+
+type searchClubsResponse struct {
+	Body struct {
+		Clubs []searchClubsResponseClub `json:"clubs"`
+	}
+}
+
+type searchClubsResponseClub struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	Location   string    `json:"location"`
+	JoinPolicy string    `json:"joinPolicy"`
+	Games      []string  `json:"games"`
+}
+
+func (h *Handler) SearchClubs(ctx context.Context, req *searchClubsRequest) (*searchClubsResponse, error) {
+	filter := club.SearchFilter{
+		Name:     req.Name,
+		Location: req.Location,
+		Game:     req.Game,
+		Limit:    req.Limit,
+		Offset:   req.Offset,
+	}
+
+	clubs, err := h.club.SearchClubs(ctx, filter)
+	if err != nil {
+		h.l.Error("failed to search clubs", "error", err)
+		return nil, huma.Error500InternalServerError("failed to search clubs, try again later")
+	}
+
+	mappedClubs := make([]searchClubsResponseClub, len(clubs))
+	for i, c := range clubs {
+		mappedClubs[i] = searchClubsResponseClub{
+			ID:         c.ID,
+			Name:       c.Name,
+			Location:   c.Location,
+			JoinPolicy: string(c.JoinPolicy),
+			Games:      c.GameNames,
+		}
+	}
+
+	resp := &searchClubsResponse{}
+	resp.Body.Clubs = mappedClubs
+
+	return resp, nil
+}
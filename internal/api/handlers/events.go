@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/authentication"
+	"core/internal/event"
+	"core/internal/game"
+	"errors"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+type getClubEventsRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+}
+
+type getClubEventsResponse struct {
+	Body struct {
+		Events []getClubEventsResponseEvent `json:"events"`
+	}
+}
+
+type getClubEventsResponseEvent struct {
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	StartsAt time.Time `json:"startsAt"`
+}
+
+func (h *Handler) GetClubEvents(ctx context.Context, req *getClubEventsRequest) (*getClubEventsResponse, error) {
+	events, err := h.event.GetEvents(ctx, req.ClubID)
+	if err != nil {
+		h.l.Error("failed to get events", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get events, try again later")
+	}
+
+	mappedEvents := make([]getClubEventsResponseEvent, len(events))
+	for i, e := range events {
+		mappedEvents[i] = getClubEventsResponseEvent{
+			ID:       e.ID,
+			Name:     e.Name,
+			StartsAt: e.StartsAt,
+		}
+	}
+
+	resp := &getClubEventsResponse{}
+	resp.Body.Events = mappedEvents
+
+	return resp, nil
+}
+
+type postClubEventRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+	Body   struct {
+		Name     string    `json:"name" minLength:"1" maxLength:"50"`
+		StartsAt time.Time `json:"startsAt"`
+	}
+}
+
+type postClubEventResponse struct {
+	Body struct {
+		EventID uuid.UUID `json:"eventId"`
+	}
+}
+
+func (h *Handler) PostClubEvent(ctx context.Context, req *postClubEventRequest) (*postClubEventResponse, error) {
+	eventID, err := h.event.CreateEvent(ctx, req.ClubID, req.Body.Name, req.Body.StartsAt)
+	if err != nil {
+		h.l.Error("failed to create event", "error", err)
+		return nil, huma.Error500InternalServerError("failed to create event, try again later")
+	}
+
+	resp := &postClubEventResponse{}
+	resp.Body.EventID = eventID
+
+	return resp, nil
+}
+
+type deleteEventRequest struct {
+	EventID uuid.UUID `path:"eventId"`
+}
+
+func (h *Handler) DeleteEvent(ctx context.Context, req *deleteEventRequest) (*struct{}, error) {
+	if err := h.event.DeleteEvent(ctx, req.EventID); err != nil {
+		h.l.Error("failed to delete event", "error", err)
+		return nil, huma.Error500InternalServerError("failed to delete event")
+	}
+
+	return nil, nil
+}
+
+type postEventCheckInRequest struct {
+	EventID uuid.UUID `path:"eventId"`
+}
+
+func (h *Handler) PostEventCheckIn(ctx context.Context, req *postEventCheckInRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	e, err := h.event.GetEvent(ctx, req.EventID)
+	if err != nil {
+		if errors.Is(err, event.ErrNotFound) {
+			return nil, huma.Error404NotFound("event not found")
+		}
+		h.l.Error("failed to get event", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get event")
+	}
+
+	// Find the membership the user checks in with
+	memberships, err := h.member.GetUserMemberships(ctx, principal.UserID)
+	if err != nil {
+		h.l.Error("failed to get memberships", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get memberships")
+	}
+
+	memberID := uuid.Nil
+	for _, m := range memberships {
+		if m.ClubID == e.ClubID {
+			memberID = m.ID
+			break
+		}
+	}
+	if memberID == uuid.Nil {
+		return nil, huma.Error403Forbidden("user not authorized to check in to this event")
+	}
+
+	if err := h.event.CheckIn(ctx, req.EventID, memberID); err != nil {
+		if errors.Is(err, event.ErrAlreadyCheckedIn) {
+			return nil, huma.Error409Conflict("already checked in to this event")
+		}
+		h.l.Error("failed to check in", "error", err)
+		return nil, huma.Error500InternalServerError("failed to check in, try again later")
+	}
+
+	return nil, nil
+}
+
+type getEventAttendeesRequest struct {
+	EventID uuid.UUID `path:"eventId"`
+}
+
+type getEventAttendeesResponse struct {
+	Body struct {
+		Attendees []getEventAttendeesResponseAttendee `json:"attendees"`
+	}
+}
+
+type getEventAttendeesResponseAttendee struct {
+	MemberID    uuid.UUID `json:"memberId"`
+	CheckedInAt time.Time `json:"checkedInAt"`
+}
+
+func (h *Handler) GetEventAttendees(ctx context.Context, req *getEventAttendeesRequest) (*getEventAttendeesResponse, error) {
+	attendances, err := h.event.GetAttendees(ctx, req.EventID)
+	if err != nil {
+		h.l.Error("failed to get attendees", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get attendees")
+	}
+
+	mappedAttendees := make([]getEventAttendeesResponseAttendee, len(attendances))
+	for i, a := range attendances {
+		mappedAttendees[i] = getEventAttendeesResponseAttendee{
+			MemberID:    a.MemberID,
+			CheckedInAt: a.CheckedInAt,
+		}
+	}
+
+	resp := &getEventAttendeesResponse{}
+	resp.Body.Attendees = mappedAttendees
+
+	return resp, nil
+}
+
+type eventPlayer struct {
+	MemberID uuid.UUID `json:"memberId"`
+	Rating   float64   `json:"rating"`
+}
+
+func mapEventPlayers(players []event.Player) []eventPlayer {
+	mapped := make([]eventPlayer, len(players))
+	for i, p := range players {
+		mapped[i] = eventPlayer{
+			MemberID: p.MemberID,
+			Rating:   p.Rating,
+		}
+	}
+
+	return mapped
+}
+
+// checkEventGame makes sure the game is played in the club hosting the event.
+func (h *Handler) checkEventGame(ctx context.Context, eventID, gameID uuid.UUID) error {
+	e, err := h.event.GetEvent(ctx, eventID)
+	if err != nil {
+		if errors.Is(err, event.ErrNotFound) {
+			return huma.Error404NotFound("event not found")
+		}
+		h.l.Error("failed to get event", "error", err)
+		return huma.Error500InternalServerError("failed to get event")
+	}
+
+	g, err := h.game.GetGame(ctx, gameID)
+	if err != nil {
+		if errors.Is(err, game.ErrNotFound) {
+			return huma.Error404NotFound("game not found")
+		}
+		h.l.Error("failed to get game", "error", err)
+		return huma.Error500InternalServerError("failed to get game")
+	}
+	if g.ClubID != e.ClubID {
+		return huma.Error404NotFound("game not found")
+	}
+
+	return nil
+}
+
+type getEventPlayersRequest struct {
+	EventID uuid.UUID `path:"eventId"`
+	GameID  uuid.UUID `query:"gameId" required:"true"`
+}
+
+type getEventPlayersResponse struct {
+	Body struct {
+		Players []eventPlayer `json:"players" doc:"The checked in members, best rated first"`
+	}
+}
+
+func (h *Handler) GetEventPlayers(ctx context.Context, req *getEventPlayersRequest) (*getEventPlayersResponse, error) {
+	if err := h.checkEventGame(ctx, req.EventID, req.GameID); err != nil {
+		return nil, err
+	}
+
+	players, err := h.event.GetPlayers(ctx, req.EventID, req.GameID)
+	if err != nil {
+		h.l.Error("failed to get players", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get players")
+	}
+
+	resp := &getEventPlayersResponse{}
+	resp.Body.Players = mapEventPlayers(players)
+
+	return resp, nil
+}
+
+type getEventTeamsRequest struct {
+	EventID uuid.UUID `path:"eventId"`
+	GameID  uuid.UUID `query:"gameId" required:"true"`
+	Teams   int       `query:"teams" minimum:"2" maximum:"16" default:"2"`
+}
+
+type getEventTeamsResponse struct {
+	Body struct {
+		Teams [][]eventPlayer `json:"teams"`
+	}
+}
+
+func (h *Handler) GetEventTeams(ctx context.Context, req *getEventTeamsRequest) (*getEventTeamsResponse, error) {
+	if err := h.checkEventGame(ctx, req.EventID, req.GameID); err != nil {
+		return nil, err
+	}
+
+	teams, err := h.event.BalanceTeams(ctx, req.EventID, req.GameID, req.Teams)
+	if err != nil {
+		if errors.Is(err, event.ErrNotEnoughPlayers) {
+			return nil, huma.Error422UnprocessableEntity("not enough players checked in for that many teams")
+		}
+		h.l.Error("failed to balance teams", "error", err)
+		return nil, huma.Error500InternalServerError("failed to balance teams")
+	}
+
+	resp := &getEventTeamsResponse{}
+	resp.Body.Teams = make([][]eventPlayer, len(teams))
+	for i, team := range teams {
+		resp.Body.Teams[i] = mapEventPlayers(team)
+	}
+
+	return resp, nil
+}
+
+type getMemberAttendanceRequest struct {
+	MemberID uuid.UUID `path:"memberId"`
+}
+
+type getMemberAttendanceResponse struct {
+	Body struct {
+		Sessions      int `json:"sessions"`
+		Streak        int `json:"streak"`
+		LongestStreak int `json:"longestStreak"`
+	}
+}
+
+func (h *Handler) GetMemberAttendance(ctx context.Context, req *getMemberAttendanceRequest) (*getMemberAttendanceResponse, error) {
+	stat, err := h.event.GetAttendanceStatistic(ctx, req.MemberID)
+	if err != nil {
+		h.l.Error("failed to get attendance statistic", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get attendance statistic")
+	}
+
+	resp := &getMemberAttendanceResponse{}
+	resp.Body.Sessions = stat.Sessions
+	resp.Body.Streak = stat.Streak
+	resp.Body.LongestStreak = stat.LongestStreak
+
+	return resp, nil
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/activity"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+type feedRequestPage struct {
+	Cursor *uuid.UUID `query:"cursor" required:"false" doc:"The nextCursor of the previous page"`
+	Limit  int        `query:"limit" minimum:"1" maximum:"100" default:"20"`
+}
+
+type activityResponse struct {
+	ID        uuid.UUID     `json:"id"`
+	ClubID    uuid.UUID     `json:"clubId"`
+	Kind      activity.Kind `json:"kind"`
+	MemberID  *uuid.UUID    `json:"memberId,omitempty"`
+	GameID    *uuid.UUID    `json:"gameId,omitempty"`
+	SubjectID *uuid.UUID    `json:"subjectId,omitempty" doc:"What the activity is about, e.g. the recorded match"`
+	Data      activity.Data `json:"data"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+type feedResponse struct {
+	Body struct {
+		Activities []activityResponse `json:"activities"`
+		NextCursor *uuid.UUID         `json:"nextCursor,omitempty" doc:"Missing on the last page"`
+	}
+}
+
+type getClubFeedRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+	feedRequestPage
+}
+
+func (h *Handler) GetClubFeed(ctx context.Context, req *getClubFeedRequest) (*feedResponse, error) {
+	page, err := h.activity.GetClubFeed(ctx, req.ClubID, req.Cursor, req.Limit)
+	if err != nil {
+		h.l.Error("failed to get club feed", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get feed, try again later")
+	}
+
+	return mapFeed(page), nil
+}
+
+type getUserFeedRequest struct {
+	UserID uuid.UUID `path:"userId"`
+	feedRequestPage
+}
+
+// GetUserFeed combines the feeds of all the user's clubs.
+func (h *Handler) GetUserFeed(ctx context.Context, req *getUserFeedRequest) (*feedResponse, error) {
+	page, err := h.activity.GetUserFeed(ctx, req.UserID, req.Cursor, req.Limit)
+	if err != nil {
+		h.l.Error("failed to get user feed", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get feed, try again later")
+	}
+
+	return mapFeed(page), nil
+}
+
+func mapFeed(page *activity.Page) *feedResponse {
+	activities := make([]activityResponse, len(page.Activities))
+	for i, a := range page.Activities {
+		activities[i] = activityResponse{
+			ID:        a.ID,
+			ClubID:    a.ClubID,
+			Kind:      a.Kind,
+			MemberID:  a.MemberID,
+			GameID:    a.GameID,
+			SubjectID: a.SubjectID,
+			Data:      a.Data,
+			CreatedAt: a.CreatedAt,
+		}
+	}
+
+	resp := &feedResponse{}
+	resp.Body.Activities = activities
+	resp.Body.NextCursor = page.NextCursor
+
+	return resp
+}
@@ -2,7 +2,11 @@ package handlers
 
 import (
 	"context"
+	"core/internal/authentication"
 	"core/internal/game"
+	"errors"
+	"net/http"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/google/uuid"
@@ -24,21 +28,6 @@ type getClubGamesResponseGame struct {
 }
 
 func (h *Handler) GetClubGames(ctx context.Context, req *getClubGamesRequest) (*getClubGamesResponse, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
-	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
-	}
-
-	ok, err := h.authorization.IsMember(ctx, userID, req.ClubId)
-	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
-	}
-	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to get matches in this club")
-	}
-
 	games, err := h.club.GetGames(ctx, req.ClubId)
 	if err != nil {
 		h.l.Error("failed to get games", "error", err)
@@ -74,22 +63,12 @@ type postClubGameResponse struct {
 }
 
 func (h *Handler) PostClubGame(ctx context.Context, req *postClubGameRequest) (*postClubGameResponse, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
+	principal, ok := authentication.PrincipalFrom(ctx)
 	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
+		return nil, huma.Error401Unauthorized("not authenticated")
 	}
 
-	ok, err := h.authorization.IsAdmin(ctx, userID, req.ClubID)
-	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
-	}
-	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to create games in this club")
-	}
-
-	gameID, err := h.game.CreateGame(ctx, req.ClubID, req.Body.Name)
+	gameID, err := h.game.CreateGame(ctx, principal.UserID, req.ClubID, req.Body.Name)
 	if err != nil {
 		h.l.Error("failed to create game", "error", err)
 		return nil, huma.Error500InternalServerError("failed to create game, try again later")
@@ -118,27 +97,6 @@ type getGameModesResponseMode struct {
 }
 
 func (h *Handler) GetGameModes(ctx context.Context, req *getGameModesRequest) (*getGameModesResponse, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
-	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
-	}
-
-	game, err := h.game.GetGame(ctx, req.GameID)
-	if err != nil {
-		h.l.Error("failed to get game", "error", err)
-		return nil, huma.Error500InternalServerError("failed to get game")
-	}
-
-	ok, err = h.authorization.IsMember(ctx, userID, game.ClubID)
-	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
-	}
-	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to view game modes")
-	}
-
 	modes, err := h.game.GetGameModes(ctx, req.GameID)
 	if err != nil {
 		h.l.Error("failed to get game modes", "error", err)
@@ -167,27 +125,6 @@ type postGameModeRequest struct {
 }
 
 func (h *Handler) PostGameMode(ctx context.Context, req *postGameModeRequest) (*struct{}, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
-	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
-	}
-
-	g, err := h.game.GetGame(ctx, req.GameID)
-	if err != nil {
-		h.l.Error("failed to get game", "error", err)
-		return nil, huma.Error500InternalServerError("failed to get game")
-	}
-
-	ok, err = h.authorization.IsAdmin(ctx, userID, g.ClubID)
-	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
-	}
-	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to add game modes")
-	}
-
 	var mode game.Mode
 	switch req.Body.Mode {
 	case "FREE_FOR_ALL":
@@ -214,27 +151,6 @@ type deleteGameModeRequest struct {
 }
 
 func (h *Handler) DeleteGameMode(ctx context.Context, req *deleteGameModeRequest) (*struct{}, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
-	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
-	}
-
-	g, err := h.game.GetGame(ctx, req.GameID)
-	if err != nil {
-		h.l.Error("failed to get game", "error", err)
-		return nil, huma.Error500InternalServerError("failed to get game")
-	}
-
-	ok, err = h.authorization.IsAdmin(ctx, userID, g.ClubID)
-	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
-	}
-	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to remove game modes")
-	}
-
 	var mode game.Mode
 	switch req.Mode {
 	case "FREE_FOR_ALL":
@@ -259,34 +175,64 @@ type deleteGameRequest struct {
 	GameID uuid.UUID `path:"gameId"`
 }
 
-func (h *Handler) DeleteGame(ctx context.Context, req *deleteGameRequest) (*struct{}, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
-	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
+type deleteGameResponse struct {
+	Status int
+	Body   struct {
+		PurgeAt time.Time `json:"purgeAt" doc:"Until then the game can be restored"`
 	}
+}
 
-	g, err := h.game.GetGame(ctx, req.GameID)
-	if err != nil {
-		h.l.Error("failed to get game", "error", err)
-		return nil, huma.Error500InternalServerError("failed to get game")
+// DeleteGame hides the game and its matches, which are purged after the
+// retention period.
+func (h *Handler) DeleteGame(ctx context.Context, req *deleteGameRequest) (*deleteGameResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
 	}
 
-	ok, err = h.authorization.IsAdmin(ctx, userID, g.ClubID)
+	purgeAt, err := h.game.DeleteGame(ctx, principal.UserID, req.GameID)
 	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
+		if errors.Is(err, game.ErrNotFound) {
+			return nil, huma.Error404NotFound("game not found")
+		}
+		h.l.Error("failed to delete game", "error", err)
+		return nil, huma.Error500InternalServerError("failed to delete game")
 	}
+
+	resp := &deleteGameResponse{Status: http.StatusAccepted}
+	resp.Body.PurgeAt = purgeAt
+
+	return resp, nil
+}
+
+type restoreGameRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+	GameID uuid.UUID `path:"gameId"`
+}
+
+func (h *Handler) RestoreGame(ctx context.Context, req *restoreGameRequest) (*putGameResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
 	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to delete games in this club")
+		return nil, huma.Error401Unauthorized("not authenticated")
 	}
 
-	if err := h.game.DeleteGame(ctx, req.GameID); err != nil {
-		h.l.Error("failed to delete game", "error", err)
-		return nil, huma.Error500InternalServerError("failed to delete game")
+	g, err := h.game.RestoreGame(ctx, principal.UserID, req.ClubID, req.GameID)
+	if err != nil {
+		switch {
+		case errors.Is(err, game.ErrNotFound):
+			return nil, huma.Error404NotFound("no deleted game found")
+		case errors.Is(err, game.ErrDuplicateName):
+			return nil, huma.Error409Conflict("another game of the club has the same name by now")
+		}
+		h.l.Error("failed to restore game", "error", err)
+		return nil, huma.Error500InternalServerError("failed to restore game")
 	}
 
-	return nil, nil
+	resp := &putGameResponse{}
+	resp.Body.ID = g.ID
+	resp.Body.Name = g.Name
+
+	return resp, nil
 }
 
 type putGameRequest struct {
@@ -304,10 +250,9 @@ type putGameResponse struct {
 }
 
 func (h *Handler) PutGame(ctx context.Context, req *putGameRequest) (*putGameResponse, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
+	principal, ok := authentication.PrincipalFrom(ctx)
 	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
+		return nil, huma.Error401Unauthorized("not authenticated")
 	}
 
 	g, err := h.game.GetGame(ctx, req.GameID)
@@ -316,17 +261,8 @@ func (h *Handler) PutGame(ctx context.Context, req *putGameRequest) (*putGameRes
 		return nil, huma.Error500InternalServerError("failed to get game")
 	}
 
-	ok, err = h.authorization.IsAdmin(ctx, userID, g.ClubID)
-	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
-	}
-	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to update games in this club")
-	}
-
 	g.Name = req.Body.Name
-	if err := h.game.UpdateGame(ctx, g); err != nil {
+	if err := h.game.UpdateGame(ctx, principal.UserID, g); err != nil {
 		h.l.Error("failed to update game", "error", err)
 		return nil, huma.Error500InternalServerError("failed to update game")
 	}
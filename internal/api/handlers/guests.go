@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/authentication"
+	"core/internal/member"
+	"errors"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+type postClubGuestRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+	Body   struct {
+		Name string `json:"name" minLength:"1" maxLength:"50"`
+	}
+}
+
+type postClubGuestResponse struct {
+	Body struct {
+		MemberID uuid.UUID `json:"memberId"`
+		Name     string    `json:"name"`
+	}
+}
+
+// PostClubGuest adds a player without an account, who can be recorded in
+// matches like any member.
+func (h *Handler) PostClubGuest(ctx context.Context, req *postClubGuestRequest) (*postClubGuestResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	guest, err := h.member.CreateGuest(ctx, principal.UserID, req.ClubID, req.Body.Name)
+	if err != nil {
+		h.l.Error("failed to create guest", "error", err)
+		return nil, huma.Error500InternalServerError("failed to add guest, try again later")
+	}
+
+	resp := &postClubGuestResponse{}
+	resp.Body.MemberID = guest.ID
+	resp.Body.Name = req.Body.Name
+
+	return resp, nil
+}
+
+type claimClubGuestRequest struct {
+	ClubID  uuid.UUID `path:"clubId"`
+	GuestID uuid.UUID `path:"guestId"`
+	Body    struct {
+		MemberID uuid.UUID `json:"memberId" doc:"The member who played as the guest"`
+	}
+}
+
+// ClaimClubGuest merges a guest into the member who played as them once they
+// signed up, carrying the guest's history over.
+func (h *Handler) ClaimClubGuest(ctx context.Context, req *claimClubGuestRequest) (*mergeResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	guest, err := h.member.GetMember(ctx, req.GuestID)
+	if err != nil {
+		if errors.Is(err, member.ErrNotFound) {
+			return nil, huma.Error404NotFound("guest not found in this club")
+		}
+		h.l.Error("failed to get guest", "error", err)
+		return nil, huma.Error500InternalServerError("failed to claim guest, try again later")
+	}
+	if guest.ClubID != req.ClubID {
+		return nil, huma.Error404NotFound("guest not found in this club")
+	}
+
+	result, err := h.member.ClaimGuest(ctx, req.GuestID, req.Body.MemberID, principal.UserID)
+	if err != nil {
+		return nil, h.mergeError(err)
+	}
+
+	return mapMergeResult(result, false), nil
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"core/internal/activity"
+	"core/internal/audit"
 	"core/internal/authentication"
 	"core/internal/authorization"
 	"core/internal/club"
+	"core/internal/event"
 	"core/internal/game"
 	"core/internal/match"
 	"core/internal/member"
@@ -33,6 +36,9 @@ type Handler struct {
 	game           game.Service
 	subscription   subscription.Service
 	statistic      statistic.Service
+	event          event.Service
+	audit          audit.Service
+	activity       activity.Service
 }
 
 func NewHandler(
@@ -48,6 +54,9 @@ func NewHandler(
 	game game.Service,
 	subscription subscription.Service,
 	statistic statistic.Service,
+	event event.Service,
+	audit audit.Service,
+	activity activity.Service,
 ) *Handler {
 	return &Handler{
 		l:              l,
@@ -62,5 +71,8 @@ func NewHandler(
 		game:           game,
 		subscription:   subscription,
 		statistic:      statistic,
+		event:          event,
+		audit:          audit,
+		activity:       activity,
 	}
 }
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/authentication"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+// oidcStateCookie binds an OIDC flow to the browser that started it, so a
+// login started elsewhere can't be completed in the user's browser.
+const oidcStateCookie = "oidc_state"
+
+type getProvidersResponse struct {
+	Body struct {
+		Providers []string `json:"providers"`
+	}
+}
+
+func (h *Handler) GetProviders(ctx context.Context, req *struct{}) (*getProvidersResponse, error) {
+	resp := &getProvidersResponse{}
+	resp.Body.Providers = h.authentication.Providers()
+
+	return resp, nil
+}
+
+type startOIDCRequest struct {
+	Provider string `path:"provider"`
+}
+
+type startOIDCResponse struct {
+	SetCookie []http.Cookie `header:"Set-Cookie"`
+	Body      struct {
+		URL string `json:"url"`
+	}
+}
+
+// StartOIDCLogin returns the provider URL the client should navigate to.
+func (h *Handler) StartOIDCLogin(ctx context.Context, req *startOIDCRequest) (*startOIDCResponse, error) {
+	return h.startOIDC(ctx, req.Provider, uuid.Nil)
+}
+
+type oidcCallbackRequest struct {
+	Provider   string `path:"provider"`
+	StateToken string `cookie:"oidc_state" required:"true"`
+	Body       struct {
+		State string `json:"state" minLength:"1"`
+		Code  string `json:"code" minLength:"1"`
+	}
+}
+
+// OIDCLoginCallback completes the login with the code the provider sent the
+// client back with.
+func (h *Handler) OIDCLoginCallback(ctx context.Context, req *oidcCallbackRequest) (*loginResponse, error) {
+	if !sameState(req) {
+		return nil, huma.Error400BadRequest("login was started in another browser, try again")
+	}
+
+	result, err := h.authentication.OIDCLogin(ctx, req.Provider, req.Body.State, req.Body.Code, authentication.ClientFrom(ctx))
+	if err != nil {
+		return nil, h.oidcError(err, "failed to login")
+	}
+
+	resp, err := h.loginResponse(result)
+	if err != nil {
+		return nil, err
+	}
+	resp.SetCookie = append(resp.SetCookie, expiredCookie(oidcStateCookie, true))
+
+	return resp, nil
+}
+
+type getIdentitiesResponse struct {
+	Body struct {
+		Identities []getIdentitiesResponseIdentity `json:"identities"`
+	}
+}
+
+type getIdentitiesResponseIdentity struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (h *Handler) GetIdentities(ctx context.Context, req *struct{}) (*getIdentitiesResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	identities, err := h.authentication.GetIdentities(ctx, principal.UserID)
+	if err != nil {
+		h.l.Error("failed to get identities", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get linked providers, try again later")
+	}
+
+	mappedIdentities := make([]getIdentitiesResponseIdentity, len(identities))
+	for i, identity := range identities {
+		mappedIdentities[i] = getIdentitiesResponseIdentity{
+			Provider:  identity.Provider,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		}
+	}
+
+	resp := &getIdentitiesResponse{}
+	resp.Body.Identities = mappedIdentities
+
+	return resp, nil
+}
+
+// StartLinkIdentity returns the provider URL the client should navigate to.
+func (h *Handler) StartLinkIdentity(ctx context.Context, req *startOIDCRequest) (*startOIDCResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	return h.startOIDC(ctx, req.Provider, principal.UserID)
+}
+
+type linkIdentityResponse struct {
+	SetCookie []http.Cookie `header:"Set-Cookie"`
+}
+
+func (h *Handler) LinkIdentityCallback(ctx context.Context, req *oidcCallbackRequest) (*linkIdentityResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if !sameState(req) {
+		return nil, huma.Error400BadRequest("linking was started in another browser, try again")
+	}
+
+	if err := h.authentication.LinkIdentity(ctx, principal.UserID, req.Provider, req.Body.State, req.Body.Code); err != nil {
+		return nil, h.oidcError(err, "failed to link provider")
+	}
+
+	resp := &linkIdentityResponse{SetCookie: []http.Cookie{expiredCookie(oidcStateCookie, true)}}
+
+	return resp, nil
+}
+
+type unlinkIdentityRequest struct {
+	Provider string `path:"provider"`
+}
+
+func (h *Handler) UnlinkIdentity(ctx context.Context, req *unlinkIdentityRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if err := h.authentication.UnlinkIdentity(ctx, principal.UserID, req.Provider); err != nil {
+		switch {
+		case errors.Is(err, authentication.ErrIdentityNotFound):
+			return nil, huma.Error404NotFound("provider is not linked")
+		case errors.Is(err, authentication.ErrLastLoginMethod):
+			return nil, huma.Error409Conflict("set a password before unlinking your only provider")
+		}
+		h.l.Error("failed to unlink identity", "error", err)
+		return nil, huma.Error500InternalServerError("failed to unlink provider, try again later")
+	}
+
+	return nil, nil
+}
+
+func (h *Handler) startOIDC(ctx context.Context, provider string, userID uuid.UUID) (*startOIDCResponse, error) {
+	authURL, state, err := h.authentication.StartOIDC(ctx, provider, userID)
+	if err != nil {
+		if errors.Is(err, authentication.ErrUnknownProvider) {
+			return nil, huma.Error404NotFound("provider not found")
+		}
+		h.l.Error("failed to start oidc flow", "provider", provider, "error", err)
+		return nil, huma.Error502BadGateway("failed to reach provider, try again later")
+	}
+
+	resp := &startOIDCResponse{
+		SetCookie: []http.Cookie{{
+			Name:     oidcStateCookie,
+			Value:    state,
+			Path:     "/",
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+			MaxAge:   int(authentication.OIDCFlowExpiry.Seconds()),
+		}},
+	}
+	resp.Body.URL = authURL
+
+	return resp, nil
+}
+
+func sameState(req *oidcCallbackRequest) bool {
+	return subtle.ConstantTimeCompare([]byte(req.StateToken), []byte(req.Body.State)) == 1
+}
+
+func (h *Handler) oidcError(err error, msg string) error {
+	switch {
+	case errors.Is(err, authentication.ErrUnknownProvider):
+		return huma.Error404NotFound("provider not found")
+	case errors.Is(err, authentication.ErrInvalidToken):
+		return huma.Error400BadRequest("login with the provider failed or expired, try again")
+	case errors.Is(err, authentication.ErrEmailNotVerified):
+		return huma.Error400BadRequest("the provider has not verified your email address")
+	case errors.Is(err, authentication.ErrIdentityConflict):
+		return huma.Error409Conflict("log in with your password to link this provider")
+	case errors.Is(err, authentication.ErrIdentityLinked):
+		return huma.Error409Conflict("this provider account is already linked")
+	}
+
+	h.l.Error(msg, "error", err)
+	return huma.Error500InternalServerError(msg + ", try again later")
+}
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/authentication"
+	"core/internal/authorization"
+	"core/internal/club"
+	"core/internal/member"
+	"errors"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+type postClubInviteRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+	Body   struct {
+		UserID uuid.UUID   `json:"userId"`
+		Role   member.Role `json:"role,omitempty" enum:"observer,member,manager"`
+	}
+}
+
+func (h *Handler) PostClubInvite(ctx context.Context, req *postClubInviteRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if err := h.club.CreateInvite(ctx, req.ClubID, req.Body.UserID, principal.UserID, club.IniatorClub, req.Body.Role); err != nil {
+		if errors.Is(err, member.ErrRoleTooHigh) {
+			return nil, huma.Error403Forbidden("you can't invite to roles above your own")
+		}
+		if errors.Is(err, club.ErrAlreadyMember) || errors.Is(err, club.ErrInviteExists) {
+			return nil, huma.Error409Conflict(err.Error())
+		}
+		h.l.Error("failed to create invite", "error", err)
+		return nil, huma.Error500InternalServerError("failed to create invite, try again later")
+	}
+
+	return nil, nil
+}
+
+type getClubInvitesRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+}
+
+type getInvitesResponse struct {
+	Body struct {
+		Invites []getInvitesResponseInvite `json:"invites"`
+	}
+}
+
+type getInvitesResponseInvite struct {
+	ID        uuid.UUID `json:"id"`
+	ClubID    uuid.UUID `json:"clubId"`
+	UserID    uuid.UUID `json:"userId"`
+	Role      string    `json:"role"`
+	Initiator string    `json:"initiator" enum:"CLUB,USER"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func mapInvites(invites []club.Invite) *getInvitesResponse {
+	mappedInvites := make([]getInvitesResponseInvite, len(invites))
+	for i, inv := range invites {
+		initiator := "CLUB"
+		if inv.Initiator == club.InitiatorUser {
+			initiator = "USER"
+		}
+
+		mappedInvites[i] = getInvitesResponseInvite{
+			ID:        inv.ID,
+			ClubID:    inv.ClubId,
+			UserID:    inv.UserId,
+			Role:      string(inv.Role),
+			Initiator: initiator,
+			CreatedAt: inv.CreatedAt,
+		}
+	}
+
+	resp := &getInvitesResponse{}
+	resp.Body.Invites = mappedInvites
+
+	return resp
+}
+
+func (h *Handler) GetClubInvites(ctx context.Context, req *getClubInvitesRequest) (*getInvitesResponse, error) {
+	invites, err := h.club.GetPendingInvites(ctx, req.ClubID)
+	if err != nil {
+		h.l.Error("failed to get invites", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get invites, try again later")
+	}
+
+	return mapInvites(invites), nil
+}
+
+type postClubJoinRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+}
+
+type postClubJoinResponse struct {
+	Body struct {
+		Joined bool `json:"joined" doc:"False if the join request awaits approval"`
+	}
+}
+
+func (h *Handler) PostClubJoin(ctx context.Context, req *postClubJoinRequest) (*postClubJoinResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	joined, err := h.club.JoinClub(ctx, req.ClubID, principal.UserID)
+	if err != nil {
+		if errors.Is(err, club.ErrJoinNotAllowed) {
+			return nil, huma.Error403Forbidden(err.Error())
+		}
+		if errors.Is(err, club.ErrAlreadyMember) || errors.Is(err, club.ErrInviteExists) {
+			return nil, huma.Error409Conflict(err.Error())
+		}
+		if errors.Is(err, club.ErrNotFound) {
+			return nil, huma.Error404NotFound("club not found")
+		}
+		h.l.Error("failed to join club", "error", err)
+		return nil, huma.Error500InternalServerError("failed to join club, try again later")
+	}
+
+	resp := &postClubJoinResponse{}
+	resp.Body.Joined = joined
+
+	return resp, nil
+}
+
+type getUserInvitesRequest struct {
+	UserID uuid.UUID `path:"userId"`
+}
+
+func (h *Handler) GetUserInvites(ctx context.Context, req *getUserInvitesRequest) (*getInvitesResponse, error) {
+	invites, err := h.club.GetUserInvites(ctx, req.UserID)
+	if err != nil {
+		h.l.Error("failed to get invites", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get invites, try again later")
+	}
+
+	return mapInvites(invites), nil
+}
+
+type inviteRequest struct {
+	InviteID uuid.UUID `path:"inviteId"`
+}
+
+// requireInviteResponder returns authorization.ErrForbidden unless the user is
+// the party that did not initiate the invite, i.e. the one allowed to accept it.
+func (h *Handler) requireInviteResponder(ctx context.Context, userID uuid.UUID, invite *club.Invite) error {
+	if invite.Initiator == club.InitiatorUser {
+		return h.authorization.Require(ctx, userID, invite.ClubId, authorization.PermissionManageInvites)
+	}
+
+	if invite.UserId != userID {
+		return authorization.ErrForbidden
+	}
+
+	return nil
+}
+
+func (h *Handler) AcceptInvite(ctx context.Context, req *inviteRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	invite, err := h.club.GetInvite(ctx, req.InviteID)
+	if err != nil {
+		if errors.Is(err, club.ErrNotFound) {
+			return nil, huma.Error404NotFound("invite not found")
+		}
+		h.l.Error("failed to get invite", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get invite")
+	}
+
+	if err := h.requireInviteResponder(ctx, principal.UserID, invite); err != nil {
+		if errors.Is(err, authorization.ErrForbidden) {
+			return nil, huma.Error403Forbidden("user not authorized to accept this invite")
+		}
+		h.l.Error("failed to check authorization", "error", err)
+		return nil, huma.Error500InternalServerError("failed to check authorization")
+	}
+
+	if err := h.club.AcceptInvite(ctx, principal.UserID, req.InviteID); err != nil {
+		if errors.Is(err, club.ErrAlreadyMember) {
+			return nil, huma.Error409Conflict(err.Error())
+		}
+		if errors.Is(err, club.ErrNotFound) {
+			return nil, huma.Error404NotFound("invite not found")
+		}
+		h.l.Error("failed to accept invite", "error", err)
+		return nil, huma.Error500InternalServerError("failed to accept invite, try again later")
+	}
+
+	return nil, nil
+}
+
+func (h *Handler) RejectInvite(ctx context.Context, req *inviteRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	invite, err := h.club.GetInvite(ctx, req.InviteID)
+	if err != nil {
+		if errors.Is(err, club.ErrNotFound) {
+			return nil, huma.Error404NotFound("invite not found")
+		}
+		h.l.Error("failed to get invite", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get invite")
+	}
+
+	// Either side may decline or withdraw an invite
+	if invite.UserId != principal.UserID {
+		if err := h.authorization.Require(ctx, principal.UserID, invite.ClubId, authorization.PermissionManageInvites); err != nil {
+			if errors.Is(err, authorization.ErrForbidden) {
+				return nil, huma.Error403Forbidden("user not authorized to reject this invite")
+			}
+			h.l.Error("failed to check authorization", "error", err)
+			return nil, huma.Error500InternalServerError("failed to check authorization")
+		}
+	}
+
+	if err := h.club.RejectInvite(ctx, req.InviteID); err != nil {
+		h.l.Error("failed to reject invite", "error", err)
+		return nil, huma.Error500InternalServerError("failed to reject invite, try again later")
+	}
+
+	return nil, nil
+}
+
+type postClubInviteLinkRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+	Body   struct {
+		Role      member.Role `json:"role,omitempty" enum:"observer,member,manager"`
+		ExpiresAt *time.Time  `json:"expiresAt,omitempty"`
+		MaxUses   *int        `json:"maxUses,omitempty" minimum:"1"`
+	}
+}
+
+type inviteLinkResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	Code      string     `json:"code"`
+	Role      string     `json:"role"`
+	MaxUses   *int       `json:"maxUses,omitempty"`
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+func mapInviteLink(link club.InviteLink) inviteLinkResponse {
+	return inviteLinkResponse{
+		ID:        link.ID,
+		Code:      link.Code,
+		Role:      string(link.Role),
+		MaxUses:   link.MaxUses,
+		Uses:      link.Uses,
+		ExpiresAt: link.ExpiresAt,
+	}
+}
+
+type postClubInviteLinkResponse struct {
+	Body inviteLinkResponse
+}
+
+func (h *Handler) PostClubInviteLink(ctx context.Context, req *postClubInviteLinkRequest) (*postClubInviteLinkResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	link, err := h.club.CreateInviteLink(ctx, req.ClubID, principal.UserID, req.Body.Role, req.Body.ExpiresAt, req.Body.MaxUses)
+	if err != nil {
+		if errors.Is(err, club.ErrInvalidInviteExpiry) || errors.Is(err, club.ErrInvalidInviteMaxUses) {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		if errors.Is(err, member.ErrRoleTooHigh) {
+			return nil, huma.Error403Forbidden("you can't invite to roles above your own")
+		}
+		h.l.Error("failed to create invite link", "error", err)
+		return nil, huma.Error500InternalServerError("failed to create invite link, try again later")
+	}
+
+	resp := &postClubInviteLinkResponse{}
+	resp.Body = mapInviteLink(*link)
+
+	return resp, nil
+}
+
+type getClubInviteLinksRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+}
+
+type getClubInviteLinksResponse struct {
+	Body struct {
+		Links []inviteLinkResponse `json:"links"`
+	}
+}
+
+func (h *Handler) GetClubInviteLinks(ctx context.Context, req *getClubInviteLinksRequest) (*getClubInviteLinksResponse, error) {
+	links, err := h.club.GetInviteLinks(ctx, req.ClubID)
+	if err != nil {
+		h.l.Error("failed to get invite links", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get invite links, try again later")
+	}
+
+	mappedLinks := make([]inviteLinkResponse, len(links))
+	for i, l := range links {
+		mappedLinks[i] = mapInviteLink(l)
+	}
+
+	resp := &getClubInviteLinksResponse{}
+	resp.Body.Links = mappedLinks
+
+	return resp, nil
+}
+
+type deleteInviteLinkRequest struct {
+	LinkID uuid.UUID `path:"linkId"`
+}
+
+func (h *Handler) DeleteInviteLink(ctx context.Context, req *deleteInviteLinkRequest) (*struct{}, error) {
+	if err := h.club.DeleteInviteLink(ctx, req.LinkID); err != nil {
+		h.l.Error("failed to delete invite link", "error", err)
+		return nil, huma.Error500InternalServerError("failed to delete invite link, try again later")
+	}
+
+	return nil, nil
+}
+
+type redeemInviteLinkRequest struct {
+	Code string `path:"code" minLength:"1" maxLength:"64"`
+}
+
+type redeemInviteLinkResponse struct {
+	Body struct {
+		ClubID uuid.UUID `json:"clubId"`
+	}
+}
+
+func (h *Handler) RedeemInviteLink(ctx context.Context, req *redeemInviteLinkRequest) (*redeemInviteLinkResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	clubID, err := h.club.RedeemInviteLink(ctx, req.Code, principal.UserID)
+	if err != nil {
+		if errors.Is(err, club.ErrInviteLinkInvalid) {
+			return nil, huma.Error410Gone(err.Error())
+		}
+		if errors.Is(err, club.ErrAlreadyMember) {
+			return nil, huma.Error409Conflict(err.Error())
+		}
+		h.l.Error("failed to redeem invite link", "error", err)
+		return nil, huma.Error500InternalServerError("failed to redeem invite link, try again later")
+	}
+
+	resp := &redeemInviteLinkResponse{}
+	resp.Body.ClubID = clubID
+
+	return resp, nil
+}
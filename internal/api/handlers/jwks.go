@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/authentication"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type getJWKSResponse struct {
+	CacheControl string `header:"Cache-Control"`
+	Body         struct {
+		Keys []authentication.JWK `json:"keys"`
+	}
+}
+
+// GetJWKS publishes the public keys verifying MatchAlly tokens, so other
+// services can verify them without sharing a secret.
+func (h *Handler) GetJWKS(ctx context.Context, req *struct{}) (*getJWKSResponse, error) {
+	keys, err := h.authentication.GetPublicKeys(ctx)
+	if err != nil {
+		h.l.Error("failed to get public keys", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get public keys")
+	}
+
+	resp := &getJWKSResponse{}
+	resp.CacheControl = "public, max-age=300"
+	resp.Body.Keys = keys
+
+	return resp, nil
+}
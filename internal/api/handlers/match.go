@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"core/internal/authentication"
 	"core/internal/game"
 	"time"
 
@@ -10,8 +11,8 @@ import (
 )
 
 type postClubMatchRequest struct {
-	Body struct {
-		ClubID uuid.UUID                  `json:"clubId"`
+	ClubID uuid.UUID `path:"clubId"`
+	Body   struct {
 		GameID uuid.UUID                  `json:"gameId"`
 		Mode   string                     `json:"mode" enum:"FREE_FOR_ALL,TEAM,COOP"`
 		Teams  []postClubMatchRequestTeam `json:"teams" minItems:"1"`
@@ -30,19 +31,9 @@ type postClubMatchResponse struct {
 }
 
 func (h *Handler) PostClubMatch(ctx context.Context, req *postClubMatchRequest) (*postClubMatchResponse, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
+	principal, ok := authentication.PrincipalFrom(ctx)
 	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
-	}
-
-	ok, err := h.authorization.IsMember(ctx, userID, req.Body.ClubID)
-	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
-	}
-	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to get matches in this club")
+		return nil, huma.Error401Unauthorized("not authenticated")
 	}
 
 	tempTeams := make([][]uuid.UUID, len(req.Body.Teams))
@@ -50,7 +41,7 @@ func (h *Handler) PostClubMatch(ctx context.Context, req *postClubMatchRequest)
 		tempTeams[i] = t.Members
 	}
 
-	teams, err := h.match.GetOrCreateTeams(ctx, req.Body.ClubID, tempTeams)
+	teams, err := h.match.GetOrCreateTeams(ctx, req.ClubID, tempTeams)
 	if err != nil {
 		h.l.Error("failed to get or create teams", "error", err)
 		return nil, huma.Error500InternalServerError("failed to get or create teams, try again later")
@@ -68,7 +59,7 @@ func (h *Handler) PostClubMatch(ctx context.Context, req *postClubMatchRequest)
 		return nil, huma.Error400BadRequest("invalid game mode")
 	}
 
-	matchID, err := h.match.CreateMatch(ctx, req.Body.ClubID, req.Body.GameID, teams, req.Body.Sets, mode)
+	matchID, err := h.match.CreateMatch(ctx, principal.UserID, req.ClubID, req.Body.GameID, teams, req.Body.Sets, mode)
 	if err != nil {
 		h.l.Error("failed to create match", "error", err)
 		return nil, huma.Error500InternalServerError("failed to create match, try again later")
@@ -111,21 +102,6 @@ type getClubMatchesResponseTeamMember struct {
 }
 
 func (h *Handler) GetClubMatches(ctx context.Context, req *getClubMatchesRequest) (*getClubMatchesResponse, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
-	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
-	}
-
-	ok, err := h.authorization.IsMember(ctx, userID, req.ClubID)
-	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
-	}
-	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to get matches in this club")
-	}
-
 	var gameID *uuid.UUID
 	if req.GameID != nil {
 		gameID = req.GameID
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/authentication"
+	"core/internal/member"
+	"errors"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+type mergeResponse struct {
+	Body struct {
+		Preview            bool        `json:"preview"`
+		Matches            int         `json:"matches" doc:"Matches moved over to the remaining member"`
+		CollapsedTeams     int         `json:"collapsedTeams" doc:"Teams dropped for having the same members as another team after the merge"`
+		CombinedStatistics int         `json:"combinedStatistics" doc:"Games both members had statistics for"`
+		Games              []uuid.UUID `json:"games" doc:"Games whose ratings are recomputed"`
+	}
+}
+
+type mergeClubMembersRequest struct {
+	ClubID   uuid.UUID `path:"clubId"`
+	MemberID uuid.UUID `path:"memberId"`
+	Preview  bool      `query:"preview" doc:"Report what the merge would do without merging"`
+	Body     struct {
+		IntoMemberID uuid.UUID `json:"intoMemberId" doc:"The member to keep"`
+	}
+}
+
+// MergeClubMembers merges a duplicate member, e.g. of someone who signed up
+// twice, into the member to keep.
+func (h *Handler) MergeClubMembers(ctx context.Context, req *mergeClubMembersRequest) (*mergeResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	from, err := h.member.GetMember(ctx, req.MemberID)
+	if err != nil {
+		if errors.Is(err, member.ErrNotFound) {
+			return nil, huma.Error404NotFound("member not found in this club")
+		}
+		h.l.Error("failed to get member", "error", err)
+		return nil, huma.Error500InternalServerError("failed to merge members, try again later")
+	}
+	if from.ClubID != req.ClubID {
+		return nil, huma.Error404NotFound("member not found in this club")
+	}
+
+	var result *member.MergeResult
+	if req.Preview {
+		result, err = h.member.PreviewMerge(ctx, req.MemberID, req.Body.IntoMemberID, principal.UserID)
+	} else {
+		result, err = h.member.MergeMembers(ctx, req.MemberID, req.Body.IntoMemberID, principal.UserID)
+	}
+	if err != nil {
+		return nil, h.mergeError(err)
+	}
+
+	return mapMergeResult(result, req.Preview), nil
+}
+
+// mergeError maps the errors of merging members to responses.
+func (h *Handler) mergeError(err error) error {
+	switch {
+	case errors.Is(err, member.ErrNotFound), errors.Is(err, member.ErrDifferentClub):
+		return huma.Error404NotFound("member not found in this club")
+	case errors.Is(err, member.ErrSameMember):
+		return huma.Error422UnprocessableEntity("a member can't be merged into itself")
+	case errors.Is(err, member.ErrNotGuest):
+		return huma.Error422UnprocessableEntity("only guests can be claimed")
+	case errors.Is(err, member.ErrGuest):
+		return huma.Error422UnprocessableEntity("members can't be merged into a guest")
+	case errors.Is(err, member.ErrRoleTooHigh):
+		return huma.Error403Forbidden("you can't merge members ranking above you")
+	case errors.Is(err, member.ErrOwner):
+		return huma.Error409Conflict("the owner can't be merged away, transfer ownership first")
+	case errors.Is(err, member.ErrOpposingTeams):
+		return huma.Error409Conflict("the members played against each other")
+	}
+	h.l.Error("failed to merge members", "error", err)
+	return huma.Error500InternalServerError("failed to merge members, try again later")
+}
+
+func mapMergeResult(result *member.MergeResult, preview bool) *mergeResponse {
+	resp := &mergeResponse{}
+	resp.Body.Preview = preview
+	resp.Body.Matches = result.Matches
+	resp.Body.CollapsedTeams = result.CollapsedTeams
+	resp.Body.CombinedStatistics = result.CombinedStatistics
+	resp.Body.Games = result.Games
+	if resp.Body.Games == nil {
+		resp.Body.Games = []uuid.UUID{}
+	}
+
+	return resp
+}
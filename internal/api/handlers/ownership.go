@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/authentication"
+	"core/internal/member"
+	"errors"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+type ownershipTransferResponse struct {
+	Body struct {
+		FromMemberID uuid.UUID `json:"fromMemberId"`
+		ToMemberID   uuid.UUID `json:"toMemberId"`
+		ExpiresAt    time.Time `json:"expiresAt"`
+		CreatedAt    time.Time `json:"createdAt"`
+	}
+}
+
+func mapOwnershipTransfer(transfer *member.OwnershipTransfer) *ownershipTransferResponse {
+	resp := &ownershipTransferResponse{}
+	resp.Body.FromMemberID = transfer.FromMemberID
+	resp.Body.ToMemberID = transfer.ToMemberID
+	resp.Body.ExpiresAt = transfer.ExpiresAt
+	resp.Body.CreatedAt = transfer.CreatedAt
+
+	return resp
+}
+
+type postOwnershipTransferRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+	Body   struct {
+		MemberID uuid.UUID `json:"memberId" doc:"The member to offer the club to"`
+	}
+}
+
+// PostOwnershipTransfer offers the club to another member, who has to accept
+// before anything changes.
+func (h *Handler) PostOwnershipTransfer(ctx context.Context, req *postOwnershipTransferRequest) (*ownershipTransferResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	transfer, err := h.member.StartOwnershipTransfer(ctx, principal.UserID, req.ClubID, req.Body.MemberID)
+	if err != nil {
+		switch {
+		case errors.Is(err, member.ErrNotOwner):
+			return nil, huma.Error403Forbidden("only owners can transfer the club")
+		case errors.Is(err, member.ErrNotFound), errors.Is(err, member.ErrDifferentClub):
+			return nil, huma.Error404NotFound("member not found in this club")
+		case errors.Is(err, member.ErrSameMember), errors.Is(err, member.ErrOwner):
+			return nil, huma.Error422UnprocessableEntity("member already owns the club")
+		case errors.Is(err, member.ErrGuest):
+			return nil, huma.Error422UnprocessableEntity("guests can't own a club")
+		}
+		h.l.Error("failed to start ownership transfer", "error", err)
+		return nil, huma.Error500InternalServerError("failed to transfer ownership, try again later")
+	}
+
+	return mapOwnershipTransfer(transfer), nil
+}
+
+type getOwnershipTransferRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+}
+
+func (h *Handler) GetOwnershipTransfer(ctx context.Context, req *getOwnershipTransferRequest) (*ownershipTransferResponse, error) {
+	transfer, err := h.member.GetOwnershipTransfer(ctx, req.ClubID)
+	if err != nil {
+		if errors.Is(err, member.ErrNotFound) {
+			return nil, huma.Error404NotFound("no ownership transfer pending")
+		}
+		h.l.Error("failed to get ownership transfer", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get ownership transfer, try again later")
+	}
+
+	return mapOwnershipTransfer(transfer), nil
+}
+
+type deleteOwnershipTransferRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+}
+
+func (h *Handler) DeleteOwnershipTransfer(ctx context.Context, req *deleteOwnershipTransferRequest) (*struct{}, error) {
+	if err := h.member.CancelOwnershipTransfer(ctx, req.ClubID); err != nil {
+		h.l.Error("failed to cancel ownership transfer", "error", err)
+		return nil, huma.Error500InternalServerError("failed to cancel ownership transfer, try again later")
+	}
+
+	return nil, nil
+}
+
+type respondOwnershipTransferRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+}
+
+// AcceptOwnershipTransfer makes the receiving member owner, the former owner
+// stays on as an admin.
+func (h *Handler) AcceptOwnershipTransfer(ctx context.Context, req *respondOwnershipTransferRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if err := h.member.AcceptOwnershipTransfer(ctx, principal.UserID, req.ClubID); err != nil {
+		if errors.Is(err, member.ErrNotFound) {
+			return nil, huma.Error404NotFound("no ownership transfer pending for you")
+		}
+		h.l.Error("failed to accept ownership transfer", "error", err)
+		return nil, huma.Error500InternalServerError("failed to accept ownership transfer, try again later")
+	}
+
+	return nil, nil
+}
+
+func (h *Handler) DeclineOwnershipTransfer(ctx context.Context, req *respondOwnershipTransferRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if err := h.member.DeclineOwnershipTransfer(ctx, principal.UserID, req.ClubID); err != nil {
+		if errors.Is(err, member.ErrNotFound) {
+			return nil, huma.Error404NotFound("no ownership transfer pending for you")
+		}
+		h.l.Error("failed to decline ownership transfer", "error", err)
+		return nil, huma.Error500InternalServerError("failed to decline ownership transfer, try again later")
+	}
+
+	return nil, nil
+}
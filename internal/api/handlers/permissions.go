@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/authentication"
+	"core/internal/authorization"
+	"core/internal/member"
+	"errors"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+type getClubPermissionsRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+}
+
+type getClubPermissionsResponse struct {
+	Body struct {
+		Roles []getClubPermissionsResponseRole `json:"roles"`
+	}
+}
+
+type getClubPermissionsResponseRole struct {
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+}
+
+func (h *Handler) GetClubPermissions(ctx context.Context, req *getClubPermissionsRequest) (*getClubPermissionsResponse, error) {
+	permissions, err := h.authorization.GetPermissions(ctx, req.ClubID)
+	if err != nil {
+		h.l.Error("failed to get permissions", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get permissions, try again later")
+	}
+
+	mappedRoles := make([]getClubPermissionsResponseRole, len(authorization.Roles))
+	for i, role := range authorization.Roles {
+		mappedPermissions := make([]string, len(permissions[role]))
+		for j, p := range permissions[role] {
+			mappedPermissions[j] = string(p)
+		}
+
+		mappedRoles[i] = getClubPermissionsResponseRole{
+			Role:        string(role),
+			Permissions: mappedPermissions,
+		}
+	}
+
+	resp := &getClubPermissionsResponse{}
+	resp.Body.Roles = mappedRoles
+
+	return resp, nil
+}
+
+type putClubPermissionRequest struct {
+	ClubID uuid.UUID `path:"clubId"`
+	Body   struct {
+		Role       member.Role              `json:"role" enum:"observer,member,manager,admin,owner"`
+		Permission authorization.Permission `json:"permission"`
+		Granted    bool                     `json:"granted"`
+	}
+}
+
+func (h *Handler) PutClubPermission(ctx context.Context, req *putClubPermissionRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if err := h.authorization.SetOverride(ctx, principal.UserID, req.ClubID, req.Body.Role, req.Body.Permission, req.Body.Granted); err != nil {
+		if errors.Is(err, authorization.ErrInvalidPermission) {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		h.l.Error("failed to set permission", "error", err)
+		return nil, huma.Error500InternalServerError("failed to set permission, try again later")
+	}
+
+	return nil, nil
+}
+
+type deleteClubPermissionRequest struct {
+	ClubID     uuid.UUID                `path:"clubId"`
+	Role       member.Role              `path:"role" enum:"observer,member,manager,admin,owner"`
+	Permission authorization.Permission `path:"permission"`
+}
+
+func (h *Handler) DeleteClubPermission(ctx context.Context, req *deleteClubPermissionRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if err := h.authorization.DeleteOverride(ctx, principal.UserID, req.ClubID, req.Role, req.Permission); err != nil {
+		if errors.Is(err, authorization.ErrInvalidPermission) {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		h.l.Error("failed to reset permission", "error", err)
+		return nil, huma.Error500InternalServerError("failed to reset permission, try again later")
+	}
+
+	return nil, nil
+}
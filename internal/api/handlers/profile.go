@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/authentication"
+	"core/internal/game"
+	"core/internal/user"
+	"errors"
+	"fmt"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+// avatarURL is where GetAvatar serves a user's avatar.
+const avatarURL = "/api/v1/users/%s/avatar"
+
+type profileGame struct {
+	ID     uuid.UUID `json:"id"`
+	ClubID uuid.UUID `json:"clubId"`
+	Name   string    `json:"name"`
+}
+
+type profileGameStatistics struct {
+	Game   profileGame `json:"game"`
+	Wins   int         `json:"wins"`
+	Draws  int         `json:"draws"`
+	Losses int         `json:"losses"`
+	Streak int         `json:"streak"`
+}
+
+type profileStatistics struct {
+	Wins   int                     `json:"wins"`
+	Draws  int                     `json:"draws"`
+	Losses int                     `json:"losses"`
+	Games  []profileGameStatistics `json:"games"`
+}
+
+type getProfileRequest struct {
+	UserID uuid.UUID `path:"userId"`
+}
+
+type getProfileResponse struct {
+	Body struct {
+		ID             uuid.UUID     `json:"id"`
+		Name           string        `json:"name"`
+		Bio            string        `json:"bio"`
+		AvatarURL      string        `json:"avatarUrl,omitempty"`
+		PreferredGames []profileGame `json:"preferredGames"`
+		// Statistics are left out unless the user shares them with the viewer
+		Statistics *profileStatistics `json:"statistics,omitempty"`
+		// The visibility settings are only shown to the user themselves
+		ProfileVisibility user.Visibility `json:"profileVisibility,omitempty"`
+		StatsVisibility   user.Visibility `json:"statsVisibility,omitempty"`
+	}
+}
+
+// GetProfile shows a user's profile and their statistics across all clubs, as
+// far as their privacy settings allow the caller to see them.
+func (h *Handler) GetProfile(ctx context.Context, req *getProfileRequest) (*getProfileResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	u, self, sharesClub, err := h.getProfileUser(ctx, principal.UserID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	preferredGameIDs, err := h.user.GetPreferredGames(ctx, u.ID)
+	if err != nil {
+		h.l.Error("failed to get preferred games", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get profile, try again later")
+	}
+
+	resp := &getProfileResponse{}
+	resp.Body.ID = u.ID
+	resp.Body.Name = u.PublicName()
+	resp.Body.Bio = u.Bio
+	if u.AvatarKey != nil {
+		resp.Body.AvatarURL = fmt.Sprintf(avatarURL, u.ID)
+	}
+	if self {
+		resp.Body.ProfileVisibility = u.ProfileVisibility
+		resp.Body.StatsVisibility = u.StatsVisibility
+	}
+
+	var stats []profileGameStatistics
+	if u.StatsVisibility.VisibleTo(self, sharesClub) {
+		memberships, err := h.member.GetUserMemberships(ctx, u.ID)
+		if err != nil {
+			h.l.Error("failed to get memberships", "error", err)
+			return nil, huma.Error500InternalServerError("failed to get profile, try again later")
+		}
+
+		memberIDs := make([]uuid.UUID, len(memberships))
+		for i, membership := range memberships {
+			memberIDs[i] = membership.ID
+		}
+
+		memberStats, err := h.statistic.GetStatisticsByMembers(ctx, memberIDs)
+		if err != nil {
+			h.l.Error("failed to get statistics", "error", err)
+			return nil, huma.Error500InternalServerError("failed to get profile, try again later")
+		}
+
+		resp.Body.Statistics = &profileStatistics{}
+		stats = make([]profileGameStatistics, len(memberStats))
+		for i, s := range memberStats {
+			stats[i] = profileGameStatistics{
+				Game:   profileGame{ID: s.GameId},
+				Wins:   s.Wins,
+				Draws:  s.Draws,
+				Losses: s.Losses,
+				Streak: s.Streak,
+			}
+			resp.Body.Statistics.Wins += s.Wins
+			resp.Body.Statistics.Draws += s.Draws
+			resp.Body.Statistics.Losses += s.Losses
+		}
+	}
+
+	// Look up the names of all games on the profile at once
+	gameIDs := append([]uuid.UUID{}, preferredGameIDs...)
+	for _, s := range stats {
+		gameIDs = append(gameIDs, s.Game.ID)
+	}
+	games, err := h.getProfileGames(ctx, gameIDs)
+	if err != nil {
+		h.l.Error("failed to get games", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get profile, try again later")
+	}
+
+	resp.Body.PreferredGames = make([]profileGame, 0, len(preferredGameIDs))
+	for _, gameID := range preferredGameIDs {
+		if g, ok := games[gameID]; ok {
+			resp.Body.PreferredGames = append(resp.Body.PreferredGames, g)
+		}
+	}
+	for i := range stats {
+		stats[i].Game = games[stats[i].Game.ID]
+	}
+	if resp.Body.Statistics != nil {
+		resp.Body.Statistics.Games = stats
+	}
+
+	return resp, nil
+}
+
+type updateProfileRequest struct {
+	UserID uuid.UUID `path:"userId"`
+	Body   struct {
+		DisplayName       string          `json:"displayName" maxLength:"50" doc:"Shown instead of the name, empty to use the name"`
+		Bio               string          `json:"bio" maxLength:"500"`
+		ProfileVisibility user.Visibility `json:"profileVisibility" enum:"public,clubs,private" doc:"Who may see the profile, clubs meaning users sharing a club"`
+		StatsVisibility   user.Visibility `json:"statsVisibility" enum:"public,clubs,private" doc:"Who may see the statistics on the profile"`
+		PreferredGames    []uuid.UUID     `json:"preferredGames" maxItems:"10" uniqueItems:"true" doc:"Games of the user's clubs, in order of preference"`
+	}
+}
+
+func (h *Handler) UpdateProfile(ctx context.Context, req *updateProfileRequest) (*struct{}, error) {
+	memberships, err := h.member.GetUserMemberships(ctx, req.UserID)
+	if err != nil {
+		h.l.Error("failed to get memberships", "error", err)
+		return nil, huma.Error500InternalServerError("failed to update profile, try again later")
+	}
+
+	clubIDs := make(map[uuid.UUID]bool, len(memberships))
+	for _, membership := range memberships {
+		clubIDs[membership.ClubID] = true
+	}
+
+	games, err := h.getProfileGames(ctx, req.Body.PreferredGames)
+	if err != nil {
+		h.l.Error("failed to get games", "error", err)
+		return nil, huma.Error500InternalServerError("failed to update profile, try again later")
+	}
+	for _, gameID := range req.Body.PreferredGames {
+		if g, ok := games[gameID]; !ok || !clubIDs[g.ClubID] {
+			return nil, huma.Error422UnprocessableEntity(fmt.Sprintf("game %s is not played in any of your clubs", gameID))
+		}
+	}
+
+	u := &user.User{
+		ID:                req.UserID,
+		DisplayName:       req.Body.DisplayName,
+		Bio:               req.Body.Bio,
+		ProfileVisibility: req.Body.ProfileVisibility,
+		StatsVisibility:   req.Body.StatsVisibility,
+	}
+	if err := h.user.UpdateProfile(ctx, u); err != nil {
+		if errors.Is(err, user.ErrInvalidVisibility) {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		h.l.Error("failed to update profile", "error", err)
+		return nil, huma.Error500InternalServerError("failed to update profile, try again later")
+	}
+
+	if err := h.user.SetPreferredGames(ctx, req.UserID, req.Body.PreferredGames); err != nil {
+		h.l.Error("failed to set preferred games", "error", err)
+		return nil, huma.Error500InternalServerError("failed to update profile, try again later")
+	}
+
+	return nil, nil
+}
+
+type putAvatarRequest struct {
+	UserID  uuid.UUID `path:"userId"`
+	RawBody []byte    `contentType:"image/*"`
+}
+
+func (h *Handler) PutAvatar(ctx context.Context, req *putAvatarRequest) (*struct{}, error) {
+	if err := h.user.SetAvatar(ctx, req.UserID, req.RawBody); err != nil {
+		if errors.Is(err, user.ErrInvalidAvatar) {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		h.l.Error("failed to set avatar", "error", err)
+		return nil, huma.Error500InternalServerError("failed to upload avatar, try again later")
+	}
+
+	return nil, nil
+}
+
+type getAvatarRequest struct {
+	UserID uuid.UUID `path:"userId"`
+}
+
+type getAvatarResponse struct {
+	ContentType  string `header:"Content-Type"`
+	CacheControl string `header:"Cache-Control"`
+	Body         []byte
+}
+
+// GetAvatar serves the avatar to whoever may see the profile.
+func (h *Handler) GetAvatar(ctx context.Context, req *getAvatarRequest) (*getAvatarResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	u, _, _, err := h.getProfileUser(ctx, principal.UserID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, contentType, err := h.user.GetAvatar(ctx, u)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, huma.Error404NotFound("user has no avatar")
+		}
+		h.l.Error("failed to get avatar", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get avatar, try again later")
+	}
+
+	resp := &getAvatarResponse{}
+	resp.ContentType = contentType
+	resp.CacheControl = "private, max-age=300"
+	resp.Body = data
+
+	return resp, nil
+}
+
+type deleteAvatarRequest struct {
+	UserID uuid.UUID `path:"userId"`
+}
+
+func (h *Handler) DeleteAvatar(ctx context.Context, req *deleteAvatarRequest) (*struct{}, error) {
+	if err := h.user.DeleteAvatar(ctx, req.UserID); err != nil {
+		h.l.Error("failed to delete avatar", "error", err)
+		return nil, huma.Error500InternalServerError("failed to delete avatar, try again later")
+	}
+
+	return nil, nil
+}
+
+// getProfileUser returns the user if the viewer may see their profile, along
+// with how the viewer relates to them. Hidden profiles are reported as not
+// found, so they can't be told apart from missing ones.
+func (h *Handler) getProfileUser(ctx context.Context, viewerID, userID uuid.UUID) (u *user.User, self, sharesClub bool, err error) {
+	u, err = h.user.GetUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, false, false, huma.Error404NotFound("user not found")
+		}
+		h.l.Error("failed to get user", "error", err)
+		return nil, false, false, huma.Error500InternalServerError("failed to get user, try again later")
+	}
+	if u.Deleted() {
+		return nil, false, false, huma.Error404NotFound("user not found")
+	}
+
+	self = viewerID == userID
+	if !self {
+		sharesClub, err = h.sharesClub(ctx, viewerID, userID)
+		if err != nil {
+			h.l.Error("failed to compare memberships", "error", err)
+			return nil, false, false, huma.Error500InternalServerError("failed to get user, try again later")
+		}
+	}
+
+	if !u.ProfileVisibility.VisibleTo(self, sharesClub) {
+		return nil, false, false, huma.Error404NotFound("user not found")
+	}
+
+	return u, self, sharesClub, nil
+}
+
+func (h *Handler) sharesClub(ctx context.Context, userID, otherUserID uuid.UUID) (bool, error) {
+	memberships, err := h.member.GetUserMemberships(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	otherMemberships, err := h.member.GetUserMemberships(ctx, otherUserID)
+	if err != nil {
+		return false, err
+	}
+
+	clubIDs := make(map[uuid.UUID]bool, len(memberships))
+	for _, membership := range memberships {
+		clubIDs[membership.ClubID] = true
+	}
+	for _, membership := range otherMemberships {
+		if clubIDs[membership.ClubID] {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (h *Handler) getProfileGames(ctx context.Context, gameIDs []uuid.UUID) (map[uuid.UUID]profileGame, error) {
+	games := make(map[uuid.UUID]profileGame, len(gameIDs))
+	if len(gameIDs) == 0 {
+		return games, nil
+	}
+
+	found, err := h.game.GetGames(ctx, gameIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range found {
+		games[g.ID] = mapProfileGame(g)
+	}
+
+	return games, nil
+}
+
+func mapProfileGame(g game.Game) profileGame {
+	return profileGame{
+		ID:     g.ID,
+		ClubID: g.ClubID,
+		Name:   g.Name,
+	}
+}
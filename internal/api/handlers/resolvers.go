@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/api/middleware"
+	"core/internal/club"
+	"core/internal/event"
+	"core/internal/game"
+	"core/internal/match"
+	"core/internal/member"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// ClubResolvers maps path parameters to lookups of the club owning the
+// referenced entity, for use by the authorization middleware.
+func (h *Handler) ClubResolvers() map[string]middleware.ClubResolver {
+	return map[string]middleware.ClubResolver{
+		"gameId": func(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+			g, err := h.game.GetGame(ctx, id)
+			if err != nil {
+				return uuid.Nil, notFound(err, game.ErrNotFound)
+			}
+			return g.ClubID, nil
+		},
+		"memberId": func(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+			m, err := h.member.GetMember(ctx, id)
+			if err != nil {
+				return uuid.Nil, notFound(err, member.ErrNotFound)
+			}
+			return m.ClubID, nil
+		},
+		"matchId": func(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+			m, err := h.match.GetMatch(ctx, id)
+			if err != nil {
+				return uuid.Nil, notFound(err, match.ErrNotFound)
+			}
+			return m.ClubID, nil
+		},
+		"eventId": func(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+			e, err := h.event.GetEvent(ctx, id)
+			if err != nil {
+				return uuid.Nil, notFound(err, event.ErrNotFound)
+			}
+			return e.ClubID, nil
+		},
+		"linkId": func(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+			l, err := h.club.GetInviteLink(ctx, id)
+			if err != nil {
+				return uuid.Nil, notFound(err, club.ErrNotFound)
+			}
+			return l.ClubID, nil
+		},
+	}
+}
+
+// notFound translates a package specific not found error into the one the
+// middleware understands.
+func notFound(err, target error) error {
+	if errors.Is(err, target) {
+		return middleware.ErrNotFound
+	}
+	return err
+}
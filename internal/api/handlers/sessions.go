@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/authentication"
+	"errors"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type getSessionsResponse struct {
+	Body struct {
+		Sessions []getSessionsResponseSession `json:"sessions"`
+	}
+}
+
+type getSessionsResponseSession struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	Current    bool      `json:"current"`
+}
+
+func (h *Handler) GetSessions(ctx context.Context, req *struct{}) (*getSessionsResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	sessions, err := h.authentication.GetSessions(ctx, principal.UserID)
+	if err != nil {
+		h.l.Error("failed to get sessions", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get sessions, try again later")
+	}
+
+	mappedSessions := make([]getSessionsResponseSession, len(sessions))
+	for i, s := range sessions {
+		mappedSessions[i] = getSessionsResponseSession{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			Current:    s.ID == principal.SessionID,
+		}
+	}
+
+	resp := &getSessionsResponse{}
+	resp.Body.Sessions = mappedSessions
+
+	return resp, nil
+}
+
+type deleteSessionRequest struct {
+	SessionID string `path:"sessionId"`
+}
+
+func (h *Handler) DeleteSession(ctx context.Context, req *deleteSessionRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if err := h.authentication.RevokeSession(ctx, principal.UserID, req.SessionID); err != nil {
+		if errors.Is(err, authentication.ErrSessionNotFound) {
+			return nil, huma.Error404NotFound("session not found")
+		}
+		h.l.Error("failed to revoke session", "error", err)
+		return nil, huma.Error500InternalServerError("failed to revoke session, try again later")
+	}
+
+	return nil, nil
+}
+
+// DeleteSessions logs the user out everywhere, including the current session.
+func (h *Handler) DeleteSessions(ctx context.Context, req *struct{}) (*logoutResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if err := h.authentication.RevokeSessions(ctx, principal.UserID, ""); err != nil {
+		h.l.Error("failed to revoke sessions", "error", err)
+		return nil, huma.Error500InternalServerError("failed to log out everywhere, try again later")
+	}
+
+	resp := &logoutResponse{SetCookie: clearedCookies()}
+
+	return resp, nil
+}
@@ -25,33 +25,15 @@ type getMemberStatisticsResponseStatistic struct {
 	Losses int       `json:"losses"`
 	Draws  int       `json:"draws"`
 	Streak int       `json:"streak"`
+	// LongestStreak is the longest win streak so far
+	LongestStreak int `json:"longestStreak"`
 }
 
 func (h *Handler) GetMemberStatistics(ctx context.Context, req *getMemberStatisticsRequest) (*getMemberStatisticsResponse, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
-	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
-	}
-
-	// Get the member's club ID
-	member, err := h.member.GetMember(ctx, req.MemberID)
-	if err != nil {
-		h.l.Error("failed to get member", "error", err)
-		return nil, huma.Error500InternalServerError("failed to get member")
-	}
-
-	// Check if the user is authorized to view the member's statistics
-	ok, err = h.authorization.IsMember(ctx, userID, member.ClubID)
-	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
-	}
-	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to view statistics in this club")
-	}
-
-	var stats []statistic.Statistic
+	var (
+		stats []statistic.Statistic
+		err   error
+	)
 	if req.GameID != nil {
 		// Get statistics for a specific game
 		stat, err := h.statistic.GetStatistics(ctx, req.MemberID, *req.GameID)
@@ -77,6 +59,8 @@ func (h *Handler) GetMemberStatistics(ctx context.Context, req *getMemberStatist
 			Losses: s.Losses,
 			Draws:  s.Draws,
 			Streak: s.Streak,
+
+			LongestStreak: s.LongestStreak,
 		}
 	}
 
@@ -105,29 +89,6 @@ type getGameRankingsResponseRanking struct {
 }
 
 func (h *Handler) GetGameRankings(ctx context.Context, req *getGameRankingsRequest) (*getGameRankingsResponse, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
-	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
-	}
-
-	// Get the game's club ID
-	game, err := h.game.GetGame(ctx, req.GameID)
-	if err != nil {
-		h.l.Error("failed to get game", "error", err)
-		return nil, huma.Error500InternalServerError("failed to get game")
-	}
-
-	// Check if the user is authorized to view the rankings
-	ok, err = h.authorization.IsMember(ctx, userID, game.ClubID)
-	if err != nil {
-		h.l.Error("failed to check authorization", "error", err)
-		return nil, huma.Error500InternalServerError("failed to check authorization")
-	}
-	if !ok {
-		return nil, huma.Error403Forbidden("user not authorized to view rankings in this club")
-	}
-
 	// Get statistics for all members in the game
 	stats, err := h.statistic.GetStatisticsByGame(ctx, req.GameID)
 	if err != nil {
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/authentication"
+	"errors"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type getTwoFactorResponse struct {
+	Body struct {
+		Enabled                bool `json:"enabled"`
+		RecoveryCodesRemaining int  `json:"recoveryCodesRemaining"`
+		// Session reports whether the current session passed a second factor
+		Session bool `json:"session"`
+	}
+}
+
+func (h *Handler) GetTwoFactor(ctx context.Context, req *struct{}) (*getTwoFactorResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	status, err := h.authentication.GetTwoFactorStatus(ctx, principal.UserID)
+	if err != nil {
+		h.l.Error("failed to get two factor status", "error", err)
+		return nil, huma.Error500InternalServerError("failed to get two-factor status, try again later")
+	}
+
+	resp := &getTwoFactorResponse{}
+	resp.Body.Enabled = status.Enabled
+	resp.Body.RecoveryCodesRemaining = status.RecoveryCodesRemaining
+	resp.Body.Session = principal.TwoFactor
+
+	return resp, nil
+}
+
+type enrollTwoFactorResponse struct {
+	Body struct {
+		Secret string `json:"secret"`
+		URI    string `json:"uri" doc:"otpauth URI to show as a QR code"`
+	}
+}
+
+func (h *Handler) EnrollTwoFactor(ctx context.Context, req *struct{}) (*enrollTwoFactorResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	secret, uri, err := h.authentication.EnrollTwoFactor(ctx, principal.UserID)
+	if err != nil {
+		if errors.Is(err, authentication.ErrTwoFactorEnabled) {
+			return nil, huma.Error409Conflict("two-factor authentication is already enabled")
+		}
+		h.l.Error("failed to enroll two factor", "error", err)
+		return nil, huma.Error500InternalServerError("failed to set up two-factor authentication, try again later")
+	}
+
+	resp := &enrollTwoFactorResponse{}
+	resp.Body.Secret = secret
+	resp.Body.URI = uri
+
+	return resp, nil
+}
+
+type twoFactorCodeRequest struct {
+	Body struct {
+		Code string `json:"code" minLength:"6" maxLength:"16"`
+	}
+}
+
+type recoveryCodesResponse struct {
+	Body struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+}
+
+func (h *Handler) ConfirmTwoFactor(ctx context.Context, req *twoFactorCodeRequest) (*recoveryCodesResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	codes, err := h.authentication.ConfirmTwoFactor(ctx, principal.UserID, req.Body.Code)
+	if err != nil {
+		return nil, h.twoFactorError(err, "failed to confirm two-factor authentication")
+	}
+
+	resp := &recoveryCodesResponse{}
+	resp.Body.RecoveryCodes = codes
+
+	return resp, nil
+}
+
+func (h *Handler) RegenerateRecoveryCodes(ctx context.Context, req *twoFactorCodeRequest) (*recoveryCodesResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	codes, err := h.authentication.RegenerateRecoveryCodes(ctx, principal.UserID, req.Body.Code)
+	if err != nil {
+		return nil, h.twoFactorError(err, "failed to regenerate recovery codes")
+	}
+
+	resp := &recoveryCodesResponse{}
+	resp.Body.RecoveryCodes = codes
+
+	return resp, nil
+}
+
+func (h *Handler) DisableTwoFactor(ctx context.Context, req *twoFactorCodeRequest) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if err := h.authentication.DisableTwoFactor(ctx, principal.UserID, req.Body.Code); err != nil {
+		return nil, h.twoFactorError(err, "failed to disable two-factor authentication")
+	}
+
+	return nil, nil
+}
+
+func (h *Handler) twoFactorError(err error, msg string) error {
+	switch {
+	case errors.Is(err, authentication.ErrInvalidCode):
+		return huma.Error400BadRequest("invalid code")
+	case errors.Is(err, authentication.ErrTwoFactorNotFound):
+		return huma.Error409Conflict("two-factor authentication is not enabled")
+	case errors.Is(err, authentication.ErrTwoFactorEnabled):
+		return huma.Error409Conflict("two-factor authentication is already enabled")
+	}
+
+	h.l.Error(msg, "error", err)
+	return huma.Error500InternalServerError(msg + ", try again later")
+}
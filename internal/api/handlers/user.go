@@ -2,6 +2,13 @@ package handlers
 
 import (
 	"context"
+	"core/internal/authentication"
+	"core/internal/member"
+	"core/internal/ratelimit"
+	"core/internal/user"
+	"errors"
+	"net/http"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/google/uuid"
@@ -23,18 +30,7 @@ type updateUserResponse struct {
 }
 
 func (h *Handler) UpdateUser(ctx context.Context, req *updateUserRequest) (*updateUserResponse, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
-	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
-	}
-
-	if userID != req.UserID {
-		h.l.Error("user id from context does not match request")
-		return nil, huma.Error403Forbidden("user id from context does not match request")
-	}
-
-	if err := h.user.UpdateUser(ctx, userID, req.Body.Email, req.Body.Name); err != nil {
+	if err := h.user.UpdateUser(ctx, req.UserID, req.Body.Email, req.Body.Name); err != nil {
 		h.l.Error("failed to update user", "error", err)
 		return nil, huma.Error500InternalServerError("failed to update user, try again later")
 	}
@@ -48,24 +44,229 @@ func (h *Handler) UpdateUser(ctx context.Context, req *updateUserRequest) (*upda
 
 type deleteUserRequest struct {
 	UserID uuid.UUID `path:"userId"`
+	Body   struct {
+		Password string `json:"password,omitempty" maxLength:"256" doc:"Required unless the account only logs in through a provider, which must have logged in recently instead"`
+	}
+}
+
+type deleteUserResponse struct {
+	Status int
+	Body   struct {
+		DeletionScheduledAt time.Time `json:"deletionScheduledAt"`
+	}
 }
 
-func (h *Handler) DeleteUser(ctx context.Context, req *deleteUserRequest) (*struct{}, error) {
-	userID, ok := ctx.Value("user_id").(uuid.UUID)
+// DeleteUser schedules the account to be anonymized after a grace period, in
+// which the user can restore it. Other sessions are logged out.
+func (h *Handler) DeleteUser(ctx context.Context, req *deleteUserRequest) (*deleteUserResponse, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
 	if !ok {
-		h.l.Error("failed to get user id from context")
-		return nil, huma.Error500InternalServerError("failed to get user id from context")
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if err := h.authentication.Reauthenticate(ctx, principal, req.Body.Password); err != nil {
+		switch {
+		case errors.Is(err, authentication.ErrReauthenticationRequired):
+			return nil, huma.Error403Forbidden("confirm with your password, or log in again if you have none")
+		case errors.Is(err, ratelimit.ErrLimited):
+			return nil, tooManyRequests(err, "too many attempts, try again later")
+		}
+		h.l.Error("failed to reauthenticate", "error", err)
+		return nil, huma.Error500InternalServerError("failed to delete user, try again later")
 	}
 
-	if userID != req.UserID {
-		h.l.Error("user id from context does not match request")
-		return nil, huma.Error403Forbidden("user id from context does not match request")
+	memberships, err := h.member.GetUserMemberships(ctx, principal.UserID)
+	if err != nil {
+		h.l.Error("failed to get memberships", "error", err)
+		return nil, huma.Error500InternalServerError("failed to delete user, try again later")
+	}
+	for _, membership := range memberships {
+		if membership.Role == member.RoleOwner {
+			return nil, huma.Error409Conflict("transfer or delete the clubs you own first")
+		}
 	}
 
-	if err := h.user.DeleteUser(ctx, userID); err != nil {
-		h.l.Error("failed to delete user", "error", err)
+	scheduledAt, err := h.user.ScheduleDeletion(ctx, principal.UserID)
+	if err != nil {
+		h.l.Error("failed to schedule deletion", "error", err)
 		return nil, huma.Error500InternalServerError("failed to delete user, try again later")
 	}
 
+	if err := h.authentication.RevokeSessions(ctx, principal.UserID, principal.SessionID); err != nil {
+		h.l.Error("failed to revoke other sessions", "error", err)
+	}
+
+	resp := &deleteUserResponse{Status: http.StatusAccepted}
+	resp.Body.DeletionScheduledAt = scheduledAt
+
+	return resp, nil
+}
+
+type restoreUserRequest struct {
+	UserID uuid.UUID `path:"userId"`
+}
+
+// RestoreUser cancels a scheduled deletion.
+func (h *Handler) RestoreUser(ctx context.Context, req *restoreUserRequest) (*struct{}, error) {
+	if err := h.user.CancelDeletion(ctx, req.UserID); err != nil {
+		if errors.Is(err, user.ErrDeletionNotScheduled) {
+			return nil, huma.Error409Conflict("account is not scheduled for deletion")
+		}
+		h.l.Error("failed to cancel deletion", "error", err)
+		return nil, huma.Error500InternalServerError("failed to restore user, try again later")
+	}
+
 	return nil, nil
 }
+
+type exportUserRequest struct {
+	UserID uuid.UUID `path:"userId"`
+}
+
+type exportProfile struct {
+	ID                  uuid.UUID  `json:"id"`
+	Email               string     `json:"email"`
+	Name                string     `json:"name"`
+	DisplayName         string     `json:"displayName"`
+	Bio                 string     `json:"bio"`
+	ProfileVisibility   string     `json:"profileVisibility"`
+	StatsVisibility     string     `json:"statsVisibility"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	LastLogin           *time.Time `json:"lastLogin,omitempty"`
+	EmailVerifiedAt     *time.Time `json:"emailVerifiedAt,omitempty"`
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty"`
+}
+
+type exportMembership struct {
+	MemberID uuid.UUID   `json:"memberId"`
+	ClubID   uuid.UUID   `json:"clubId"`
+	ClubName string      `json:"clubName"`
+	Role     member.Role `json:"role"`
+}
+
+type exportMatch struct {
+	ID        uuid.UUID     `json:"id"`
+	ClubID    uuid.UUID     `json:"clubId"`
+	GameID    uuid.UUID     `json:"gameId"`
+	Mode      int           `json:"mode"`
+	Ranked    bool          `json:"ranked"`
+	Sets      []string      `json:"sets"`
+	Teams     [][]uuid.UUID `json:"teams" doc:"Member IDs of each team"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+type exportRating struct {
+	MemberID  uuid.UUID `json:"memberId"`
+	GameID    uuid.UUID `json:"gameId"`
+	Mu        float64   `json:"mu"`
+	Sigma     float64   `json:"sigma"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type exportUserResponse struct {
+	ContentDisposition string `header:"Content-Disposition"`
+	Body               struct {
+		ExportedAt  time.Time          `json:"exportedAt"`
+		Profile     exportProfile      `json:"profile"`
+		Memberships []exportMembership `json:"memberships"`
+		Matches     []exportMatch      `json:"matches"`
+		Ratings     []exportRating     `json:"ratings"`
+	}
+}
+
+// ExportUser returns an archive of the data kept about the user.
+func (h *Handler) ExportUser(ctx context.Context, req *exportUserRequest) (*exportUserResponse, error) {
+	u, err := h.user.GetUser(ctx, req.UserID)
+	if err != nil {
+		h.l.Error("failed to get user", "error", err)
+		return nil, huma.Error500InternalServerError("failed to export user, try again later")
+	}
+
+	memberships, err := h.member.GetUserMemberships(ctx, req.UserID)
+	if err != nil {
+		h.l.Error("failed to get memberships", "error", err)
+		return nil, huma.Error500InternalServerError("failed to export user, try again later")
+	}
+
+	resp := &exportUserResponse{}
+	resp.ContentDisposition = `attachment; filename="matchally-export.json"`
+	resp.Body.ExportedAt = time.Now()
+	resp.Body.Profile = exportProfile{
+		ID:                  u.ID,
+		Email:               u.Email,
+		Name:                u.Name,
+		DisplayName:         u.DisplayName,
+		Bio:                 u.Bio,
+		ProfileVisibility:   string(u.ProfileVisibility),
+		StatsVisibility:     string(u.StatsVisibility),
+		CreatedAt:           u.CreatedAt,
+		LastLogin:           u.LastLogin,
+		EmailVerifiedAt:     u.EmailVerifiedAt,
+		DeletionScheduledAt: u.DeletionScheduledAt,
+	}
+
+	memberIDs := make([]uuid.UUID, len(memberships))
+	resp.Body.Memberships = make([]exportMembership, len(memberships))
+	for i, membership := range memberships {
+		c, err := h.club.GetClub(ctx, membership.ClubID)
+		if err != nil {
+			h.l.Error("failed to get club", "error", err)
+			return nil, huma.Error500InternalServerError("failed to export user, try again later")
+		}
+
+		memberIDs[i] = membership.ID
+		resp.Body.Memberships[i] = exportMembership{
+			MemberID: membership.ID,
+			ClubID:   membership.ClubID,
+			ClubName: c.Name,
+			Role:     membership.Role,
+		}
+	}
+
+	matches, err := h.match.GetMemberMatches(ctx, memberIDs)
+	if err != nil {
+		h.l.Error("failed to get matches", "error", err)
+		return nil, huma.Error500InternalServerError("failed to export user, try again later")
+	}
+
+	resp.Body.Matches = make([]exportMatch, len(matches))
+	for i, m := range matches {
+		teams := make([][]uuid.UUID, len(m.Teams))
+		for j, team := range m.Teams {
+			teams[j] = make([]uuid.UUID, len(team.Members))
+			for k, teamMember := range team.Members {
+				teams[j][k] = teamMember.ID
+			}
+		}
+
+		resp.Body.Matches[i] = exportMatch{
+			ID:        m.ID,
+			ClubID:    m.ClubID,
+			GameID:    m.GameID,
+			Mode:      int(m.Gamemode),
+			Ranked:    m.Ranked,
+			Sets:      m.Sets,
+			Teams:     teams,
+			CreatedAt: m.CreatedAt,
+		}
+	}
+
+	ratings, err := h.rating.GetMemberRatings(ctx, memberIDs)
+	if err != nil {
+		h.l.Error("failed to get ratings", "error", err)
+		return nil, huma.Error500InternalServerError("failed to export user, try again later")
+	}
+
+	resp.Body.Ratings = make([]exportRating, len(ratings))
+	for i, r := range ratings {
+		resp.Body.Ratings[i] = exportRating{
+			MemberID:  r.MemberID,
+			GameID:    r.GameID,
+			Mu:        r.Mu,
+			Sigma:     r.Sigma,
+			UpdatedAt: r.UpdatedAt,
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"core/internal/authentication"
+	"errors"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type verifyEmailRequest struct {
+	Body struct {
+		Token string `json:"token" minLength:"1"`
+	}
+}
+
+func (h *Handler) VerifyEmail(ctx context.Context, req *verifyEmailRequest) (*struct{}, error) {
+	if err := h.authentication.VerifyEmail(ctx, req.Body.Token); err != nil {
+		if errors.Is(err, authentication.ErrInvalidToken) {
+			return nil, huma.Error400BadRequest("verification link is invalid or expired")
+		}
+		h.l.Error("failed to verify email", "error", err)
+		return nil, huma.Error500InternalServerError("failed to verify email, try again later")
+	}
+
+	return nil, nil
+}
+
+func (h *Handler) ResendVerificationEmail(ctx context.Context, req *struct{}) (*struct{}, error) {
+	principal, ok := authentication.PrincipalFrom(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("not authenticated")
+	}
+
+	if err := h.authentication.SendVerificationEmail(ctx, principal.UserID); err != nil {
+		h.l.Error("failed to send verification email", "error", err)
+		return nil, huma.Error500InternalServerError("failed to send verification email, try again later")
+	}
+
+	return nil, nil
+}
+
+type requestPasswordResetRequest struct {
+	Body struct {
+		Email string `json:"email" format:"email"`
+	}
+}
+
+// RequestPasswordReset always succeeds, so it can't be used to find out
+// which email addresses are registered.
+func (h *Handler) RequestPasswordReset(ctx context.Context, req *requestPasswordResetRequest) (*struct{}, error) {
+	if err := h.authentication.RequestPasswordReset(ctx, req.Body.Email); err != nil {
+		h.l.Error("failed to request password reset", "error", err)
+	}
+
+	return nil, nil
+}
+
+type resetPasswordRequest struct {
+	Body struct {
+		Token    string `json:"token" minLength:"1"`
+		Password string `json:"password" minLength:"8" maxLength:"256"`
+	}
+}
+
+func (h *Handler) ResetPassword(ctx context.Context, req *resetPasswordRequest) (*struct{}, error) {
+	if err := h.authentication.ResetPassword(ctx, req.Body.Token, req.Body.Password); err != nil {
+		if errors.Is(err, authentication.ErrInvalidToken) {
+			return nil, huma.Error400BadRequest("reset link is invalid or expired")
+		}
+		h.l.Error("failed to reset password", "error", err)
+		return nil, huma.Error500InternalServerError("failed to reset password, try again later")
+	}
+
+	return nil, nil
+}
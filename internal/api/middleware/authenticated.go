@@ -3,23 +3,50 @@ package middleware
 import (
 	"core/internal/authentication"
 	"core/internal/cache"
+	"crypto/subtle"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/danielgtaylor/huma/v2"
 )
 
+const (
+	AccessCookie = "access"
+	CSRFCookie   = "csrf"
+	CSRFHeader   = "X-CSRF-Token"
+)
+
+// Authenticated verifies the access token from either the Authorization bearer
+// header or the access cookie and stores the resulting principal in the context.
+// Cookie authenticated requests with unsafe methods must echo the csrf cookie in
+// the X-CSRF-Token header, since browsers attach cookies to cross-site requests.
+// API keys are accepted as bearer tokens instead of an access token.
 func Authenticated(authenticationService authentication.Service, cacheService cache.Service) func(ctx huma.Context, next func(huma.Context)) {
 	return func(ctx huma.Context, next func(huma.Context)) {
-		header := ctx.Header("Authorization")
-		if len(header) == 0 {
+		token, fromCookie, ok := accessToken(ctx)
+		if !ok {
 			ctx.SetStatus(http.StatusUnauthorized)
 			return
 		}
 
-		typ, token, ok := strings.Cut(header, " ")
-		if !ok || typ != "Bearer" {
-			ctx.SetStatus(http.StatusUnauthorized)
+		if !fromCookie && strings.HasPrefix(token, authentication.APIKeyPrefix) {
+			principal, err := authenticationService.VerifyAPIKey(ctx.Context(), token)
+			if err != nil {
+				if errors.Is(err, authentication.ErrInvalidToken) {
+					ctx.SetStatus(http.StatusUnauthorized)
+					return
+				}
+				ctx.SetStatus(http.StatusInternalServerError)
+				return
+			}
+
+			next(huma.WithValue(ctx, authentication.PrincipalContextKey, principal))
+			return
+		}
+
+		if fromCookie && !isSafeMethod(ctx.Method()) && !validCSRF(ctx) {
+			ctx.SetStatus(http.StatusForbidden)
 			return
 		}
 
@@ -29,19 +56,92 @@ func Authenticated(authenticationService authentication.Service, cacheService ca
 			return
 		}
 
-		used, err := cacheService.GetTokenUsed(ctx.Context(), token)
+		principal, err := authentication.NewPrincipal(claims)
+		if err != nil {
+			ctx.SetStatus(http.StatusUnauthorized)
+			return
+		}
+
+		revoked, err := cacheService.IsTokenRevoked(ctx.Context(), principal.TokenID)
 		if err != nil {
 			ctx.SetStatus(http.StatusInternalServerError)
 			return
 		}
-		if used {
+		if revoked {
 			ctx.SetStatus(http.StatusUnauthorized)
 			return
 		}
 
-		ctx = huma.WithValue(ctx, "claims", claims)
-		ctx = huma.WithValue(ctx, "token", token)
+		revokedBefore, revoked, err := cacheService.GetUserTokensRevokedBefore(ctx.Context(), principal.UserID)
+		if err != nil {
+			ctx.SetStatus(http.StatusInternalServerError)
+			return
+		}
+		if revoked && (claims.IssuedAt == nil || !claims.IssuedAt.After(revokedBefore)) {
+			ctx.SetStatus(http.StatusUnauthorized)
+			return
+		}
 
-		next(ctx)
+		// Tokens outlive their session when it is revoked, e.g. by logging out everywhere
+		active, err := cacheService.TouchTokenFamily(ctx.Context(), principal.SessionID)
+		if err != nil {
+			ctx.SetStatus(http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			ctx.SetStatus(http.StatusUnauthorized)
+			return
+		}
+
+		// Memberships embedded before the user's roles last changed can't be trusted
+		changedAt, changed, err := cacheService.GetMembershipsChanged(ctx.Context(), principal.UserID)
+		if err != nil {
+			ctx.SetStatus(http.StatusInternalServerError)
+			return
+		}
+		if changed && (claims.IssuedAt == nil || !claims.IssuedAt.After(changedAt)) {
+			principal.Memberships = nil
+		}
+
+		next(huma.WithValue(ctx, authentication.PrincipalContextKey, principal))
+	}
+}
+
+// accessToken reads the access token, preferring the bearer header over the cookie.
+func accessToken(ctx huma.Context) (token string, fromCookie bool, ok bool) {
+	if header := ctx.Header("Authorization"); len(header) != 0 {
+		typ, token, ok := strings.Cut(header, " ")
+		if !ok || typ != "Bearer" || token == "" {
+			return "", false, false
+		}
+		return token, false, true
+	}
+
+	cookie, err := huma.ReadCookie(ctx, AccessCookie)
+	if err != nil || cookie.Value == "" {
+		return "", false, false
+	}
+
+	return cookie.Value, true, true
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// validCSRF implements the double submit check: the header must match the csrf
+// cookie, which a cross-site page can neither read nor set.
+func validCSRF(ctx huma.Context) bool {
+	cookie, err := huma.ReadCookie(ctx, CSRFCookie)
+	if err != nil || cookie.Value == "" {
+		return false
 	}
+
+	header := ctx.Header(CSRFHeader)
+	return subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) == 1
 }
@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"context"
+	"core/internal/authentication"
+	"core/internal/authorization"
+	"core/internal/member"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+const policyMetadataKey = "authorization"
+
+var ErrNotFound = errors.New("not found")
+
+// Policy declares who may call an operation. Every operation behind
+// Authorized must declare exactly one policy.
+type Policy struct {
+	// Permission is checked against the club owning the path parameters.
+	Permission authorization.Permission
+	// Self only admits the user referenced by the userId path parameter.
+	Self bool
+	// Authenticated admits any logged in user, leaving further checks to the handler.
+	Authenticated bool
+	// AllowUnverified admits callers whose email address is not verified yet.
+	AllowUnverified bool
+	// Scope admits API keys holding it. Without one, keys are refused.
+	Scope authorization.Scope
+}
+
+// ClubResolver returns the ID of the club owning the entity with the given ID,
+// or ErrNotFound if the entity does not exist.
+type ClubResolver func(ctx context.Context, id uuid.UUID) (uuid.UUID, error)
+
+// clubParams lists the path parameters a club can be resolved from, in order
+// of precedence. The club ID itself needs no resolver.
+var clubParams = []string{"clubId", "gameId", "memberId", "matchId", "eventId", "linkId"}
+
+// Require declares that the caller needs the permission in the club owning
+// the operation's path parameters.
+func Require(permission authorization.Permission) func(o *huma.Operation) {
+	return withPolicy(Policy{Permission: permission})
+}
+
+// RequireSelf declares that the caller must be the user in the userId path parameter.
+func RequireSelf() func(o *huma.Operation) {
+	return withPolicy(Policy{Self: true})
+}
+
+// RequireAuthenticated declares that any authenticated user may call the operation.
+func RequireAuthenticated() func(o *huma.Operation) {
+	return withPolicy(Policy{Authenticated: true})
+}
+
+// AllowUnverified lets users with an unverified email address call the
+// operation. It amends the policy declared before it.
+func AllowUnverified(o *huma.Operation) {
+	policy, ok := policyOf(o)
+	if !ok {
+		panic(fmt.Sprintf("operation %s %s allows unverified users without a policy", o.Method, o.Path))
+	}
+	policy.AllowUnverified = true
+	withPolicy(policy)(o)
+}
+
+// AllowKeys lets API keys with the scope call the operation. It amends the
+// policy declared before it.
+func AllowKeys(scope authorization.Scope) func(o *huma.Operation) {
+	return func(o *huma.Operation) {
+		policy, ok := policyOf(o)
+		if !ok {
+			panic(fmt.Sprintf("operation %s %s allows api keys without a policy", o.Method, o.Path))
+		}
+		policy.Scope = scope
+		withPolicy(policy)(o)
+	}
+}
+
+func withPolicy(policy Policy) func(o *huma.Operation) {
+	return func(o *huma.Operation) {
+		if o.Metadata == nil {
+			o.Metadata = map[string]any{}
+		}
+		o.Metadata[policyMetadataKey] = policy
+	}
+}
+
+func policyOf(o *huma.Operation) (Policy, bool) {
+	if o == nil || o.Metadata == nil {
+		return Policy{}, false
+	}
+
+	policy, ok := o.Metadata[policyMetadataKey].(Policy)
+	return policy, ok
+}
+
+// RequirePolicy is a group modifier that refuses to register operations
+// without an authorization policy, so no route can be added unprotected.
+func RequirePolicy(o *huma.Operation) {
+	if _, ok := policyOf(o); !ok {
+		panic(fmt.Sprintf("operation %s %s has no authorization policy", o.Method, o.Path))
+	}
+}
+
+// Authorized enforces the policy declared on each operation. It must run after
+// Authenticated, and denies operations that declare no policy.
+func Authorized(authorizationService authorization.Service, resolvers map[string]ClubResolver) func(ctx huma.Context, next func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		policy, ok := policyOf(ctx.Operation())
+		if !ok {
+			ctx.SetStatus(http.StatusForbidden)
+			return
+		}
+
+		principal, ok := authentication.PrincipalFrom(ctx.Context())
+		if !ok {
+			ctx.SetStatus(http.StatusUnauthorized)
+			return
+		}
+		userID := principal.UserID
+
+		if !principal.EmailVerified && !policy.AllowUnverified {
+			ctx.SetStatus(http.StatusForbidden)
+			return
+		}
+
+		if key := principal.APIKey; key != nil {
+			if policy.Scope == "" || !key.HasScope(policy.Scope) {
+				ctx.SetStatus(http.StatusForbidden)
+				return
+			}
+
+			// Club keys hold their scopes in their own club, without a role
+			if key.ClubID != nil {
+				if policy.Permission == "" {
+					ctx.SetStatus(http.StatusForbidden)
+					return
+				}
+
+				clubID, status := resolveClub(ctx, resolvers)
+				if status != 0 {
+					ctx.SetStatus(status)
+					return
+				}
+				if clubID != *key.ClubID {
+					ctx.SetStatus(http.StatusForbidden)
+					return
+				}
+
+				next(ctx)
+				return
+			}
+		}
+
+		switch {
+		case policy.Authenticated:
+		case policy.Self:
+			if ctx.Param("userId") != userID.String() {
+				ctx.SetStatus(http.StatusForbidden)
+				return
+			}
+		default:
+			clubID, status := resolveClub(ctx, resolvers)
+			if status != 0 {
+				ctx.SetStatus(status)
+				return
+			}
+
+			// Prefer the role embedded in the token over querying the memberships
+			role := member.RoleNone
+			if membership, ok := principal.Memberships[clubID]; ok {
+				role = membership.Role
+			} else {
+				var err error
+				role, err = authorizationService.GetRole(ctx.Context(), userID, clubID)
+				if err != nil {
+					ctx.SetStatus(http.StatusInternalServerError)
+					return
+				}
+			}
+
+			err := authorizationService.RequireRole(ctx.Context(), clubID, role, policy.Permission)
+			if err == nil && !principal.TwoFactor {
+				err = authorizationService.RequireTwoFactor(ctx.Context(), clubID, role)
+			}
+			if err != nil {
+				if errors.Is(err, authorization.ErrForbidden) || errors.Is(err, authorization.ErrTwoFactorRequired) {
+					ctx.SetStatus(http.StatusForbidden)
+					return
+				}
+				ctx.SetStatus(http.StatusInternalServerError)
+				return
+			}
+		}
+
+		next(ctx)
+	}
+}
+
+// resolveClub finds the club owning the first resolvable path parameter. It
+// returns a non-zero HTTP status if the club cannot be determined.
+func resolveClub(ctx huma.Context, resolvers map[string]ClubResolver) (uuid.UUID, int) {
+	for _, param := range clubParams {
+		value := ctx.Param(param)
+		if value == "" {
+			continue
+		}
+
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return uuid.Nil, http.StatusBadRequest
+		}
+
+		if param == "clubId" {
+			return id, 0
+		}
+
+		resolve, ok := resolvers[param]
+		if !ok {
+			continue
+		}
+
+		clubID, err := resolve(ctx.Context(), id)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return uuid.Nil, http.StatusNotFound
+			}
+			return uuid.Nil, http.StatusInternalServerError
+		}
+
+		return clubID, 0
+	}
+
+	// A permission was declared on a route without any club to check it against
+	return uuid.Nil, http.StatusInternalServerError
+}
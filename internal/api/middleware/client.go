@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"core/internal/authentication"
+	"net"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// ClientInfo stores the requesting client's user agent and IP in the context.
+func ClientInfo() func(ctx huma.Context, next func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		ip, _, err := net.SplitHostPort(ctx.RemoteAddr())
+		if err != nil {
+			ip = ctx.RemoteAddr()
+		}
+
+		client := authentication.Client{
+			UserAgent: ctx.Header("User-Agent"),
+			IP:        ip,
+		}
+
+		next(huma.WithValue(ctx, authentication.ClientContextKey, client))
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"core/internal/authentication"
+	"core/internal/ratelimit"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+const rateLimitMetadataKey = "rateLimit"
+
+// RateLimit counts the operation against the limits of the route group.
+// Operations in the same group share their counts.
+func RateLimit(group string) func(o *huma.Operation) {
+	return func(o *huma.Operation) {
+		if o.Metadata == nil {
+			o.Metadata = map[string]any{}
+		}
+		o.Metadata[rateLimitMetadataKey] = group
+		o.Errors = append(o.Errors, http.StatusTooManyRequests)
+	}
+}
+
+// RateLimited enforces the per IP and global limits of each operation's route
+// group, answering 429 with a Retry-After header once one is exceeded. Per
+// account limits depend on the request body and are left to the handlers.
+// It must run after ClientInfo.
+func RateLimited(limiter ratelimit.Limiter, groups map[string]ratelimit.Rules) func(ctx huma.Context, next func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		group, ok := rateLimitGroup(ctx.Operation())
+		if !ok {
+			next(ctx)
+			return
+		}
+		rules := groups[group]
+
+		client := authentication.ClientFrom(ctx.Context())
+
+		err := limiter.Allow(ctx.Context(), group+":ip:"+client.IP, rules.PerIP)
+		if err == nil {
+			err = limiter.Allow(ctx.Context(), group+":global", rules.Global)
+		}
+
+		var limited *ratelimit.LimitedError
+		if errors.As(err, &limited) {
+			ctx.SetHeader("Retry-After", strconv.Itoa(limited.RetryAfterSeconds()))
+			ctx.SetStatus(http.StatusTooManyRequests)
+			return
+		}
+		if err != nil {
+			ctx.SetStatus(http.StatusInternalServerError)
+			return
+		}
+
+		next(ctx)
+	}
+}
+
+func rateLimitGroup(o *huma.Operation) (string, bool) {
+	if o == nil || o.Metadata == nil {
+		return "", false
+	}
+
+	group, ok := o.Metadata[rateLimitMetadataKey].(string)
+	return group, ok
+}
@@ -2,49 +2,144 @@ package api
 
 import (
 	"core/internal/api/handlers"
+	"core/internal/api/middleware"
+	"core/internal/authorization"
 
 	"github.com/danielgtaylor/huma/v2"
 )
 
+func addWellKnownRoutes(api huma.API, h *handlers.Handler) {
+	huma.Get(api, "/.well-known/jwks.json", h.GetJWKS)
+}
+
 func addPublicRoutes(g *huma.Group, h *handlers.Handler) {
+	login := middleware.RateLimit(RateLimitLogin)
+	signup := middleware.RateLimit(RateLimitSignup)
+	recovery := middleware.RateLimit(RateLimitRecovery)
+
 	// Authentication
-	huma.Post(g, "/auth/signup", h.Signup)
-	huma.Post(g, "/auth/login", h.Login)
+	huma.Post(g, "/auth/signup", h.Signup, signup)
+	huma.Post(g, "/auth/login", h.Login, login)
+	huma.Post(g, "/auth/login/two-factor", h.LoginTwoFactor, login)
+	huma.Post(g, "/auth/refresh", h.Refresh)
+	huma.Post(g, "/auth/verify-email", h.VerifyEmail, recovery)
+	huma.Post(g, "/auth/password-reset", h.RequestPasswordReset, recovery)
+	huma.Post(g, "/auth/password-reset/confirm", h.ResetPassword, recovery)
+	huma.Get(g, "/auth/providers", h.GetProviders)
+	huma.Post(g, "/auth/oidc/:provider", h.StartOIDCLogin)
+	huma.Post(g, "/auth/oidc/:provider/callback", h.OIDCLoginCallback)
 }
 
+// addAuthRoutes registers the routes behind authentication. Every route must
+// declare its authorization policy, which the middleware enforces. Users must
+// verify their email address first, unless a route allows unverified users.
+// API keys may only call routes allowing their scope.
 func addAuthRoutes(g *huma.Group, h *handlers.Handler) {
+	authenticated := middleware.RequireAuthenticated()
+	self := middleware.RequireSelf()
+	require := middleware.Require
+	unverified := middleware.AllowUnverified
+	keys := middleware.AllowKeys
+
 	// Authentication
-	huma.Post(g, "/auth/logout", h.Logout)
-	huma.Post(g, "/auth/refresh", h.Refresh)
-	huma.Post(g, "/auth/password", h.ChangePassword)
+	huma.Post(g, "/auth/logout", h.Logout, authenticated, unverified)
+	huma.Post(g, "/auth/password", h.ChangePassword, authenticated, unverified)
+	huma.Post(g, "/auth/verify-email/resend", h.ResendVerificationEmail, authenticated, unverified, middleware.RateLimit(RateLimitRecovery))
+	huma.Get(g, "/auth/sessions", h.GetSessions, authenticated, unverified)
+	huma.Delete(g, "/auth/sessions", h.DeleteSessions, authenticated, unverified)
+	huma.Delete(g, "/auth/sessions/:sessionId", h.DeleteSession, authenticated, unverified)
+	huma.Get(g, "/auth/two-factor", h.GetTwoFactor, authenticated, unverified)
+	huma.Post(g, "/auth/two-factor", h.EnrollTwoFactor, authenticated, unverified)
+	huma.Post(g, "/auth/two-factor/confirm", h.ConfirmTwoFactor, authenticated, unverified)
+	huma.Post(g, "/auth/two-factor/recovery-codes", h.RegenerateRecoveryCodes, authenticated, unverified)
+	huma.Post(g, "/auth/two-factor/disable", h.DisableTwoFactor, authenticated, unverified)
+	huma.Get(g, "/auth/identities", h.GetIdentities, authenticated, unverified)
+	huma.Post(g, "/auth/identities/:provider", h.StartLinkIdentity, authenticated, unverified)
+	huma.Post(g, "/auth/identities/:provider/callback", h.LinkIdentityCallback, authenticated, unverified)
+	huma.Delete(g, "/auth/identities/:provider", h.UnlinkIdentity, authenticated, unverified)
+	huma.Get(g, "/auth/tokens", h.GetPersonalTokens, authenticated)
+	huma.Post(g, "/auth/tokens", h.PostPersonalToken, authenticated)
+	huma.Delete(g, "/auth/tokens/:keyId", h.DeletePersonalToken, authenticated)
 
 	// Users
-	huma.Delete(g, "/users/:userId", h.DeleteUser)
-	huma.Put(g, "/users/:userId", h.UpdateUser)
-	huma.Get(g, "/users/:userId/clubs", h.GetMemberships)
+	huma.Delete(g, "/users/:userId", h.DeleteUser, self, unverified)
+	huma.Post(g, "/users/:userId/restore", h.RestoreUser, self, unverified)
+	huma.Get(g, "/users/:userId/export", h.ExportUser, self, unverified)
+	huma.Get(g, "/users/:userId/profile", h.GetProfile, authenticated)
+	huma.Put(g, "/users/:userId/profile", h.UpdateProfile, self, unverified)
+	huma.Get(g, "/users/:userId/avatar", h.GetAvatar, authenticated)
+	huma.Put(g, "/users/:userId/avatar", h.PutAvatar, self, unverified)
+	huma.Delete(g, "/users/:userId/avatar", h.DeleteAvatar, self, unverified)
+	huma.Put(g, "/users/:userId", h.UpdateUser, self, unverified)
+	huma.Get(g, "/users/:userId/clubs", h.GetMemberships, self, unverified)
+	huma.Get(g, "/users/:userId/invites", h.GetUserInvites, self, unverified)
+	huma.Get(g, "/users/:userId/feed", h.GetUserFeed, self)
 
 	// Clubs
-	huma.Post(g, "/clubs", h.CreateClub)
-	huma.Put(g, "/clubs/:clubId", h.UpdateClub)
-	huma.Delete(g, "/clubs/:clubId", h.DeleteClub)
-	huma.Get(g, "/clubs/:clubId/members", h.GetMembersInClub)
-	huma.Delete(g, "/clubs/:clubId/members/:memberId", h.RemoveMemberFromClub)
-	huma.Put(g, "/clubs/:clubId/members/:memberId", h.UpdateMemberRole)
-	huma.Post(g, "/clubs/:clubId/matches", h.PostClubMatch)
-	huma.Get(g, "/clubs/:clubId/matches", h.GetClubMatches)
-	huma.Get(g, "/clubs/:clubId/games", h.GetClubGames)
-	huma.Post(g, "/clubs/:clubId/games", h.PostClubGame)
+	huma.Get(g, "/clubs", h.SearchClubs, authenticated)
+	huma.Post(g, "/clubs", h.CreateClub, authenticated)
+	huma.Put(g, "/clubs/:clubId", h.UpdateClub, require(authorization.PermissionManageClub))
+	huma.Delete(g, "/clubs/:clubId", h.DeleteClub, require(authorization.PermissionDeleteClub))
+	huma.Post(g, "/clubs/:clubId/restore", h.RestoreClub, authenticated)
+	huma.Put(g, "/clubs/:clubId/security", h.PutClubSecurity, require(authorization.PermissionManageSecurity))
+	huma.Get(g, "/clubs/:clubId/permissions", h.GetClubPermissions, require(authorization.PermissionViewClub))
+	huma.Put(g, "/clubs/:clubId/permissions", h.PutClubPermission, require(authorization.PermissionManagePermissions))
+	huma.Delete(g, "/clubs/:clubId/permissions/:role/:permission", h.DeleteClubPermission, require(authorization.PermissionManagePermissions))
+	huma.Get(g, "/clubs/:clubId/members", h.GetMembersInClub, require(authorization.PermissionViewClub), keys(authorization.ScopeClubRead))
+	huma.Delete(g, "/clubs/:clubId/members/:memberId", h.RemoveMemberFromClub, require(authorization.PermissionManageMembers))
+	huma.Put(g, "/clubs/:clubId/members/:memberId", h.UpdateMemberRole, require(authorization.PermissionManageMembers))
+	huma.Post(g, "/clubs/:clubId/members/:memberId/merge", h.MergeClubMembers, require(authorization.PermissionManageMembers))
+	huma.Put(g, "/clubs/:clubId/members/:memberId/nickname", h.UpdateMemberNickname, require(authorization.PermissionViewClub))
+	huma.Post(g, "/clubs/:clubId/guests", h.PostClubGuest, require(authorization.PermissionRecordMatches), keys(authorization.ScopeMatchesWrite))
+	huma.Post(g, "/clubs/:clubId/guests/:guestId/claim", h.ClaimClubGuest, require(authorization.PermissionManageMembers))
+	huma.Get(g, "/clubs/:clubId/invites", h.GetClubInvites, require(authorization.PermissionManageInvites))
+	huma.Post(g, "/clubs/:clubId/invites", h.PostClubInvite, require(authorization.PermissionManageInvites))
+	huma.Post(g, "/clubs/:clubId/join", h.PostClubJoin, authenticated)
+	huma.Post(g, "/clubs/:clubId/leave", h.LeaveClub, authenticated)
+	huma.Get(g, "/clubs/:clubId/ownership-transfer", h.GetOwnershipTransfer, require(authorization.PermissionViewClub))
+	huma.Post(g, "/clubs/:clubId/ownership-transfer", h.PostOwnershipTransfer, require(authorization.PermissionTransferOwnership))
+	huma.Delete(g, "/clubs/:clubId/ownership-transfer", h.DeleteOwnershipTransfer, require(authorization.PermissionTransferOwnership))
+	huma.Post(g, "/clubs/:clubId/ownership-transfer/accept", h.AcceptOwnershipTransfer, require(authorization.PermissionViewClub))
+	huma.Post(g, "/clubs/:clubId/ownership-transfer/decline", h.DeclineOwnershipTransfer, require(authorization.PermissionViewClub))
+	huma.Get(g, "/clubs/:clubId/invite-links", h.GetClubInviteLinks, require(authorization.PermissionManageInvites))
+	huma.Post(g, "/clubs/:clubId/invite-links", h.PostClubInviteLink, require(authorization.PermissionManageInvites))
+	huma.Post(g, "/clubs/:clubId/matches", h.PostClubMatch, require(authorization.PermissionRecordMatches), keys(authorization.ScopeMatchesWrite))
+	huma.Get(g, "/clubs/:clubId/matches", h.GetClubMatches, require(authorization.PermissionViewClub), keys(authorization.ScopeMatchesRead))
+	huma.Get(g, "/clubs/:clubId/games", h.GetClubGames, require(authorization.PermissionViewClub), keys(authorization.ScopeClubRead))
+	huma.Post(g, "/clubs/:clubId/games", h.PostClubGame, require(authorization.PermissionManageGames))
+	huma.Post(g, "/clubs/:clubId/games/:gameId/restore", h.RestoreGame, require(authorization.PermissionManageGames))
+	huma.Get(g, "/clubs/:clubId/events", h.GetClubEvents, require(authorization.PermissionViewClub), keys(authorization.ScopeEventsRead))
+	huma.Post(g, "/clubs/:clubId/events", h.PostClubEvent, require(authorization.PermissionManageEvents), keys(authorization.ScopeEventsWrite))
+	huma.Get(g, "/clubs/:clubId/api-keys", h.GetClubAPIKeys, require(authorization.PermissionManageAPIKeys))
+	huma.Post(g, "/clubs/:clubId/api-keys", h.PostClubAPIKey, require(authorization.PermissionManageAPIKeys))
+	huma.Delete(g, "/clubs/:clubId/api-keys/:keyId", h.DeleteClubAPIKey, require(authorization.PermissionManageAPIKeys))
+	huma.Get(g, "/clubs/:clubId/audit", h.GetClubAuditLog, require(authorization.PermissionViewAuditLog))
+	huma.Get(g, "/clubs/:clubId/feed", h.GetClubFeed, require(authorization.PermissionViewClub), keys(authorization.ScopeClubRead))
+
+	// Invites, the handlers check which side of the invite the user is on
+	huma.Post(g, "/invites/:inviteId/accept", h.AcceptInvite, authenticated)
+	huma.Post(g, "/invites/:inviteId/reject", h.RejectInvite, authenticated)
+	huma.Delete(g, "/invite-links/:linkId", h.DeleteInviteLink, require(authorization.PermissionManageInvites))
+	huma.Post(g, "/invite-links/:code/redeem", h.RedeemInviteLink, authenticated)
 
 	// Games
-	huma.Put(g, "/games/:gameId", h.PutGame)
-	huma.Delete(g, "/games/:gameId", h.DeleteGame)
+	huma.Put(g, "/games/:gameId", h.PutGame, require(authorization.PermissionManageGames))
+	huma.Delete(g, "/games/:gameId", h.DeleteGame, require(authorization.PermissionManageGames))
 
 	// Game Modes
-	huma.Get(g, "/games/:gameId/modes", h.GetGameModes)
-	huma.Post(g, "/games/:gameId/modes", h.PostGameMode)
-	huma.Delete(g, "/games/:gameId/modes/:mode", h.DeleteGameMode)
+	huma.Get(g, "/games/:gameId/modes", h.GetGameModes, require(authorization.PermissionViewClub), keys(authorization.ScopeClubRead))
+	huma.Post(g, "/games/:gameId/modes", h.PostGameMode, require(authorization.PermissionManageGames))
+	huma.Delete(g, "/games/:gameId/modes/:mode", h.DeleteGameMode, require(authorization.PermissionManageGames))
+
+	// Events
+	huma.Delete(g, "/events/:eventId", h.DeleteEvent, require(authorization.PermissionManageEvents), keys(authorization.ScopeEventsWrite))
+	huma.Post(g, "/events/:eventId/checkin", h.PostEventCheckIn, require(authorization.PermissionViewClub))
+	huma.Get(g, "/events/:eventId/attendees", h.GetEventAttendees, require(authorization.PermissionViewClub), keys(authorization.ScopeEventsRead))
+	huma.Get(g, "/events/:eventId/players", h.GetEventPlayers, require(authorization.PermissionViewClub), keys(authorization.ScopeEventsRead))
+	huma.Get(g, "/events/:eventId/teams", h.GetEventTeams, require(authorization.PermissionViewClub), keys(authorization.ScopeEventsRead))
 
 	// Statistics
-	huma.Get(g, "/members/:memberId/statistics", h.GetMemberStatistics)
-	huma.Get(g, "/games/:gameId/rankings", h.GetGameRankings)
+	huma.Get(g, "/members/:memberId/statistics", h.GetMemberStatistics, require(authorization.PermissionViewClub), keys(authorization.ScopeStatsRead))
+	huma.Get(g, "/members/:memberId/attendance", h.GetMemberAttendance, require(authorization.PermissionViewClub), keys(authorization.ScopeStatsRead))
+	huma.Get(g, "/games/:gameId/rankings", h.GetGameRankings, require(authorization.PermissionViewClub), keys(authorization.ScopeStatsRead))
 }
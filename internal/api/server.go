@@ -5,7 +5,9 @@ import (
 	"core/internal/api/handlers"
 	"core/internal/api/middleware"
 	"core/internal/authentication"
+	"core/internal/authorization"
 	"core/internal/cache"
+	"core/internal/ratelimit"
 	"fmt"
 	"log/slog"
 
@@ -15,9 +17,18 @@ import (
 	echoMiddleware "github.com/labstack/echo/v4/middleware"
 )
 
+// Route groups sharing rate limits
+const (
+	RateLimitLogin    = "login"
+	RateLimitSignup   = "signup"
+	RateLimitRecovery = "recovery"
+)
+
 type Config struct {
 	Port    int
 	Version string
+	// RateLimits holds the limits of each route group
+	RateLimits map[string]ratelimit.Rules
 }
 
 type Server struct {
@@ -27,7 +38,7 @@ type Server struct {
 	l      *slog.Logger
 }
 
-func NewServer(config Config, version string, l *slog.Logger, handler *handlers.Handler, authService authentication.Service, cacheService cache.Service) *Server {
+func NewServer(config Config, version string, l *slog.Logger, handler *handlers.Handler, authService authentication.Service, authorizationService authorization.Service, cacheService cache.Service, limiter ratelimit.Limiter) *Server {
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
@@ -45,14 +56,20 @@ func NewServer(config Config, version string, l *slog.Logger, handler *handlers.
 
 	api := humaecho.New(e, humaConfig)
 	api.UseMiddleware(middleware.CanonicalLogger(l))
+	api.UseMiddleware(middleware.ClientInfo())
+	api.UseMiddleware(middleware.RateLimited(limiter, config.RateLimits))
 
 	authGroup := huma.NewGroup(api, "/api/v1")
+	authGroup.UseSimpleModifier(middleware.RequirePolicy)
 	authGroup.UseMiddleware(middleware.Authenticated(authService, cacheService))
+	authGroup.UseMiddleware(middleware.Authorized(authorizationService, handler.ClubResolvers()))
 	addAuthRoutes(authGroup, handler)
 
 	baseGroup := huma.NewGroup(api, "/api")
 	addPublicRoutes(baseGroup, handler)
 
+	addWellKnownRoutes(api, handler)
+
 	return &Server{
 		config: config,
 		e:      e,
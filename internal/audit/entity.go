@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Action string
+
+const (
+	ActionClubUpdated         Action = "club.updated"
+	ActionClubSecurityChanged Action = "club.security_changed"
+	ActionClubDeleted         Action = "club.deleted"
+	ActionClubRestored        Action = "club.restored"
+	ActionPermissionChanged   Action = "permission.changed"
+	// ActionPermissionReset restores a role's default for the permission
+	ActionPermissionReset Action = "permission.reset"
+	// ActionMemberJoined is recorded with the new member as target, whether
+	// they joined themselves or their request to join was accepted
+	ActionMemberJoined      Action = "member.joined"
+	ActionMemberRoleChanged Action = "member.role_changed"
+	ActionMemberRenamed     Action = "member.renamed"
+	ActionMemberRemoved     Action = "member.removed"
+	// ActionMemberLeft is recorded with the leaving member as target and actor
+	ActionMemberLeft    Action = "member.left"
+	ActionMembersMerged Action = "members.merged"
+	ActionGuestCreated  Action = "guest.created"
+	ActionGuestClaimed  Action = "guest.claimed"
+	// ActionInviteCreated is also recorded for requests to join, with the
+	// requesting user as actor
+	ActionInviteCreated     Action = "invite.created"
+	ActionInviteLinkCreated Action = "invite_link.created"
+	// ActionOwnershipTransferred is recorded with the new owner as actor
+	ActionOwnershipTransferred Action = "ownership.transferred"
+	ActionGameCreated          Action = "game.created"
+	ActionGameUpdated          Action = "game.updated"
+	ActionGameDeleted          Action = "game.deleted"
+	ActionGameRestored         Action = "game.restored"
+	ActionMatchRecorded        Action = "match.recorded"
+)
+
+// Actions lists every known action in a stable order.
+var Actions = []Action{
+	ActionClubUpdated,
+	ActionClubSecurityChanged,
+	ActionClubDeleted,
+	ActionClubRestored,
+	ActionPermissionChanged,
+	ActionPermissionReset,
+	ActionMemberJoined,
+	ActionMemberRoleChanged,
+	ActionMemberRenamed,
+	ActionMemberRemoved,
+	ActionMemberLeft,
+	ActionMembersMerged,
+	ActionGuestCreated,
+	ActionGuestClaimed,
+	ActionInviteCreated,
+	ActionInviteLinkCreated,
+	ActionOwnershipTransferred,
+	ActionGameCreated,
+	ActionGameUpdated,
+	ActionGameDeleted,
+	ActionGameRestored,
+	ActionMatchRecorded,
+}
+
+func (a Action) IsValid() bool {
+	for _, action := range Actions {
+		if a == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Entry records who did what in a club.
+type Entry struct {
+	ID     uuid.UUID `db:"id"`
+	ClubID uuid.UUID `db:"club_id"`
+	// ActorID is nil for actions taken by the system, or by users since deleted
+	ActorID   *uuid.UUID `db:"actor_id"`
+	Action    Action     `db:"action"`
+	TargetID  *uuid.UUID `db:"target_id"`
+	Details   Details    `db:"details"`
+	Before    Snapshot   `db:"before"`
+	After     Snapshot   `db:"after"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// NewEntry starts an entry for the action. A nil actor stands for the system
+// or an API key, a nil target for the club itself.
+func NewEntry(clubID, actorID uuid.UUID, action Action, targetID uuid.UUID) *Entry {
+	entry := &Entry{
+		ClubID: clubID,
+		Action: action,
+	}
+	if actorID != uuid.Nil {
+		entry.ActorID = &actorID
+	}
+	if targetID != uuid.Nil {
+		entry.TargetID = &targetID
+	}
+
+	return entry
+}
+
+// Filter narrows down a club's entries. Empty fields match everything.
+type Filter struct {
+	Action   Action
+	ActorID  *uuid.UUID
+	TargetID *uuid.UUID
+	Since    *time.Time
+	Until    *time.Time
+	Limit    int
+	Offset   int
+}
+
+// Details hold whatever else is worth knowing about an action, stored as JSON.
+type Details map[string]any
+
+func (d Details) Value() (driver.Value, error) {
+	if d == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode details: %w", err)
+	}
+
+	return string(b), nil
+}
+
+func (d *Details) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("cannot scan %T into details", src)
+	}
+
+	return json.Unmarshal(b, d)
+}
+
+// Snapshot holds the state of an entry's target before or after the action,
+// stored as JSON. It is nil, and NULL, where there was none.
+type Snapshot map[string]any
+
+func (s Snapshot) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	return string(b), nil
+}
+
+func (s *Snapshot) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("cannot scan %T into snapshot", src)
+	}
+
+	return json.Unmarshal(b, s)
+}
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository interface {
+	GetEntries(ctx context.Context, clubID uuid.UUID, filter Filter) ([]Entry, error)
+}
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (r *repository) GetEntries(ctx context.Context, clubID uuid.UUID, filter Filter) ([]Entry, error) {
+	var entries []Entry
+
+	err := r.db.SelectContext(ctx, &entries, `
+		SELECT *
+		FROM audit_log
+		WHERE club_id = $1
+		AND ($2 = '' OR action = $2)
+		AND ($3::uuid IS NULL OR actor_id = $3)
+		AND ($4::uuid IS NULL OR target_id = $4)
+		AND ($5::timestamptz IS NULL OR created_at >= $5)
+		AND ($6::timestamptz IS NULL OR created_at < $6)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $7 OFFSET $8`,
+		clubID, filter.Action, filter.ActorID, filter.TargetID, filter.Since, filter.Until, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Insert writes the entry with db, which may be a transaction so the entry is
+// only kept if the action it records is.
+func Insert(ctx context.Context, db sqlx.ExecerContext, entry *Entry) error {
+	_, err := db.ExecContext(ctx,
+		"INSERT INTO audit_log (club_id, actor_id, action, target_id, details, before, after) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		entry.ClubID, entry.ActorID, entry.Action, entry.TargetID, entry.Details, entry.Before, entry.After)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// WithEntry runs fn in a transaction that also inserts the entry, so the entry
+// is kept exactly when the change it records is. fn may fill in the entry's
+// target, e.g. with the ID of a row it inserts.
+func WithEntry(ctx context.Context, db *sqlx.DB, entry *Entry, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := Insert(ctx, tx, entry); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const maxLimit = 100
+
+var ErrInvalidAction = fmt.Errorf("invalid action")
+
+type Service interface {
+	// GetEntries returns the club's entries matching the filter, newest first.
+	GetEntries(ctx context.Context, clubID uuid.UUID, filter Filter) ([]Entry, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{
+		repo: repo,
+	}
+}
+
+func (s *service) GetEntries(ctx context.Context, clubID uuid.UUID, filter Filter) ([]Entry, error) {
+	if filter.Action != "" && !filter.Action.IsValid() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAction, filter.Action)
+	}
+	if filter.Limit <= 0 || filter.Limit > maxLimit {
+		filter.Limit = maxLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	entries, err := s.repo.GetEntries(ctx, clubID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit log: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,205 @@
+package authentication
+
+import (
+	"context"
+	"core/internal/authorization"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// APIKeyPrefix starts every API key, telling them apart from JWTs.
+	APIKeyPrefix = "mak_"
+	// apiKeyDisplayLength is how much of a key is kept in the clear, to
+	// recognize it in listings.
+	apiKeyDisplayLength = len(APIKeyPrefix) + 8
+	// lastUsedInterval bounds how often a key's last use is written.
+	lastUsedInterval = time.Minute
+)
+
+var (
+	ErrAPIKeyNotFound = fmt.Errorf("api key not found")
+	ErrInvalidScope   = fmt.Errorf("invalid scope")
+)
+
+// APIKey authenticates integrations without a login. Personal access tokens
+// act as their user, club keys only within their club. Only the keyed hash of
+// the key is stored.
+type APIKey struct {
+	ID         uuid.UUID    `db:"id"`
+	UserID     *uuid.UUID   `db:"user_id"`
+	ClubID     *uuid.UUID   `db:"club_id"`
+	CreatedBy  *uuid.UUID   `db:"created_by"`
+	Name       string       `db:"name"`
+	Prefix     string       `db:"prefix"`
+	Hash       []byte       `db:"hash"`
+	Scopes     APIKeyScopes `db:"scopes"`
+	ExpiresAt  *time.Time   `db:"expires_at"`
+	LastUsedAt *time.Time   `db:"last_used_at"`
+	CreatedAt  time.Time    `db:"created_at"`
+	// TwoFactor is set for personal keys created from a session that passed a
+	// second factor. It only counts while the user keeps two-factor enabled.
+	TwoFactor bool `db:"two_factor"`
+}
+
+func (k *APIKey) HasScope(scope authorization.Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// APIKeyScopes are stored space separated, like OAuth scopes.
+type APIKeyScopes []authorization.Scope
+
+func (s APIKeyScopes) Value() (driver.Value, error) {
+	scopes := make([]string, len(s))
+	for i, scope := range s {
+		scopes[i] = string(scope)
+	}
+
+	return strings.Join(scopes, " "), nil
+}
+
+func (s *APIKeyScopes) Scan(src any) error {
+	var value string
+	switch v := src.(type) {
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into scopes", src)
+	}
+
+	fields := strings.Fields(value)
+	scopes := make(APIKeyScopes, len(fields))
+	for i, field := range fields {
+		scopes[i] = authorization.Scope(field)
+	}
+	*s = scopes
+
+	return nil
+}
+
+func (s *service) CreateAPIKey(ctx context.Context, key *APIKey) (string, error) {
+	if len(key.Scopes) == 0 {
+		return "", ErrInvalidScope
+	}
+	for _, scope := range key.Scopes {
+		if !scope.IsValid() {
+			return "", fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	token := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	key.Prefix = token[:apiKeyDisplayLength]
+	key.Hash = s.hashUserToken(token)
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return "", fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return token, nil
+}
+
+func (s *service) GetUserAPIKeys(ctx context.Context, userID uuid.UUID) ([]APIKey, error) {
+	keys, err := s.repo.GetUserAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (s *service) GetClubAPIKeys(ctx context.Context, clubID uuid.UUID) ([]APIKey, error) {
+	keys, err := s.repo.GetClubAPIKeys(ctx, clubID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (s *service) RevokeUserAPIKey(ctx context.Context, userID, keyID uuid.UUID) error {
+	if err := s.repo.DeleteUserAPIKey(ctx, userID, keyID); err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return ErrAPIKeyNotFound
+		}
+		return fmt.Errorf("failed to delete api key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) RevokeClubAPIKey(ctx context.Context, clubID, keyID uuid.UUID) error {
+	if err := s.repo.DeleteClubAPIKey(ctx, clubID, keyID); err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return ErrAPIKeyNotFound
+		}
+		return fmt.Errorf("failed to delete api key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) VerifyAPIKey(ctx context.Context, token string) (*Principal, error) {
+	if !strings.HasPrefix(token, APIKeyPrefix) {
+		return nil, ErrInvalidToken
+	}
+
+	key, err := s.repo.GetAPIKeyByHash(ctx, s.hashUserToken(token))
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	if key.ExpiresAt != nil && !time.Now().Before(*key.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	// Busy integrations would otherwise write on every request
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > lastUsedInterval {
+		if err := s.repo.TouchAPIKey(ctx, key.ID); err != nil {
+			return nil, fmt.Errorf("failed to update api key last use: %w", err)
+		}
+	}
+
+	principal := &Principal{
+		APIKey:        key,
+		EmailVerified: true,
+	}
+	if key.UserID != nil {
+		u, err := s.userService.GetUser(ctx, *key.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		principal.UserID = u.ID
+		principal.EmailVerified = u.EmailVerified()
+
+		if key.TwoFactor {
+			status, err := s.GetTwoFactorStatus(ctx, u.ID)
+			if err != nil {
+				return nil, err
+			}
+			principal.TwoFactor = status.Enabled
+		}
+	}
+
+	return principal, nil
+}
@@ -5,16 +5,26 @@ import (
 	"core/internal/subscription"
 	"encoding/json"
 	"fmt"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+func init() {
+	// Issue times are compared against revocations and membership changes, so
+	// a token issued in the same second as one must still be told apart.
+	jwt.TimePrecision = time.Millisecond
+}
+
 type AccessClaims struct {
 	jwt.RegisteredClaims
-	SubscriptionTier subscription.Tier          `json:"s"`
-	Organizations    map[int]ClaimsOrganization `json:"o"` // Map of organization ID to tier and role
+	Session          string                           `json:"sid"`
+	EmailVerified    bool                             `json:"ev"`
+	TwoFactor        bool                             `json:"tfa"` // Whether the session passed a second factor
+	SubscriptionTier subscription.Tier                `json:"s"`
+	Organizations    map[uuid.UUID]ClaimsOrganization `json:"o"` // Map of club ID to tier and role
 }
 
 type ClaimsOrganization struct {
@@ -22,6 +32,23 @@ type ClaimsOrganization struct {
 	Role member.Role
 }
 
+// Organizations are encoded as compact "id:tier:role" strings to keep tokens small.
+func (c AccessClaims) MarshalJSON() ([]byte, error) {
+	type alias AccessClaims
+	organizations := make([]string, 0, len(c.Organizations))
+	for id, org := range c.Organizations {
+		organizations = append(organizations, fmt.Sprintf("%s:%s:%s", id, org.Tier, org.Role))
+	}
+
+	return json.Marshal(&struct {
+		Organizations []string `json:"o"`
+		alias
+	}{
+		Organizations: organizations,
+		alias:         alias(c),
+	})
+}
+
 // Two step unmarshaling to first handle the general fields and then the nested organizations.
 func (c *AccessClaims) UnmarshalJSON(data []byte) error {
 	type alias AccessClaims
@@ -36,15 +63,16 @@ func (c *AccessClaims) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	c.Organizations = make(map[uuid.UUID]ClaimsOrganization, len(aux.Organizations))
 	for _, orgStr := range aux.Organizations {
 		parts := strings.Split(orgStr, ":")
 		if len(parts) != 3 {
 			return fmt.Errorf("invalid format in org claim: %s", orgStr)
 		}
 
-		id, err := strconv.Atoi(parts[0])
+		id, err := uuid.Parse(parts[0])
 		if err != nil {
-			return fmt.Errorf("org id is not an integer: %s", parts[0])
+			return fmt.Errorf("org id is not a uuid: %s", parts[0])
 		}
 
 		c.Organizations[id] = ClaimsOrganization{
@@ -56,6 +84,10 @@ func (c *AccessClaims) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// RefreshClaims identify the token by its ID (jti) within a family of rotated
+// refresh tokens, all descending from the same login.
 type RefreshClaims struct {
 	jwt.RegisteredClaims
+	Family    string `json:"fam"`
+	TwoFactor bool   `json:"tfa"`
 }
@@ -0,0 +1,74 @@
+package authentication
+
+import (
+	"core/internal/member"
+	"core/internal/subscription"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestAccessClaimsRoundTrip(t *testing.T) {
+	first, second := uuid.New(), uuid.New()
+	claims := AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   uuid.NewString(),
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour).Truncate(time.Second)),
+		},
+		Session:          "session",
+		EmailVerified:    true,
+		TwoFactor:        true,
+		SubscriptionTier: subscription.TierMinor,
+		Organizations: map[uuid.UUID]ClaimsOrganization{
+			first:  {Tier: subscription.TierFree, Role: member.RoleOwner},
+			second: {Tier: subscription.TierMajor, Role: member.RoleObserver},
+		},
+	}
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to marshal claims: %v", err)
+	}
+
+	// The organizations must be encoded once, as compact strings under "o".
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("failed to unmarshal raw claims: %v", err)
+	}
+	var organizations []string
+	if err := json.Unmarshal(raw["o"], &organizations); err != nil {
+		t.Fatalf("organizations are not encoded as strings: %s", raw["o"])
+	}
+	sort.Strings(organizations)
+	want := []string{
+		first.String() + ":free:owner",
+		second.String() + ":major:observer",
+	}
+	sort.Strings(want)
+	if !reflect.DeepEqual(organizations, want) {
+		t.Errorf("organizations encoded as %v, want %v", organizations, want)
+	}
+
+	var decoded AccessClaims
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal claims: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, claims) {
+		t.Errorf("claims changed in the round trip:\n got %+v\nwant %+v", decoded, claims)
+	}
+}
+
+func TestAccessClaimsUnmarshalInvalidOrganization(t *testing.T) {
+	for _, o := range []string{`["not-a-claim"]`, `["not-a-uuid:free:owner"]`} {
+		var claims AccessClaims
+		if err := json.Unmarshal([]byte(`{"sub":"user","o":`+o+`}`), &claims); err == nil {
+			t.Errorf("unmarshaling organizations %s succeeded, want an error", o)
+		}
+	}
+}
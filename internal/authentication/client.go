@@ -0,0 +1,18 @@
+package authentication
+
+import "context"
+
+// ClientContextKey is the context key the requesting client is stored under.
+const ClientContextKey contextKey = "client"
+
+// Client describes the device a request comes from, recorded on its session.
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// ClientFrom returns the requesting client, or an empty one if unknown.
+func ClientFrom(ctx context.Context) Client {
+	client, _ := ctx.Value(ClientContextKey).(Client)
+	return client
+}
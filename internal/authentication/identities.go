@@ -0,0 +1,273 @@
+package authentication
+
+import (
+	"context"
+	"core/internal/cache"
+	"core/internal/oidc"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OIDCFlowExpiry is how long the user has to complete a login at the provider.
+const OIDCFlowExpiry = 10 * time.Minute
+
+// maxNameLength matches the longest name accepted at signup.
+const maxNameLength = 20
+
+var (
+	ErrUnknownProvider  = fmt.Errorf("unknown provider")
+	ErrIdentityNotFound = fmt.Errorf("identity not found")
+	ErrIdentityLinked   = fmt.Errorf("identity is already linked")
+	// ErrEmailNotVerified is returned when a provider identity can't be matched
+	// to an account because the provider did not verify its email address.
+	ErrEmailNotVerified = fmt.Errorf("email address is not verified by the provider")
+	// ErrIdentityConflict is returned when an account with the email address
+	// exists but is not verified, so it can't be proven to belong to the same person.
+	ErrIdentityConflict = fmt.Errorf("an unverified account with this email address exists")
+	// ErrLastLoginMethod is returned when unlinking would lock the user out.
+	ErrLastLoginMethod = fmt.Errorf("cannot remove the only way to log in")
+)
+
+// Identity links a user to their account at an external provider.
+type Identity struct {
+	ID        uuid.UUID `db:"id"`
+	UserID    uuid.UUID `db:"user_id"`
+	Provider  string    `db:"provider"`
+	Subject   string    `db:"subject"`
+	Email     string    `db:"email"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (s *service) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (s *service) StartOIDC(ctx context.Context, providerName string, userID uuid.UUID) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to build authorization url: %w", err)
+	}
+
+	flow := cache.OIDCFlow{
+		Provider: providerName,
+		Verifier: verifier,
+		Nonce:    nonce,
+		UserID:   userID,
+	}
+	if err := s.cache.CreateOIDCFlow(ctx, state, flow, OIDCFlowExpiry); err != nil {
+		return "", "", fmt.Errorf("failed to create oidc flow: %w", err)
+	}
+
+	return authURL, state, nil
+}
+
+func (s *service) OIDCLogin(ctx context.Context, providerName, state, code string, client Client) (*LoginResult, error) {
+	identity, err := s.finishOIDC(ctx, providerName, state, code, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := s.identityUser(ctx, providerName, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(ctx, userID, client)
+}
+
+func (s *service) LinkIdentity(ctx context.Context, userID uuid.UUID, providerName, state, code string) error {
+	identity, err := s.finishOIDC(ctx, providerName, state, code, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.CreateIdentity(ctx, &Identity{
+		UserID:   userID,
+		Provider: providerName,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	if err != nil {
+		if errors.Is(err, ErrIdentityLinked) {
+			return ErrIdentityLinked
+		}
+		return fmt.Errorf("failed to create identity: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) GetIdentities(ctx context.Context, userID uuid.UUID) ([]Identity, error) {
+	identities, err := s.repo.GetUserIdentities(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identities: %w", err)
+	}
+
+	return identities, nil
+}
+
+func (s *service) UnlinkIdentity(ctx context.Context, userID uuid.UUID, providerName string) error {
+	u, err := s.userService.GetUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	identities, err := s.repo.GetUserIdentities(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get identities: %w", err)
+	}
+
+	// Accounts created through a provider have no password until one is reset
+	if u.Hash == "" && len(identities) <= 1 {
+		return ErrLastLoginMethod
+	}
+
+	if err := s.repo.DeleteIdentity(ctx, userID, providerName); err != nil {
+		if errors.Is(err, ErrIdentityNotFound) {
+			return ErrIdentityNotFound
+		}
+		return fmt.Errorf("failed to delete identity: %w", err)
+	}
+
+	return nil
+}
+
+// finishOIDC consumes the flow started for the user, nil when logging in, and
+// redeems the code at its provider.
+func (s *service) finishOIDC(ctx context.Context, providerName, state, code string, userID uuid.UUID) (*oidc.Identity, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	flow, found, err := s.cache.ConsumeOIDCFlow(ctx, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oidc flow: %w", err)
+	}
+	if !found || flow.Provider != providerName || flow.UserID != userID {
+		return nil, ErrInvalidToken
+	}
+
+	identity, err := provider.Exchange(ctx, code, flow.Verifier, flow.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrRejected) || errors.Is(err, oidc.ErrInvalidIDToken) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+		}
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	return identity, nil
+}
+
+// identityUser returns the user linked to the identity. Unknown identities
+// are linked to the account with the same verified email address, or get a
+// new account if there is none.
+func (s *service) identityUser(ctx context.Context, providerName string, identity *oidc.Identity) (uuid.UUID, error) {
+	existing, err := s.repo.GetIdentity(ctx, providerName, identity.Subject)
+	if err == nil {
+		return existing.UserID, nil
+	}
+	if !errors.Is(err, ErrIdentityNotFound) {
+		return uuid.Nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return uuid.Nil, ErrEmailNotVerified
+	}
+
+	exists, u, err := s.userService.GetUserByEmail(ctx, identity.Email)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get user by email: %w", err)
+	}
+
+	var userID uuid.UUID
+	switch {
+	case exists && !u.EmailVerified():
+		// Whoever signed up with the address may not own it
+		return uuid.Nil, ErrIdentityConflict
+	case exists:
+		userID = u.ID
+	default:
+		userID, err = s.createOIDCUser(ctx, identity)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	err = s.repo.CreateIdentity(ctx, &Identity{
+		UserID:   userID,
+		Provider: providerName,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	if err != nil {
+		if errors.Is(err, ErrIdentityLinked) {
+			return uuid.Nil, ErrIdentityLinked
+		}
+		return uuid.Nil, fmt.Errorf("failed to create identity: %w", err)
+	}
+
+	return userID, nil
+}
+
+// createOIDCUser creates a verified account without a password.
+func (s *service) createOIDCUser(ctx context.Context, identity *oidc.Identity) (uuid.UUID, error) {
+	userID, err := s.userService.CreateUser(ctx, identity.Email, identityName(identity), "")
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	if err := s.userService.SetEmailVerified(ctx, userID); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	if err := s.subscriptionService.Create(ctx, userID); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create subscription: %w", err)
+	}
+
+	return userID, nil
+}
+
+// identityName picks a name for a new account, falling back to the email's local part.
+func identityName(identity *oidc.Identity) string {
+	name := strings.TrimSpace(identity.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	runes := []rune(name)
+	if len(runes) > maxNameLength {
+		name = string(runes[:maxNameLength])
+	}
+
+	return name
+}
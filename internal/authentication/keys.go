@@ -0,0 +1,306 @@
+package authentication
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	defaultKeyRotation = 24 * time.Hour
+	rsaKeyBits         = 2048
+	// minReloadInterval limits how often unknown key IDs can trigger a reload.
+	minReloadInterval = time.Minute
+)
+
+// SigningKeyRecord is a stored asymmetric signing key, DER encoded as PKCS #8
+// for the private and PKIX for the public key.
+type SigningKeyRecord struct {
+	ID         uuid.UUID `db:"id"`
+	Algorithm  string    `db:"algorithm"`
+	PrivateKey []byte    `db:"private_key"`
+	PublicKey  []byte    `db:"public_key"`
+	CreatedAt  time.Time `db:"created_at"`
+	ExpiresAt  time.Time `db:"expires_at"`
+}
+
+// JWK is a public key in JSON Web Key format, as published in the JWKS.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	public    crypto.PublicKey
+	createdAt time.Time
+}
+
+// keyring holds the signing keys shared by all instances through the database.
+// The newest key signs new tokens and is replaced once it is older than the
+// rotation interval, while older keys keep verifying until their tokens expire.
+type keyring struct {
+	mu         sync.RWMutex
+	repo       Repository
+	algorithm  string
+	rotation   time.Duration
+	retention  time.Duration
+	keys       []signingKey // Newest first
+	lastReload time.Time
+}
+
+func newKeyring(repo Repository, algorithm string, rotation, retention time.Duration) *keyring {
+	return &keyring{
+		repo:      repo,
+		algorithm: algorithm,
+		rotation:  rotation,
+		retention: retention,
+	}
+}
+
+// current returns the key to sign with, rotating it if it is due.
+func (k *keyring) current(ctx context.Context) (*signingKey, error) {
+	k.mu.RLock()
+	if len(k.keys) > 0 && time.Since(k.keys[0].createdAt) < k.rotation {
+		key := k.keys[0]
+		k.mu.RUnlock()
+		return &key, nil
+	}
+	k.mu.RUnlock()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// Another instance may have rotated already
+	if err := k.reload(ctx); err != nil {
+		return nil, err
+	}
+	if len(k.keys) > 0 && time.Since(k.keys[0].createdAt) < k.rotation {
+		key := k.keys[0]
+		return &key, nil
+	}
+
+	if err := k.rotate(ctx); err != nil {
+		return nil, err
+	}
+
+	key := k.keys[0]
+	return &key, nil
+}
+
+// lookup returns the key with the given ID, reloading if it was created elsewhere.
+func (k *keyring) lookup(ctx context.Context, id string) (*signingKey, error) {
+	k.mu.RLock()
+	key, ok := k.find(id)
+	k.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if key, ok := k.find(id); ok {
+		return key, nil
+	}
+	if time.Since(k.lastReload) < minReloadInterval {
+		return nil, fmt.Errorf("unknown key id: %s", id)
+	}
+
+	if err := k.reload(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := k.find(id); ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown key id: %s", id)
+}
+
+// publicKeys returns all keys that may still have signed a valid token.
+func (k *keyring) publicKeys(ctx context.Context) ([]JWK, error) {
+	// Make sure there is a key to publish before the first token is signed
+	if _, err := k.current(ctx); err != nil {
+		return nil, err
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	jwks := make([]JWK, 0, len(k.keys))
+	for _, key := range k.keys {
+		jwk, err := toJWK(key)
+		if err != nil {
+			return nil, err
+		}
+		jwks = append(jwks, jwk)
+	}
+
+	return jwks, nil
+}
+
+func (k *keyring) find(id string) (*signingKey, bool) {
+	for _, key := range k.keys {
+		if key.id == id {
+			return &key, true
+		}
+	}
+	return nil, false
+}
+
+// reload replaces the cached keys with the stored ones. The caller must hold the write lock.
+func (k *keyring) reload(ctx context.Context) error {
+	records, err := k.repo.GetSigningKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get signing keys: %w", err)
+	}
+
+	keys := make([]signingKey, 0, len(records))
+	for _, record := range records {
+		// Keys of a previous algorithm are dropped once the configuration changes
+		if record.Algorithm != k.algorithm {
+			continue
+		}
+
+		key, err := parseSigningKey(record)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	k.keys = keys
+	k.lastReload = time.Now()
+
+	return nil
+}
+
+// rotate creates a new signing key. The caller must hold the write lock.
+func (k *keyring) rotate(ctx context.Context) error {
+	private, public, err := generateKeyPair(k.algorithm)
+	if err != nil {
+		return err
+	}
+
+	record := &SigningKeyRecord{
+		Algorithm:  k.algorithm,
+		PrivateKey: private,
+		PublicKey:  public,
+		// Signs for one rotation, then verifies the longest lived tokens it signed
+		ExpiresAt: time.Now().Add(k.rotation + k.retention),
+	}
+	if err := k.repo.CreateSigningKey(ctx, record); err != nil {
+		return fmt.Errorf("failed to create signing key: %w", err)
+	}
+
+	if err := k.repo.DeleteExpiredSigningKeys(ctx); err != nil {
+		return fmt.Errorf("failed to delete expired signing keys: %w", err)
+	}
+
+	return k.reload(ctx)
+}
+
+func generateKeyPair(algorithm string) (private, public []byte, err error) {
+	var signer crypto.Signer
+	switch algorithm {
+	case AlgorithmRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	private, err = x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	public, err = x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	return private, public, nil
+}
+
+func parseSigningKey(record SigningKeyRecord) (signingKey, error) {
+	parsed, err := x509.ParsePKCS8PrivateKey(record.PrivateKey)
+	if err != nil {
+		return signingKey{}, fmt.Errorf("failed to parse private key %s: %w", record.ID, err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return signingKey{}, fmt.Errorf("private key %s cannot sign", record.ID)
+	}
+
+	var method jwt.SigningMethod
+	switch signer.(type) {
+	case *rsa.PrivateKey:
+		method = jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return signingKey{}, fmt.Errorf("unsupported private key type %T", signer)
+	}
+
+	return signingKey{
+		id:        record.ID.String(),
+		method:    method,
+		private:   signer,
+		public:    signer.Public(),
+		createdAt: record.CreatedAt,
+	}, nil
+}
+
+func toJWK(key signingKey) (JWK, error) {
+	jwk := JWK{
+		KeyID:     key.id,
+		Algorithm: key.method.Alg(),
+		Use:       "sig",
+	}
+
+	switch public := key.public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", public)
+	}
+
+	return jwk, nil
+}
@@ -0,0 +1,58 @@
+package authentication
+
+import (
+	"context"
+	"core/internal/subscription"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type contextKey string
+
+// PrincipalContextKey is the context key the authenticated principal is stored under.
+const PrincipalContextKey contextKey = "principal"
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID           uuid.UUID
+	SessionID        string
+	TokenID          string
+	TokenExpiresAt   time.Time
+	EmailVerified    bool
+	TwoFactor        bool
+	SubscriptionTier subscription.Tier
+	Memberships      map[uuid.UUID]ClaimsOrganization
+	// APIKey is set when the caller authenticated with an API key instead of
+	// logging in. Club keys have no user.
+	APIKey *APIKey
+}
+
+// NewPrincipal builds the principal described by verified access token claims.
+func NewPrincipal(claims *AccessClaims) (*Principal, error) {
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse subject: %w", err)
+	}
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("token has no id or expiry")
+	}
+
+	return &Principal{
+		UserID:           userID,
+		SessionID:        claims.Session,
+		TokenID:          claims.ID,
+		TokenExpiresAt:   claims.ExpiresAt.Time,
+		EmailVerified:    claims.EmailVerified,
+		TwoFactor:        claims.TwoFactor,
+		SubscriptionTier: claims.SubscriptionTier,
+		Memberships:      claims.Organizations,
+	}, nil
+}
+
+// PrincipalFrom returns the authenticated principal of the request, if any.
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(PrincipalContextKey).(*Principal)
+	return principal, ok && principal != nil
+}
@@ -0,0 +1,454 @@
+package authentication
+
+import (
+	"context"
+	"core/internal/user"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository interface {
+	// GetSigningKeys returns the keys that have not expired, newest first.
+	GetSigningKeys(ctx context.Context) ([]SigningKeyRecord, error)
+	CreateSigningKey(ctx context.Context, key *SigningKeyRecord) error
+	DeleteExpiredSigningKeys(ctx context.Context) error
+	CreateUserToken(ctx context.Context, token *UserToken) error
+	// ConsumeUserToken marks the unexpired, unused token with the hash as used
+	// and returns its user, or ErrInvalidToken.
+	ConsumeUserToken(ctx context.Context, purpose TokenPurpose, hash []byte) (uuid.UUID, error)
+	// GetIdentity returns the identity of the provider's subject, or ErrIdentityNotFound.
+	GetIdentity(ctx context.Context, provider, subject string) (*Identity, error)
+	GetUserIdentities(ctx context.Context, userID uuid.UUID) ([]Identity, error)
+	// CreateIdentity returns ErrIdentityLinked if the subject or the user is
+	// already linked with the provider.
+	CreateIdentity(ctx context.Context, identity *Identity) error
+	// DeleteIdentity returns ErrIdentityNotFound if the user has no identity with the provider.
+	DeleteIdentity(ctx context.Context, userID uuid.UUID, provider string) error
+	// GetTwoFactor returns the user's TOTP enrollment, or ErrTwoFactorNotFound.
+	GetTwoFactor(ctx context.Context, userID uuid.UUID) (*TwoFactor, error)
+	// ReplaceTwoFactor starts a new unconfirmed enrollment, unless a confirmed one exists.
+	ReplaceTwoFactor(ctx context.Context, userID uuid.UUID, secret []byte) error
+	// ConfirmTwoFactor enables the enrollment, using up the step of the code
+	// that confirmed it, and stores the recovery codes.
+	ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, step int64, recoveryCodes [][]byte) error
+	DeleteTwoFactor(ctx context.Context, userID uuid.UUID) error
+	// UseTOTPStep records the step of an accepted code. It returns false if the
+	// step, or a later one, was already used.
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	// ReplaceRecoveryCodes invalidates all recovery codes in favor of the new ones.
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, recoveryCodes [][]byte) error
+	// ConsumeRecoveryCode marks the unused code as used, or returns ErrInvalidCode.
+	ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, hash []byte) error
+	CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error)
+	CreateAPIKey(ctx context.Context, key *APIKey) error
+	// GetAPIKeyByHash returns the key with the hash, or ErrAPIKeyNotFound.
+	GetAPIKeyByHash(ctx context.Context, hash []byte) (*APIKey, error)
+	GetUserAPIKeys(ctx context.Context, userID uuid.UUID) ([]APIKey, error)
+	GetClubAPIKeys(ctx context.Context, clubID uuid.UUID) ([]APIKey, error)
+	TouchAPIKey(ctx context.Context, keyID uuid.UUID) error
+	// DeleteUserAPIKey returns ErrAPIKeyNotFound unless the user owns the key.
+	DeleteUserAPIKey(ctx context.Context, userID, keyID uuid.UUID) error
+	// DeleteClubAPIKey returns ErrAPIKeyNotFound unless the club owns the key.
+	DeleteClubAPIKey(ctx context.Context, clubID, keyID uuid.UUID) error
+}
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (r *repository) GetSigningKeys(ctx context.Context) ([]SigningKeyRecord, error) {
+	var keys []SigningKeyRecord
+	err := r.db.SelectContext(ctx, &keys,
+		"SELECT * FROM signing_keys WHERE expires_at > CURRENT_TIMESTAMP ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *repository) CreateSigningKey(ctx context.Context, key *SigningKeyRecord) error {
+	err := r.db.QueryRowContext(ctx,
+		"INSERT INTO signing_keys (algorithm, private_key, public_key, expires_at) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		key.Algorithm, key.PrivateKey, key.PublicKey, key.ExpiresAt).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) DeleteExpiredSigningKeys(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM signing_keys WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) CreateUserToken(ctx context.Context, token *UserToken) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO user_tokens (user_id, purpose, hash, expires_at) VALUES ($1, $2, $3, $4)",
+		token.UserID, token.Purpose, token.Hash, token.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) ConsumeUserToken(ctx context.Context, purpose TokenPurpose, hash []byte) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := r.db.GetContext(ctx, &userID, `
+		UPDATE user_tokens
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE hash = $1
+		AND purpose = $2
+		AND used_at IS NULL
+		AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id`,
+		hash, purpose)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, ErrInvalidToken
+		}
+		return uuid.Nil, err
+	}
+
+	return userID, nil
+}
+
+func (r *repository) GetIdentity(ctx context.Context, provider, subject string) (*Identity, error) {
+	var identity Identity
+	err := r.db.GetContext(ctx, &identity,
+		"SELECT * FROM user_identities WHERE provider = $1 AND subject = $2",
+		provider, subject)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+func (r *repository) GetUserIdentities(ctx context.Context, userID uuid.UUID) ([]Identity, error) {
+	var identities []Identity
+	err := r.db.SelectContext(ctx, &identities,
+		"SELECT * FROM user_identities WHERE user_id = $1 ORDER BY created_at",
+		userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+func (r *repository) CreateIdentity(ctx context.Context, identity *Identity) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)",
+		identity.UserID, identity.Provider, identity.Subject, identity.Email)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == user.UniqueViolationCode {
+			return ErrIdentityLinked
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) DeleteIdentity(ctx context.Context, userID uuid.UUID, provider string) error {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM user_identities WHERE user_id = $1 AND provider = $2",
+		userID, provider)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrIdentityNotFound
+	}
+
+	return nil
+}
+
+func (r *repository) GetTwoFactor(ctx context.Context, userID uuid.UUID) (*TwoFactor, error) {
+	var twoFactor TwoFactor
+	err := r.db.GetContext(ctx, &twoFactor,
+		"SELECT * FROM user_two_factor WHERE user_id = $1",
+		userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTwoFactorNotFound
+		}
+		return nil, err
+	}
+
+	return &twoFactor, nil
+}
+
+func (r *repository) ReplaceTwoFactor(ctx context.Context, userID uuid.UUID, secret []byte) error {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO user_two_factor (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+		WHERE user_two_factor.confirmed_at IS NULL`,
+		userID, secret)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTwoFactorEnabled
+	}
+
+	return nil
+}
+
+func (r *repository) ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, step int64, recoveryCodes [][]byte) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE user_two_factor SET confirmed_at = CURRENT_TIMESTAMP, last_used_step = $2 WHERE user_id = $1 AND confirmed_at IS NULL",
+		userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to confirm two factor: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTwoFactorNotFound
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) DeleteTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_two_factor WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete two factor: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE user_two_factor SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2",
+		userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (r *repository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, recoveryCodes [][]byte) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, recoveryCodes [][]byte) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	for _, hash := range recoveryCodes {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO user_recovery_codes (user_id, hash) VALUES ($1, $2)",
+			userID, hash)
+		if err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, hash []byte) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE user_recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND hash = $2 AND used_at IS NULL",
+		userID, hash)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidCode
+	}
+
+	return nil
+}
+
+func (r *repository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count,
+		"SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL",
+		userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *repository) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO api_keys (user_id, club_id, created_by, name, prefix, hash, scopes, expires_at, two_factor)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at`,
+		key.UserID, key.ClubID, key.CreatedBy, key.Name, key.Prefix, key.Hash, key.Scopes, key.ExpiresAt, key.TwoFactor).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) GetAPIKeyByHash(ctx context.Context, hash []byte) (*APIKey, error) {
+	var key APIKey
+	err := r.db.GetContext(ctx, &key,
+		"SELECT * FROM api_keys WHERE hash = $1 AND (club_id IS NULL OR club_id IN (SELECT id FROM clubs WHERE deleted_at IS NULL))",
+		hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (r *repository) GetUserAPIKeys(ctx context.Context, userID uuid.UUID) ([]APIKey, error) {
+	var keys []APIKey
+	err := r.db.SelectContext(ctx, &keys,
+		"SELECT * FROM api_keys WHERE user_id = $1 ORDER BY created_at",
+		userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *repository) GetClubAPIKeys(ctx context.Context, clubID uuid.UUID) ([]APIKey, error) {
+	var keys []APIKey
+	err := r.db.SelectContext(ctx, &keys,
+		"SELECT * FROM api_keys WHERE club_id = $1 ORDER BY created_at",
+		clubID)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *repository) TouchAPIKey(ctx context.Context, keyID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1",
+		keyID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) DeleteUserAPIKey(ctx context.Context, userID, keyID uuid.UUID) error {
+	return r.deleteAPIKey(ctx, "DELETE FROM api_keys WHERE id = $1 AND user_id = $2", keyID, userID)
+}
+
+func (r *repository) DeleteClubAPIKey(ctx context.Context, clubID, keyID uuid.UUID) error {
+	return r.deleteAPIKey(ctx, "DELETE FROM api_keys WHERE id = $1 AND club_id = $2", keyID, clubID)
+}
+
+func (r *repository) deleteAPIKey(ctx context.Context, query string, keyID, ownerID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, query, keyID, ownerID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
@@ -3,83 +3,338 @@ package authentication
 import (
 	"context"
 	"core/internal/cache"
+	"core/internal/mailer"
+	"core/internal/member"
+	"core/internal/oidc"
+	"core/internal/ratelimit"
 	"core/internal/subscription"
 	"core/internal/user"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrInvalidToken is returned for refresh and mailed tokens that are malformed,
+// expired, spent or revoked. Reusing a spent refresh token also revokes its
+// whole family.
+var (
+	ErrInvalidToken    = fmt.Errorf("invalid token")
+	ErrSessionNotFound = fmt.Errorf("session not found")
+	// ErrReauthenticationRequired is returned when a sensitive action is not
+	// confirmed with the user's password, or a fresh login for users without one.
+	ErrReauthenticationRequired = fmt.Errorf("reauthentication required")
+)
+
+// reauthWindow is how recently users without a password must have logged in
+// to confirm a sensitive action.
+const reauthWindow = 10 * time.Minute
+
+// defaultLockout locks an email address out for a minute after five failed
+// logins in a row, doubling up to an hour.
+var defaultLockout = ratelimit.Lockout{
+	Threshold: 5,
+	Base:      time.Minute,
+	Max:       time.Hour,
+	Window:    24 * time.Hour,
+}
+
 type Config struct {
-	Secret        string        `mapstructure:"secret" validate:"required"`
+	// Algorithm is HS256 with the shared secret, or RS256 or EdDSA with rotated key pairs
+	Algorithm     string        `mapstructure:"algorithm"`
+	Secret        string        `mapstructure:"secret"`
+	KeyRotation   time.Duration `mapstructure:"key_rotation"`
 	AccessExpiry  time.Duration `mapstructure:"access_expiry" validate:"required"`
 	RefreshExpiry time.Duration `mapstructure:"refresh_expiry" validate:"required"`
-	Pepper        string        `mapstructure:"pepper" validate:"required"`
+	// TokenSecret keys the hashes of tokens mailed for verification and password resets
+	TokenSecret        string        `mapstructure:"token_secret" validate:"required"`
+	VerificationExpiry time.Duration `mapstructure:"verification_expiry"`
+	ResetExpiry        time.Duration `mapstructure:"reset_expiry"`
+	// AppURL is the base of the links in emails
+	AppURL string `mapstructure:"app_url" validate:"required"`
+	// LoginLimit and SignupLimit bound the attempts per email address,
+	// wherever they come from
+	LoginLimit  ratelimit.Limit `mapstructure:"login_limit"`
+	SignupLimit ratelimit.Limit `mapstructure:"signup_limit"`
+	// Lockout applies to an email address after failed logins, unset fields
+	// take those of defaultLockout
+	Lockout ratelimit.Lockout `mapstructure:"lockout"`
 }
 
 type Service interface {
-	Login(ctx context.Context, email, password string) (valid bool, accessToken, refreshToken string, err error)
-	Logout(ctx context.Context, token string) error
+	// Login returns valid false for wrong credentials. Users with two-factor
+	// authentication get a challenge instead of tokens, see VerifyLoginChallenge.
+	// It returns a ratelimit.LimitedError while the email address is limited
+	// or locked out after failed attempts.
+	Login(ctx context.Context, email, password string, client Client) (valid bool, result *LoginResult, err error)
+	// VerifyLoginChallenge issues the tokens of a login once its second factor
+	// is checked. It returns ErrInvalidToken if the challenge expired or ran out
+	// of attempts, and ErrInvalidCode for a wrong code. Wrong codes lock the
+	// user out like failed logins, returning a ratelimit.LimitedError.
+	VerifyLoginChallenge(ctx context.Context, challenge, code string) (accessToken, refreshToken string, err error)
+	// Reauthenticate confirms that the principal is the user, returning
+	// ErrReauthenticationRequired if not. Wrong passwords lock out like logins.
+	Reauthenticate(ctx context.Context, principal *Principal, password string) error
+	// Logout revokes the principal's access token and ends its session.
+	Logout(ctx context.Context, principal *Principal) error
+	// Signup returns a ratelimit.LimitedError if the email address is limited.
 	Signup(ctx context.Context, email, username, password string) (success bool, err error)
 	VerifyAccessToken(ctx context.Context, token string) (valid bool, claims *AccessClaims, err error)
 	VerifyRefreshToken(ctx context.Context, token string) (valid bool, claims *RefreshClaims, err error)
 	RefreshTokens(ctx context.Context, refreshToken string) (newAccessToken, newRefreshToken string, err error)
+	GetSessions(ctx context.Context, userID uuid.UUID) ([]cache.Session, error)
+	// RevokeSession logs the user out on the session's device.
+	RevokeSession(ctx context.Context, userID uuid.UUID, sessionID string) error
+	// RevokeSessions logs the user out everywhere except the given session, which may be empty.
+	RevokeSessions(ctx context.Context, userID uuid.UUID, exceptSessionID string) error
+	// GetPublicKeys returns the keys verifying current tokens, empty for HS256.
+	GetPublicKeys(ctx context.Context) ([]JWK, error)
+	SendVerificationEmail(ctx context.Context, userID uuid.UUID) error
+	// VerifyEmail returns ErrInvalidToken if the token is unknown, used or expired.
+	VerifyEmail(ctx context.Context, token string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	// ResetPassword returns ErrInvalidToken if the token is unknown, used or expired.
+	// All of the user's sessions are logged out.
+	ResetPassword(ctx context.Context, token, password string) error
+	// Providers returns the names of the configured OIDC providers.
+	Providers() []string
+	// StartOIDC begins logging in with the provider, or linking it to the user
+	// if userID is set. It returns the URL to send the user to and the state
+	// the provider will return with the code.
+	StartOIDC(ctx context.Context, provider string, userID uuid.UUID) (authURL, state string, err error)
+	// OIDCLogin completes a login started with StartOIDC. It returns
+	// ErrInvalidToken if the state or code is no good.
+	OIDCLogin(ctx context.Context, provider, state, code string, client Client) (*LoginResult, error)
+	// LinkIdentity completes linking the provider started with StartOIDC.
+	LinkIdentity(ctx context.Context, userID uuid.UUID, provider, state, code string) error
+	GetIdentities(ctx context.Context, userID uuid.UUID) ([]Identity, error)
+	UnlinkIdentity(ctx context.Context, userID uuid.UUID, provider string) error
+	GetTwoFactorStatus(ctx context.Context, userID uuid.UUID) (*TwoFactorStatus, error)
+	// EnrollTwoFactor generates a TOTP secret, returned raw and as an otpauth
+	// URI. It only takes effect once confirmed, returning ErrTwoFactorEnabled
+	// if two-factor authentication is already enabled.
+	EnrollTwoFactor(ctx context.Context, userID uuid.UUID) (secret, uri string, err error)
+	// ConfirmTwoFactor enables the enrollment with a code from the app and
+	// returns the recovery codes, which are only shown this once.
+	ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, code string) (recoveryCodes []string, err error)
+	// DisableTwoFactor needs a TOTP or recovery code.
+	DisableTwoFactor(ctx context.Context, userID uuid.UUID, code string) error
+	// RegenerateRecoveryCodes needs a TOTP code and invalidates the old recovery codes.
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	// CreateAPIKey stores the key, owned by either a user or a club, and
+	// returns its secret, which is only shown this once. It returns
+	// ErrInvalidScope unless the key has only known scopes.
+	CreateAPIKey(ctx context.Context, key *APIKey) (string, error)
+	GetUserAPIKeys(ctx context.Context, userID uuid.UUID) ([]APIKey, error)
+	GetClubAPIKeys(ctx context.Context, clubID uuid.UUID) ([]APIKey, error)
+	// RevokeUserAPIKey returns ErrAPIKeyNotFound unless the user owns the key.
+	RevokeUserAPIKey(ctx context.Context, userID, keyID uuid.UUID) error
+	// RevokeClubAPIKey returns ErrAPIKeyNotFound unless the club owns the key.
+	RevokeClubAPIKey(ctx context.Context, clubID, keyID uuid.UUID) error
+	// VerifyAPIKey returns the principal the key authenticates, or
+	// ErrInvalidToken if it is unknown or expired.
+	VerifyAPIKey(ctx context.Context, token string) (*Principal, error)
 }
 
 type service struct {
 	config              Config
+	repo                Repository
+	keys                *keyring
 	userService         user.Service
+	memberService       member.Service
 	subscriptionService subscription.Service
 	cache               cache.Service
+	mailer              mailer.Mailer
+	providers           map[string]oidc.Provider
+	limiter             ratelimit.Limiter
 }
 
-func NewService(config Config, userService user.Service, subscriptionService subscription.Service, cache cache.Service) Service {
+func NewService(config Config, repo Repository, userService user.Service, memberService member.Service, subscriptionService subscription.Service, cache cache.Service, mailer mailer.Mailer, providers []oidc.Provider, limiter ratelimit.Limiter) Service {
+	if config.Algorithm == "" {
+		config.Algorithm = AlgorithmHS256
+	}
+	if config.KeyRotation <= 0 {
+		config.KeyRotation = defaultKeyRotation
+	}
+	if config.VerificationExpiry <= 0 {
+		config.VerificationExpiry = defaultVerificationExpiry
+	}
+	if config.ResetExpiry <= 0 {
+		config.ResetExpiry = defaultResetExpiry
+	}
+	if config.Lockout.Threshold <= 0 {
+		config.Lockout.Threshold = defaultLockout.Threshold
+	}
+	if config.Lockout.Base <= 0 {
+		config.Lockout.Base = defaultLockout.Base
+	}
+	if config.Lockout.Max <= 0 {
+		config.Lockout.Max = defaultLockout.Max
+	}
+	if config.Lockout.Window <= 0 {
+		config.Lockout.Window = defaultLockout.Window
+	}
+
+	providersByName := make(map[string]oidc.Provider, len(providers))
+	for _, provider := range providers {
+		providersByName[provider.Name()] = provider
+	}
+
 	return &service{
 		config:              config,
+		repo:                repo,
+		keys:                newKeyring(repo, config.Algorithm, config.KeyRotation, config.RefreshExpiry),
 		userService:         userService,
+		memberService:       memberService,
 		subscriptionService: subscriptionService,
 		cache:               cache,
+		mailer:              mailer,
+		providers:           providersByName,
+		limiter:             limiter,
 	}
 }
 
-func (s *service) Login(ctx context.Context, email string, password string) (bool, string, string, error) {
+func (s *service) Login(ctx context.Context, email string, password string, client Client) (bool, *LoginResult, error) {
+	account := accountKey(email)
+	if err := s.limiter.Allow(ctx, "login:account:"+account, s.config.LoginLimit); err != nil {
+		return false, nil, fmt.Errorf("failed to check login limit: %w", err)
+	}
+	if err := s.limiter.CheckLockout(ctx, "login:"+account); err != nil {
+		return false, nil, fmt.Errorf("failed to check lockout: %w", err)
+	}
+
 	exists, user, err := s.userService.GetUserByEmail(ctx, email)
 	if err != nil {
-		return false, "", "", fmt.Errorf("failed to check for existing user with email: %w", err)
+		return false, nil, fmt.Errorf("failed to check for existing user with email: %w", err)
 	}
 	if !exists {
-		return false, "", "", nil
+		// Unknown addresses lock out like known ones, not to reveal which exist
+		return false, nil, s.loginFailed(ctx, account)
 	}
 
-	if err = bcrypt.CompareHashAndPassword([]byte(user.Hash), []byte(password+s.config.Pepper)); err != nil {
-		return false, "", "", nil
+	correct, err := s.userService.VerifyPassword(ctx, user, password)
+	if err != nil {
+		return false, nil, err
+	}
+	if !correct {
+		return false, nil, s.loginFailed(ctx, account)
 	}
 
-	if err := s.userService.UpdateLastLogin(ctx, user.ID); err != nil {
-		return false, "", "", fmt.Errorf("failed to update last login: %w", err)
+	if err := s.limiter.Reset(ctx, "login:"+account); err != nil {
+		return false, nil, fmt.Errorf("failed to reset failed logins: %w", err)
 	}
 
-	accessToken, refreshToken, err := s.generateTokenPair(ctx, user.ID)
+	result, err := s.completeLogin(ctx, user.ID, client)
 	if err != nil {
-		return false, "", "", fmt.Errorf("failed to generate jwts: %w", err)
+		return false, nil, err
+	}
+
+	return true, result, nil
+}
+
+func (s *service) loginFailed(ctx context.Context, account string) error {
+	if err := s.limiter.Fail(ctx, "login:"+account, s.config.Lockout); err != nil {
+		return fmt.Errorf("failed to record failed login: %w", err)
 	}
 
-	return true, accessToken, refreshToken, nil
+	return nil
+}
+
+// accountKey identifies the account an email address refers to, however it is capitalized.
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
-func (s *service) Logout(ctx context.Context, token string) error {
-	if err := s.cache.SetTokenUsed(ctx, token); err != nil {
-		return fmt.Errorf("failed to set token as used: %w", err)
+// startSession logs the user in on the client's device. twoFactor records
+// whether a second factor was checked, which some clubs require.
+func (s *service) startSession(ctx context.Context, userID uuid.UUID, client Client, twoFactor bool) (string, string, error) {
+	if err := s.userService.UpdateLastLogin(ctx, userID); err != nil {
+		return "", "", fmt.Errorf("failed to update last login: %w", err)
+	}
+
+	session := cache.Session{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+	}
+	familyID, tokenID := session.ID, uuid.NewString()
+	if err := s.cache.CreateTokenFamily(ctx, session, tokenID, s.config.RefreshExpiry); err != nil {
+		return "", "", fmt.Errorf("failed to create token family: %w", err)
+	}
+
+	accessToken, refreshToken, err := s.generateTokenPair(ctx, userID, familyID, tokenID, twoFactor)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate jwts: %w", err)
+	}
+
+	return accessToken, refreshToken, nil
+}
 
+func (s *service) Reauthenticate(ctx context.Context, principal *Principal, password string) error {
+	if principal.APIKey != nil {
+		return ErrReauthenticationRequired
+	}
+
+	key := "reauth:" + principal.UserID.String()
+	if err := s.limiter.CheckLockout(ctx, key); err != nil {
+		return fmt.Errorf("failed to check lockout: %w", err)
+	}
+
+	u, err := s.userService.GetUser(ctx, principal.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if u.Hash != "" {
+		correct, err := s.userService.VerifyPassword(ctx, u, password)
+		if err != nil {
+			return err
+		}
+		if !correct {
+			if err := s.limiter.Fail(ctx, key, s.config.Lockout); err != nil {
+				return fmt.Errorf("failed to record failed reauthentication: %w", err)
+			}
+			return ErrReauthenticationRequired
+		}
+
+		if err := s.limiter.Reset(ctx, key); err != nil {
+			return fmt.Errorf("failed to reset failed reauthentications: %w", err)
+		}
+		return nil
+	}
+
+	// Users logging in through a provider prove themselves by logging in again
+	session, found, err := s.cache.GetSession(ctx, principal.SessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if !found || time.Since(session.CreatedAt) > reauthWindow {
+		return ErrReauthenticationRequired
+	}
+
+	return nil
+}
+
+func (s *service) Logout(ctx context.Context, principal *Principal) error {
+	if err := s.cache.RevokeToken(ctx, principal.TokenID, principal.TokenExpiresAt); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	if err := s.cache.RevokeTokenFamily(ctx, principal.SessionID); err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
 	}
 
 	return nil
 }
 
 func (s *service) Signup(ctx context.Context, email string, username string, password string) (bool, error) {
+	if err := s.limiter.Allow(ctx, "signup:account:"+accountKey(email), s.config.SignupLimit); err != nil {
+		return false, fmt.Errorf("failed to check signup limit: %w", err)
+	}
+
 	exists, _, err := s.userService.GetUserByEmail(ctx, email)
 	if err != nil {
 		return false, fmt.Errorf("failed to check for existing user with email: %w", err)
@@ -88,12 +343,7 @@ func (s *service) Signup(ctx context.Context, email string, username string, pas
 		return false, nil
 	}
 
-	hashedPasswordBytes, err := bcrypt.GenerateFromPassword([]byte(password+s.config.Pepper), bcrypt.MinCost)
-	if err != nil {
-		return false, fmt.Errorf("failed to hash password: %w", err)
-	}
-
-	if _, err = s.userService.CreateUser(ctx, email, username, string(hashedPasswordBytes)); err != nil {
+	if _, err = s.userService.CreateUser(ctx, email, username, password); err != nil {
 		return false, fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -101,12 +351,7 @@ func (s *service) Signup(ctx context.Context, email string, username string, pas
 }
 
 func (s *service) VerifyAccessToken(ctx context.Context, token string) (bool, *AccessClaims, error) {
-	parsedToken, err := jwt.ParseWithClaims(token, &AccessClaims{}, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.config.Secret), nil
-	})
+	parsedToken, err := jwt.ParseWithClaims(token, &AccessClaims{}, s.keyFunc(ctx), jwt.WithValidMethods([]string{s.config.Algorithm}))
 	if err != nil {
 		return false, nil, err
 	}
@@ -123,12 +368,7 @@ func (s *service) VerifyAccessToken(ctx context.Context, token string) (bool, *A
 }
 
 func (s *service) VerifyRefreshToken(ctx context.Context, token string) (bool, *RefreshClaims, error) {
-	parsedToken, err := jwt.ParseWithClaims(token, &RefreshClaims{}, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.config.Secret), nil
-	})
+	parsedToken, err := jwt.ParseWithClaims(token, &RefreshClaims{}, s.keyFunc(ctx), jwt.WithValidMethods([]string{s.config.Algorithm}))
 	if err != nil {
 		return false, nil, err
 	}
@@ -147,7 +387,7 @@ func (s *service) VerifyRefreshToken(ctx context.Context, token string) (bool, *
 func (s *service) RefreshTokens(ctx context.Context, refreshToken string) (string, string, error) {
 	_, claims, err := s.VerifyRefreshToken(ctx, refreshToken)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to verify refresh token: %w", err)
+		return "", "", fmt.Errorf("%w: %w", ErrInvalidToken, err)
 	}
 
 	userId, err := uuid.Parse(claims.Subject)
@@ -155,7 +395,25 @@ func (s *service) RefreshTokens(ctx context.Context, refreshToken string) (strin
 		return "", "", fmt.Errorf("failed to parse user id: %w", err)
 	}
 
-	accessToken, newRefreshToken, err := s.generateTokenPair(ctx, userId)
+	// A refresh token must not outlive the account it was issued to
+	u, err := s.userService.GetUser(ctx, userId)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get user: %w", err)
+	}
+	if u.Deleted() {
+		return "", "", ErrInvalidToken
+	}
+
+	tokenID := uuid.NewString()
+	rotated, err := s.cache.RotateTokenFamily(ctx, claims.Family, claims.ID, tokenID, s.config.RefreshExpiry)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to rotate token family: %w", err)
+	}
+	if !rotated {
+		return "", "", ErrInvalidToken
+	}
+
+	accessToken, newRefreshToken, err := s.generateTokenPair(ctx, userId, claims.Family, tokenID, claims.TwoFactor)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to generate token pair: %w", err)
 	}
@@ -163,42 +421,191 @@ func (s *service) RefreshTokens(ctx context.Context, refreshToken string) (strin
 	return accessToken, newRefreshToken, nil
 }
 
-func (s *service) generateTokenPair(ctx context.Context, userId uuid.UUID) (string, string, error) {
+func (s *service) GetSessions(ctx context.Context, userID uuid.UUID) ([]cache.Session, error) {
+	sessions, err := s.cache.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+func (s *service) RevokeSession(ctx context.Context, userID uuid.UUID, sessionID string) error {
+	session, found, err := s.cache.GetSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if !found || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+
+	if err := s.cache.RevokeTokenFamily(ctx, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) RevokeSessions(ctx context.Context, userID uuid.UUID, exceptSessionID string) error {
+	// Without an exception every token can be revoked in one write
+	if exceptSessionID == "" {
+		if err := s.cache.RevokeUserTokens(ctx, userID); err != nil {
+			return fmt.Errorf("failed to revoke user tokens: %w", err)
+		}
+	}
+
+	sessions, err := s.cache.GetUserSessions(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	for _, session := range sessions {
+		if session.ID == exceptSessionID {
+			continue
+		}
+		if err := s.cache.RevokeTokenFamily(ctx, session.ID); err != nil {
+			return fmt.Errorf("failed to revoke session: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *service) GetPublicKeys(ctx context.Context) ([]JWK, error) {
+	if s.config.Algorithm == AlgorithmHS256 {
+		return []JWK{}, nil
+	}
+
+	keys, err := s.keys.publicKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// sign signs the claims with the shared secret or the current key pair, whose
+// ID is set as the kid header so verifiers can pick the right public key.
+func (s *service) sign(ctx context.Context, claims jwt.Claims) (string, error) {
+	if s.config.Algorithm == AlgorithmHS256 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.config.Secret))
+	}
+
+	key, err := s.keys.current(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get signing key: %w", err)
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+
+	return token.SignedString(key.private)
+}
+
+func (s *service) keyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (any, error) {
+		if s.config.Algorithm == AlgorithmHS256 {
+			return []byte(s.config.Secret), nil
+		}
+
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, fmt.Errorf("token has no key id")
+		}
+
+		key, err := s.keys.lookup(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if key.method.Alg() != token.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return key.public, nil
+	}
+}
+
+func (s *service) generateTokenPair(ctx context.Context, userId uuid.UUID, familyID, tokenID string, twoFactor bool) (string, string, error) {
+	u, err := s.userService.GetUser(ctx, userId)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get user: %w", err)
+	}
+
 	sub, err := s.subscriptionService.GetByUserID(ctx, userId)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to get subscription: %w", err)
 	}
 
+	organizations, err := s.claimsOrganizations(ctx, userId)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get memberships: %w", err)
+	}
+
 	now := time.Now()
 
 	accessclaims := AccessClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			Subject:   userId.String(),
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.AccessExpiry)),
 		},
+		Session:          familyID,
+		EmailVerified:    u.EmailVerified(),
+		TwoFactor:        twoFactor,
 		SubscriptionTier: sub.Tier,
+		Organizations:    organizations,
 	}
 
-	accessTokenUnsigned := jwt.NewWithClaims(jwt.SigningMethodHS256, accessclaims)
-	accessTokenSigned, err := accessTokenUnsigned.SignedString([]byte(s.config.Secret))
+	accessTokenSigned, err := s.sign(ctx, accessclaims)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to sign access token: %w", err)
 	}
 
 	refreshClaims := RefreshClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
 			Subject:   userId.String(),
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.RefreshExpiry)),
 		},
+		Family:    familyID,
+		TwoFactor: twoFactor,
 	}
 
-	refreshTokenUnsigned := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenSigned, err := refreshTokenUnsigned.SignedString([]byte(s.config.Secret))
+	refreshTokenSigned, err := s.sign(ctx, refreshClaims)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to sign refresh token: %w", err)
 	}
 
 	return accessTokenSigned, refreshTokenSigned, nil
 }
+
+// claimsOrganizations collects the user's memberships for embedding in the
+// access token. A club's tier is the subscription tier of its owner.
+func (s *service) claimsOrganizations(ctx context.Context, userId uuid.UUID) (map[uuid.UUID]ClaimsOrganization, error) {
+	memberships, err := s.memberService.GetUserMemberships(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user memberships: %w", err)
+	}
+
+	clubIds := make([]uuid.UUID, len(memberships))
+	for i, m := range memberships {
+		clubIds[i] = m.ClubID
+	}
+
+	tiers, err := s.subscriptionService.GetClubTiers(ctx, clubIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get club tiers: %w", err)
+	}
+
+	organizations := make(map[uuid.UUID]ClaimsOrganization, len(memberships))
+	for _, m := range memberships {
+		organizations[m.ClubID] = ClaimsOrganization{
+			Tier: tiers[m.ClubID],
+			Role: m.Role,
+		}
+	}
+
+	return organizations, nil
+}
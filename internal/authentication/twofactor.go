@@ -0,0 +1,341 @@
+package authentication
+
+import (
+	"context"
+	"core/internal/cache"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// loginChallengeExpiry is how long the user has to enter their code after their password.
+	loginChallengeExpiry = 5 * time.Minute
+	// maxChallengeAttempts bounds guessing codes for a single password check.
+	maxChallengeAttempts = 5
+	recoveryCodeCount    = 10
+	recoveryCodeLength   = 10
+	// recoveryCodeAlphabet leaves out characters that are easily confused.
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var (
+	ErrTwoFactorNotFound = fmt.Errorf("two-factor authentication is not enabled")
+	ErrTwoFactorEnabled  = fmt.Errorf("two-factor authentication is already enabled")
+	// ErrInvalidCode is returned for wrong, reused or spent TOTP and recovery codes.
+	ErrInvalidCode = fmt.Errorf("invalid code")
+)
+
+// TwoFactor is a user's TOTP enrollment. It only applies once confirmed with
+// a code, proving the authenticator app was set up. The secret is encrypted.
+type TwoFactor struct {
+	UserID       uuid.UUID  `db:"user_id"`
+	Secret       []byte     `db:"secret"`
+	ConfirmedAt  *time.Time `db:"confirmed_at"`
+	LastUsedStep int64      `db:"last_used_step"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+func (t *TwoFactor) Enabled() bool {
+	return t.ConfirmedAt != nil
+}
+
+type TwoFactorStatus struct {
+	Enabled                bool
+	RecoveryCodesRemaining int
+}
+
+// LoginResult holds the tokens of a completed login, or the challenge to
+// answer with a second factor before tokens are issued.
+type LoginResult struct {
+	AccessToken  string
+	RefreshToken string
+	Challenge    string
+}
+
+func (s *service) VerifyLoginChallenge(ctx context.Context, challengeID, code string) (string, string, error) {
+	challenge, found, err := s.cache.AttemptLoginChallenge(ctx, challengeID, maxChallengeAttempts)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get login challenge: %w", err)
+	}
+	if !found {
+		return "", "", ErrInvalidToken
+	}
+
+	// Wrong codes lock the account out like wrong passwords, however many
+	// challenges they are spread over
+	key := "login:two-factor:" + challenge.UserID.String()
+	if err := s.limiter.CheckLockout(ctx, key); err != nil {
+		return "", "", fmt.Errorf("failed to check lockout: %w", err)
+	}
+
+	twoFactor, err := s.enabledTwoFactor(ctx, challenge.UserID)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.verifyCode(ctx, twoFactor, code, true); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			if err := s.limiter.Fail(ctx, key, s.config.Lockout); err != nil {
+				return "", "", fmt.Errorf("failed to record failed second factor: %w", err)
+			}
+		}
+		return "", "", err
+	}
+
+	if err := s.limiter.Reset(ctx, key); err != nil {
+		return "", "", fmt.Errorf("failed to reset failed second factors: %w", err)
+	}
+
+	if err := s.cache.DeleteLoginChallenge(ctx, challengeID); err != nil {
+		return "", "", fmt.Errorf("failed to delete login challenge: %w", err)
+	}
+
+	client := Client{UserAgent: challenge.UserAgent, IP: challenge.IP}
+	return s.startSession(ctx, challenge.UserID, client, true)
+}
+
+func (s *service) GetTwoFactorStatus(ctx context.Context, userID uuid.UUID) (*TwoFactorStatus, error) {
+	twoFactor, err := s.repo.GetTwoFactor(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrTwoFactorNotFound) {
+			return &TwoFactorStatus{}, nil
+		}
+		return nil, fmt.Errorf("failed to get two factor: %w", err)
+	}
+	if !twoFactor.Enabled() {
+		return &TwoFactorStatus{}, nil
+	}
+
+	remaining, err := s.repo.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return &TwoFactorStatus{
+		Enabled:                true,
+		RecoveryCodesRemaining: remaining,
+	}, nil
+}
+
+func (s *service) EnrollTwoFactor(ctx context.Context, userID uuid.UUID) (string, string, error) {
+	u, err := s.userService.GetUser(ctx, userID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	sealed, err := s.encryptSecret(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.repo.ReplaceTwoFactor(ctx, userID, sealed); err != nil {
+		if errors.Is(err, ErrTwoFactorEnabled) {
+			return "", "", ErrTwoFactorEnabled
+		}
+		return "", "", fmt.Errorf("failed to store two factor secret: %w", err)
+	}
+
+	return totpEncoding.EncodeToString(secret), totpURI(secret, u.Email), nil
+}
+
+func (s *service) ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	twoFactor, err := s.repo.GetTwoFactor(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrTwoFactorNotFound) {
+			return nil, ErrTwoFactorNotFound
+		}
+		return nil, fmt.Errorf("failed to get two factor: %w", err)
+	}
+	if twoFactor.Enabled() {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := s.decryptSecret(twoFactor.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := matchTOTP(secret, normalizeCode(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.ConfirmTwoFactor(ctx, userID, step, hashes); err != nil {
+		return nil, fmt.Errorf("failed to confirm two factor: %w", err)
+	}
+
+	return codes, nil
+}
+
+func (s *service) DisableTwoFactor(ctx context.Context, userID uuid.UUID, code string) error {
+	twoFactor, err := s.enabledTwoFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyCode(ctx, twoFactor, code, true); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteTwoFactor(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete two factor: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	twoFactor, err := s.enabledTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A recovery code can't be used to replace the recovery codes
+	if err := s.verifyCode(ctx, twoFactor, code, false); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+// completeLogin starts a session after the password or provider login, or a
+// challenge for the second factor if the user enabled it.
+func (s *service) completeLogin(ctx context.Context, userID uuid.UUID, client Client) (*LoginResult, error) {
+	status, err := s.GetTwoFactorStatus(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !status.Enabled {
+		accessToken, refreshToken, err := s.startSession(ctx, userID, client, false)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+	}
+
+	challengeID := uuid.NewString()
+	challenge := cache.LoginChallenge{
+		UserID:    userID,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+	}
+	if err := s.cache.CreateLoginChallenge(ctx, challengeID, challenge, loginChallengeExpiry); err != nil {
+		return nil, fmt.Errorf("failed to create login challenge: %w", err)
+	}
+
+	return &LoginResult{Challenge: challengeID}, nil
+}
+
+func (s *service) enabledTwoFactor(ctx context.Context, userID uuid.UUID) (*TwoFactor, error) {
+	twoFactor, err := s.repo.GetTwoFactor(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrTwoFactorNotFound) {
+			return nil, ErrTwoFactorNotFound
+		}
+		return nil, fmt.Errorf("failed to get two factor: %w", err)
+	}
+	if !twoFactor.Enabled() {
+		return nil, ErrTwoFactorNotFound
+	}
+
+	return twoFactor, nil
+}
+
+// verifyCode accepts a current TOTP code that was not used before, or an
+// unused recovery code if allowed.
+func (s *service) verifyCode(ctx context.Context, twoFactor *TwoFactor, code string, allowRecovery bool) error {
+	code = normalizeCode(code)
+
+	if len(code) == totpDigits {
+		secret, err := s.decryptSecret(twoFactor.Secret)
+		if err != nil {
+			return err
+		}
+
+		step, ok := matchTOTP(secret, code, time.Now())
+		if !ok {
+			return ErrInvalidCode
+		}
+
+		// Codes stay valid for their whole period, so a seen one could be replayed
+		fresh, err := s.repo.UseTOTPStep(ctx, twoFactor.UserID, step)
+		if err != nil {
+			return fmt.Errorf("failed to use totp step: %w", err)
+		}
+		if !fresh {
+			return ErrInvalidCode
+		}
+
+		return nil
+	}
+
+	if !allowRecovery || len(code) != recoveryCodeLength {
+		return ErrInvalidCode
+	}
+
+	if err := s.repo.ConsumeRecoveryCode(ctx, twoFactor.UserID, s.hashUserToken(code)); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			return ErrInvalidCode
+		}
+		return fmt.Errorf("failed to consume recovery code: %w", err)
+	}
+
+	return nil
+}
+
+// generateRecoveryCodes returns the codes for display, formatted as
+// "xxxxx-xxxxx", and their hashes for storage.
+func (s *service) generateRecoveryCodes() ([]string, [][]byte, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([][]byte, recoveryCodeCount)
+
+	alphabetSize := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for i := range codes {
+		code := make([]byte, recoveryCodeLength)
+		for j := range code {
+			n, err := rand.Int(rand.Reader, alphabetSize)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+			}
+			code[j] = recoveryCodeAlphabet[n.Int64()]
+		}
+
+		half := recoveryCodeLength / 2
+		codes[i] = string(code[:half]) + "-" + string(code[half:])
+		hashes[i] = s.hashUserToken(string(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeCode strips the formatting users may copy along with a code.
+func normalizeCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
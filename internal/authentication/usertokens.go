@@ -0,0 +1,159 @@
+package authentication
+
+import (
+	"context"
+	"core/internal/mailer"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TokenPurpose string
+
+const (
+	TokenPurposeVerifyEmail   TokenPurpose = "verify_email"
+	TokenPurposeResetPassword TokenPurpose = "reset_password"
+)
+
+const (
+	defaultVerificationExpiry = 24 * time.Hour
+	defaultResetExpiry        = time.Hour
+)
+
+// UserToken is a single use token mailed to a user. Only its keyed hash is
+// stored, so tokens can neither be read from nor forged with the database.
+type UserToken struct {
+	ID        uuid.UUID    `db:"id"`
+	UserID    uuid.UUID    `db:"user_id"`
+	Purpose   TokenPurpose `db:"purpose"`
+	Hash      []byte       `db:"hash"`
+	ExpiresAt time.Time    `db:"expires_at"`
+	UsedAt    *time.Time   `db:"used_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+func (s *service) SendVerificationEmail(ctx context.Context, userID uuid.UUID) error {
+	u, err := s.userService.GetUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if u.EmailVerified() {
+		return nil
+	}
+
+	token, err := s.issueUserToken(ctx, userID, TokenPurposeVerifyEmail, s.config.VerificationExpiry)
+	if err != nil {
+		return err
+	}
+
+	msg := mailer.Message{
+		To:      u.Email,
+		Subject: "Verify your MatchAlly email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			u.Name, s.link("/verify-email", token), s.config.VerificationExpiry),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) VerifyEmail(ctx context.Context, token string) error {
+	userID, err := s.repo.ConsumeUserToken(ctx, TokenPurposeVerifyEmail, s.hashUserToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to consume token: %w", err)
+	}
+
+	if err := s.userService.SetEmailVerified(ctx, userID); err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) RequestPasswordReset(ctx context.Context, email string) error {
+	exists, u, err := s.userService.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to get user by email: %w", err)
+	}
+	// Callers must not learn which emails have accounts
+	if !exists {
+		return nil
+	}
+
+	token, err := s.issueUserToken(ctx, u.ID, TokenPurposeResetPassword, s.config.ResetExpiry)
+	if err != nil {
+		return err
+	}
+
+	msg := mailer.Message{
+		To:      u.Email,
+		Subject: "Reset your MatchAlly password",
+		Body: fmt.Sprintf("Hi %s,\n\nChoose a new password by opening the link below:\n\n%s\n\nThe link expires in %s. If you did not ask to reset your password, you can ignore this email.\n",
+			u.Name, s.link("/reset-password", token), s.config.ResetExpiry),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) ResetPassword(ctx context.Context, token, password string) error {
+	userID, err := s.repo.ConsumeUserToken(ctx, TokenPurposeResetPassword, s.hashUserToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to consume token: %w", err)
+	}
+
+	if err := s.userService.SetPassword(ctx, userID, password); err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+
+	// Receiving the email proves ownership of the address as well
+	if err := s.userService.SetEmailVerified(ctx, userID); err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	if err := s.RevokeSessions(ctx, userID, ""); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) issueUserToken(ctx context.Context, userID uuid.UUID, purpose TokenPurpose, expiry time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	userToken := &UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		Hash:      s.hashUserToken(token),
+		ExpiresAt: time.Now().Add(expiry),
+	}
+	if err := s.repo.CreateUserToken(ctx, userToken); err != nil {
+		return "", fmt.Errorf("failed to create %s token: %w", purpose, err)
+	}
+
+	return token, nil
+}
+
+func (s *service) hashUserToken(token string) []byte {
+	mac := hmac.New(sha256.New, []byte(s.config.TokenSecret))
+	mac.Write([]byte(token))
+	return mac.Sum(nil)
+}
+
+func (s *service) link(path, token string) string {
+	return s.config.AppURL + path + "?token=" + url.QueryEscape(token)
+}
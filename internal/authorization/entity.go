@@ -0,0 +1,94 @@
+package authorization
+
+import (
+	"core/internal/member"
+
+	"github.com/google/uuid"
+)
+
+type Permission string
+
+const (
+	PermissionViewClub          Permission = "club:view"
+	PermissionManageClub        Permission = "club:manage"
+	PermissionDeleteClub        Permission = "club:delete"
+	PermissionManagePermissions Permission = "permissions:manage"
+	PermissionManageSecurity    Permission = "security:manage"
+	PermissionManageMembers     Permission = "members:manage"
+	PermissionManageInvites     Permission = "invites:manage"
+	PermissionManageGames       Permission = "games:manage"
+	PermissionManageEvents      Permission = "events:manage"
+	PermissionRecordMatches     Permission = "matches:record"
+	PermissionEditMatches       Permission = "matches:edit"
+	PermissionManageAPIKeys     Permission = "apikeys:manage"
+	PermissionTransferOwnership Permission = "ownership:transfer"
+	PermissionViewAuditLog      Permission = "audit:view"
+)
+
+// defaultMinimumRoles holds the least privileged role granted each permission
+// when a club has no override for it.
+var defaultMinimumRoles = map[Permission]member.Role{
+	PermissionViewClub:          member.RoleObserver,
+	PermissionRecordMatches:     member.RoleMember,
+	PermissionEditMatches:       member.RoleManager,
+	PermissionManageEvents:      member.RoleManager,
+	PermissionManageInvites:     member.RoleManager,
+	PermissionManageGames:       member.RoleAdmin,
+	PermissionManageMembers:     member.RoleAdmin,
+	PermissionManageClub:        member.RoleAdmin,
+	PermissionManageAPIKeys:     member.RoleAdmin,
+	PermissionViewAuditLog:      member.RoleAdmin,
+	PermissionDeleteClub:        member.RoleOwner,
+	PermissionManagePermissions: member.RoleOwner,
+	PermissionManageSecurity:    member.RoleOwner,
+	PermissionTransferOwnership: member.RoleOwner,
+}
+
+// Permissions lists every known permission in a stable order.
+var Permissions = []Permission{
+	PermissionViewClub,
+	PermissionRecordMatches,
+	PermissionEditMatches,
+	PermissionManageEvents,
+	PermissionManageInvites,
+	PermissionManageGames,
+	PermissionManageMembers,
+	PermissionManageClub,
+	PermissionManageAPIKeys,
+	PermissionViewAuditLog,
+	PermissionDeleteClub,
+	PermissionManagePermissions,
+	PermissionManageSecurity,
+	PermissionTransferOwnership,
+}
+
+// Roles lists the roles that can hold permissions, least privileged first.
+var Roles = []member.Role{
+	member.RoleObserver,
+	member.RoleMember,
+	member.RoleManager,
+	member.RoleAdmin,
+	member.RoleOwner,
+}
+
+func (p Permission) IsValid() bool {
+	_, ok := defaultMinimumRoles[p]
+	return ok
+}
+
+// IsOverridable reports whether clubs may change which roles hold p. Deleting
+// the club, managing permissions and security settings and transferring
+// ownership stay with owners so a club cannot lock itself out.
+func (p Permission) IsOverridable() bool {
+	return p != PermissionDeleteClub && p != PermissionManagePermissions && p != PermissionManageSecurity &&
+		p != PermissionTransferOwnership
+}
+
+// Override grants or revokes a permission for a role within a single club.
+type Override struct {
+	ID         uuid.UUID   `db:"id"`
+	ClubID     uuid.UUID   `db:"club_id"`
+	Role       member.Role `db:"role"`
+	Permission Permission  `db:"permission"`
+	Granted    bool        `db:"granted"`
+}
@@ -0,0 +1,75 @@
+package authorization
+
+import (
+	"context"
+	"core/internal/audit"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository interface {
+	GetOverrides(ctx context.Context, clubID uuid.UUID) ([]Override, error)
+	// UpsertOverride and DeleteOverride write the audit entry along with the change.
+	UpsertOverride(ctx context.Context, override *Override, entry *audit.Entry) error
+	DeleteOverride(ctx context.Context, override *Override, entry *audit.Entry) error
+	GetRequireTwoFactor(ctx context.Context, clubID uuid.UUID) (bool, error)
+}
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (r *repository) GetOverrides(ctx context.Context, clubID uuid.UUID) ([]Override, error) {
+	var overrides []Override
+	err := r.db.SelectContext(ctx, &overrides,
+		"SELECT id, club_id, role, permission, granted FROM club_role_permissions WHERE club_id = $1",
+		clubID)
+	if err != nil {
+		return nil, err
+	}
+
+	return overrides, nil
+}
+
+func (r *repository) UpsertOverride(ctx context.Context, override *Override, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO club_role_permissions (club_id, role, permission, granted) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (club_id, role, permission) DO UPDATE SET granted = EXCLUDED.granted`,
+			override.ClubID, override.Role, override.Permission, override.Granted)
+		return err
+	})
+}
+
+func (r *repository) DeleteOverride(ctx context.Context, override *Override, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			"DELETE FROM club_role_permissions WHERE club_id = $1 AND role = $2 AND permission = $3",
+			override.ClubID, override.Role, override.Permission)
+		return err
+	})
+}
+
+func (r *repository) GetRequireTwoFactor(ctx context.Context, clubID uuid.UUID) (bool, error) {
+	var required bool
+	err := r.db.GetContext(ctx, &required,
+		"SELECT require_two_factor FROM clubs WHERE id = $1",
+		clubID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return required, nil
+}
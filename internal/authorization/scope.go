@@ -0,0 +1,44 @@
+package authorization
+
+// Scope limits what an API key may be used for. Routes declare the scope a key
+// needs to call them, routes without one can't be called with keys at all.
+type Scope string
+
+const (
+	ScopeClubRead     Scope = "club:read"
+	ScopeMatchesRead  Scope = "matches:read"
+	ScopeMatchesWrite Scope = "matches:write"
+	ScopeStatsRead    Scope = "stats:read"
+	ScopeEventsRead   Scope = "events:read"
+	ScopeEventsWrite  Scope = "events:write"
+)
+
+// scopePermissions holds the permission a user needs to grant each scope to
+// a club's key, so keys can't do more than their creator.
+var scopePermissions = map[Scope]Permission{
+	ScopeClubRead:     PermissionViewClub,
+	ScopeMatchesRead:  PermissionViewClub,
+	ScopeMatchesWrite: PermissionRecordMatches,
+	ScopeStatsRead:    PermissionViewClub,
+	ScopeEventsRead:   PermissionViewClub,
+	ScopeEventsWrite:  PermissionManageEvents,
+}
+
+// Scopes lists every known scope in a stable order.
+var Scopes = []Scope{
+	ScopeClubRead,
+	ScopeMatchesRead,
+	ScopeMatchesWrite,
+	ScopeStatsRead,
+	ScopeEventsRead,
+	ScopeEventsWrite,
+}
+
+func (s Scope) IsValid() bool {
+	_, ok := scopePermissions[s]
+	return ok
+}
+
+func (s Scope) Permission() Permission {
+	return scopePermissions[s]
+}
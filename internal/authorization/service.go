@@ -2,52 +2,197 @@ package authorization
 
 import (
 	"context"
+	"core/internal/audit"
 	"core/internal/member"
+	"fmt"
 
 	"github.com/google/uuid"
 )
 
+var (
+	ErrForbidden         = fmt.Errorf("forbidden")
+	ErrInvalidPermission = fmt.Errorf("invalid permission")
+	// ErrTwoFactorRequired is returned when the club requires its admins and
+	// owners to have passed two-factor authentication.
+	ErrTwoFactorRequired = fmt.Errorf("two-factor authentication required")
+)
+
 type Service interface {
-	IsMember(ctx context.Context, userID, clubID uuid.UUID) (bool, error)
-	IsAdmin(ctx context.Context, userID, clubID uuid.UUID) (bool, error)
+	// Require returns ErrForbidden unless the user is a member of the club
+	// whose role holds the permission.
+	Require(ctx context.Context, userID, clubID uuid.UUID, permission Permission) error
+	// RequireRole returns ErrForbidden unless the role holds the permission in
+	// the club. Use it when the caller's role is already known, e.g. from token claims.
+	RequireRole(ctx context.Context, clubID uuid.UUID, role member.Role, permission Permission) error
+	// GetRole returns the user's role in the club, RoleNone if not a member.
+	GetRole(ctx context.Context, userID, clubID uuid.UUID) (member.Role, error)
+	// RequireTwoFactor returns ErrTwoFactorRequired for admins and owners of
+	// clubs requiring two-factor authentication. Call it for sessions that did
+	// not pass a second factor.
+	RequireTwoFactor(ctx context.Context, clubID uuid.UUID, role member.Role) error
+	GetPermissions(ctx context.Context, clubID uuid.UUID) (map[member.Role][]Permission, error)
+	SetOverride(ctx context.Context, actorID, clubID uuid.UUID, role member.Role, permission Permission, granted bool) error
+	DeleteOverride(ctx context.Context, actorID, clubID uuid.UUID, role member.Role, permission Permission) error
 }
 
 type service struct {
+	repo          Repository
 	memberService member.Service
 }
 
-func NewService(memberService member.Service) Service {
+func NewService(repo Repository, memberService member.Service) Service {
 	return &service{
+		repo:          repo,
 		memberService: memberService,
 	}
 }
 
-func (s *service) IsMember(ctx context.Context, userID, clubID uuid.UUID) (bool, error) {
+func (s *service) Require(ctx context.Context, userID, clubID uuid.UUID, permission Permission) error {
+	role, err := s.GetRole(ctx, userID, clubID)
+	if err != nil {
+		return err
+	}
+
+	return s.RequireRole(ctx, clubID, role, permission)
+}
+
+func (s *service) GetRole(ctx context.Context, userID, clubID uuid.UUID) (member.Role, error) {
 	memberships, err := s.memberService.GetUserMemberships(ctx, userID)
 	if err != nil {
-		return false, err
+		return member.RoleNone, fmt.Errorf("failed to get memberships: %w", err)
 	}
 
 	for _, membership := range memberships {
-		if membership.UserID == userID {
-			return true, nil
+		if membership.ClubID == clubID {
+			return membership.Role, nil
 		}
 	}
 
-	return false, nil
+	return member.RoleNone, nil
 }
 
-func (s *service) IsAdmin(ctx context.Context, userID, clubID uuid.UUID) (bool, error) {
-	memberships, err := s.memberService.GetUserMemberships(ctx, userID)
+func (s *service) RequireTwoFactor(ctx context.Context, clubID uuid.UUID, role member.Role) error {
+	if !role.AtLeast(member.RoleAdmin) {
+		return nil
+	}
+
+	required, err := s.repo.GetRequireTwoFactor(ctx, clubID)
 	if err != nil {
-		return false, err
+		return fmt.Errorf("failed to get two factor requirement: %w", err)
+	}
+	if required {
+		return ErrTwoFactorRequired
 	}
 
-	for _, membership := range memberships {
-		if membership.UserID == userID && membership.Role == member.RoleAdmin {
-			return true, nil
+	return nil
+}
+
+func (s *service) RequireRole(ctx context.Context, clubID uuid.UUID, role member.Role, permission Permission) error {
+	if role.Level() == 0 {
+		return ErrForbidden
+	}
+
+	overrides, err := s.repo.GetOverrides(ctx, clubID)
+	if err != nil {
+		return fmt.Errorf("failed to get permission overrides: %w", err)
+	}
+
+	if !isGranted(overrides, role, permission) {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+func (s *service) GetPermissions(ctx context.Context, clubID uuid.UUID) (map[member.Role][]Permission, error) {
+	overrides, err := s.repo.GetOverrides(ctx, clubID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission overrides: %w", err)
+	}
+
+	permissions := make(map[member.Role][]Permission, len(Roles))
+	for _, role := range Roles {
+		permissions[role] = []Permission{}
+		for _, permission := range Permissions {
+			if isGranted(overrides, role, permission) {
+				permissions[role] = append(permissions[role], permission)
+			}
+		}
+	}
+
+	return permissions, nil
+}
+
+func (s *service) SetOverride(ctx context.Context, actorID, clubID uuid.UUID, role member.Role, permission Permission, granted bool) error {
+	if err := validateOverride(role, permission); err != nil {
+		return err
+	}
+
+	override := &Override{
+		ClubID:     clubID,
+		Role:       role,
+		Permission: permission,
+		Granted:    granted,
+	}
+
+	entry := audit.NewEntry(clubID, actorID, audit.ActionPermissionChanged, uuid.Nil)
+	entry.Details = audit.Details{"role": role, "permission": permission}
+	entry.After = audit.Snapshot{"granted": granted}
+
+	if err := s.repo.UpsertOverride(ctx, override, entry); err != nil {
+		return fmt.Errorf("failed to set permission override: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) DeleteOverride(ctx context.Context, actorID, clubID uuid.UUID, role member.Role, permission Permission) error {
+	if err := validateOverride(role, permission); err != nil {
+		return err
+	}
+
+	override := &Override{
+		ClubID:     clubID,
+		Role:       role,
+		Permission: permission,
+	}
+
+	entry := audit.NewEntry(clubID, actorID, audit.ActionPermissionReset, uuid.Nil)
+	entry.Details = audit.Details{"role": role, "permission": permission}
+
+	if err := s.repo.DeleteOverride(ctx, override, entry); err != nil {
+		return fmt.Errorf("failed to delete permission override: %w", err)
+	}
+
+	return nil
+}
+
+func validateOverride(role member.Role, permission Permission) error {
+	if !permission.IsValid() || !permission.IsOverridable() {
+		return fmt.Errorf("%w: %s cannot be overridden", ErrInvalidPermission, permission)
+	}
+	if role.Level() == 0 {
+		return fmt.Errorf("%w: role %s cannot hold permissions", ErrInvalidPermission, role)
+	}
+
+	return nil
+}
+
+// isGranted applies the club's override for the role if there is one, and
+// otherwise falls back to the default role hierarchy.
+func isGranted(overrides []Override, role member.Role, permission Permission) bool {
+	if permission.IsOverridable() {
+		for _, o := range overrides {
+			if o.Role == role && o.Permission == permission {
+				return o.Granted
+			}
 		}
 	}
 
-	return false, nil
+	minimum, ok := defaultMinimumRoles[permission]
+	if !ok {
+		return false
+	}
+
+	return role.AtLeast(minimum)
 }
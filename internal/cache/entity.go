@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a login on one device, tracked for as long as its refresh token
+// family is alive. The session ID is the family ID.
+type Session struct {
+	ID         string
+	UserID     uuid.UUID
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+}
+
+// OIDCFlow is an authorization code flow in progress, keyed by its state. A
+// nil user ID means the user is logging in, otherwise they are linking the
+// provider to their account.
+type OIDCFlow struct {
+	Provider string
+	Verifier string
+	Nonce    string
+	UserID   uuid.UUID
+}
+
+// LoginChallenge is a login waiting for its second factor. It remembers the
+// client the first factor was presented from, which the session is created for.
+type LoginChallenge struct {
+	UserID    uuid.UUID
+	UserAgent string
+	IP        string
+}
@@ -3,3 +3,27 @@ package cache
 func denylistTokenKey(tokenID string) string {
 	return "denylist:" + tokenID
 }
+
+func revokedBeforeKey(userID string) string {
+	return "revoked:before:" + userID
+}
+
+func membershipsChangedKey(userID string) string {
+	return "memberships:changed:" + userID
+}
+
+func tokenFamilyKey(familyID string) string {
+	return "family:" + familyID
+}
+
+func userSessionsKey(userID string) string {
+	return "sessions:" + userID
+}
+
+func oidcFlowKey(state string) string {
+	return "oidc:" + state
+}
+
+func loginChallengeKey(challengeID string) string {
+	return "challenge:" + challengeID
+}
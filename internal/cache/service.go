@@ -2,34 +2,354 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 type Service interface {
-	SetTokenUsed(ctx context.Context, token string) error
-	GetTokenUsed(ctx context.Context, token string) (bool, error)
+	// RevokeToken denylists the token ID until the token would have expired anyway.
+	RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+	// RevokeUserTokens revokes every token issued to the user until now.
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID) error
+	GetUserTokensRevokedBefore(ctx context.Context, userID uuid.UUID) (revokedBefore time.Time, revoked bool, err error)
+	// SetMembershipsChanged records that the user's club memberships changed,
+	// making memberships embedded in previously issued access tokens stale.
+	SetMembershipsChanged(ctx context.Context, userID uuid.UUID) error
+	GetMembershipsChanged(ctx context.Context, userID uuid.UUID) (changedAt time.Time, changed bool, err error)
+	// CreateTokenFamily starts a refresh token family for the session, whose only
+	// valid token is tokenID, and registers the session with its user.
+	CreateTokenFamily(ctx context.Context, session Session, tokenID string, expiry time.Duration) error
+	// RotateTokenFamily replaces the family's valid token, spending the old one.
+	// Presenting any other token revokes the whole family, and rotated is false.
+	RotateTokenFamily(ctx context.Context, familyID, oldTokenID, newTokenID string, expiry time.Duration) (rotated bool, err error)
+	// TouchTokenFamily updates the session's last seen time and reports whether it is still active.
+	TouchTokenFamily(ctx context.Context, familyID string) (active bool, err error)
+	RevokeTokenFamily(ctx context.Context, familyID string) error
+	GetSession(ctx context.Context, familyID string) (session *Session, found bool, err error)
+	GetUserSessions(ctx context.Context, userID uuid.UUID) ([]Session, error)
+	CreateOIDCFlow(ctx context.Context, state string, flow OIDCFlow, expiry time.Duration) error
+	// ConsumeOIDCFlow returns and deletes the flow, so each state is used at most once.
+	ConsumeOIDCFlow(ctx context.Context, state string) (flow *OIDCFlow, found bool, err error)
+	CreateLoginChallenge(ctx context.Context, challengeID string, challenge LoginChallenge, expiry time.Duration) error
+	// AttemptLoginChallenge counts an attempt at answering the challenge and
+	// returns it, unless it expired or ran out of attempts, which deletes it.
+	AttemptLoginChallenge(ctx context.Context, challengeID string, maxAttempts int) (challenge *LoginChallenge, found bool, err error)
+	DeleteLoginChallenge(ctx context.Context, challengeID string) error
 }
 
 type service struct {
-	client         *redis.Client
-	denylistExpiry time.Duration
+	client *redis.Client
+	// accessExpiry is the lifetime of access tokens, bounding how long user wide
+	// markers must be kept before every token they apply to has expired.
+	accessExpiry time.Duration
 }
 
-func NewService(client *redis.Client, denylistExpiry time.Duration) Service {
+func NewService(client *redis.Client, accessExpiry time.Duration) Service {
 	return &service{
-		denylistExpiry: denylistExpiry,
-		client:         client,
+		accessExpiry: accessExpiry,
+		client:       client,
 	}
 }
 
-func (s *service) SetTokenUsed(ctx context.Context, token string) error {
-	key := denylistTokenKey(token)
-	return s.client.Set(ctx, key, true, s.denylistExpiry).Err()
+func (s *service) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	key := denylistTokenKey(tokenID)
+	return s.client.Set(ctx, key, true, ttl).Err()
+}
+
+func (s *service) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	key := denylistTokenKey(tokenID)
+	n, err := s.client.Exists(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (s *service) RevokeUserTokens(ctx context.Context, userID uuid.UUID) error {
+	key := revokedBeforeKey(userID.String())
+	return s.client.Set(ctx, key, time.Now().UnixMilli(), s.accessExpiry).Err()
+}
+
+func (s *service) GetUserTokensRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, bool, error) {
+	return s.getTime(ctx, revokedBeforeKey(userID.String()))
+}
+
+func (s *service) SetMembershipsChanged(ctx context.Context, userID uuid.UUID) error {
+	key := membershipsChangedKey(userID.String())
+	return s.client.Set(ctx, key, time.Now().UnixMilli(), s.accessExpiry).Err()
+}
+
+func (s *service) GetMembershipsChanged(ctx context.Context, userID uuid.UUID) (time.Time, bool, error) {
+	return s.getTime(ctx, membershipsChangedKey(userID.String()))
+}
+
+// getTime reads a unix timestamp in milliseconds, reporting whether the key
+// was set. Seconds would be too coarse to tell apart tokens issued in the same
+// second as the change.
+func (s *service) getTime(ctx context.Context, key string) (time.Time, bool, error) {
+	unix, err := s.client.Get(ctx, key).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+
+	return time.UnixMilli(unix), true, nil
+}
+
+func (s *service) CreateTokenFamily(ctx context.Context, session Session, tokenID string, expiry time.Duration) error {
+	key := tokenFamilyKey(session.ID)
+	sessionsKey := userSessionsKey(session.UserID.String())
+	now := time.Now().Unix()
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key,
+		"token_id", tokenID,
+		"user_id", session.UserID.String(),
+		"user_agent", session.UserAgent,
+		"ip", session.IP,
+		"created_at", now,
+		"last_seen_at", now,
+	)
+	pipe.Expire(ctx, key, expiry)
+	pipe.SAdd(ctx, sessionsKey, session.ID)
+	pipe.Expire(ctx, sessionsKey, expiry)
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+// rotateTokenFamily swaps the family's token if the presented one is current,
+// and otherwise deletes the family, as a spent token is being reused.
+var rotateTokenFamily = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'token_id')
+if not current then
+	return 0
+end
+if current ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return 0
+end
+redis.call('HSET', KEYS[1], 'token_id', ARGV[2], 'last_seen_at', ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+func (s *service) RotateTokenFamily(ctx context.Context, familyID, oldTokenID, newTokenID string, expiry time.Duration) (bool, error) {
+	key := tokenFamilyKey(familyID)
+	rotated, err := rotateTokenFamily.Run(ctx, s.client, []string{key}, oldTokenID, newTokenID, expiry.Milliseconds(), time.Now().Unix()).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return rotated == 1, nil
+}
+
+// touchTokenFamily only updates families that exist, so revoked sessions are
+// not recreated by a late request.
+var touchTokenFamily = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'last_seen_at', ARGV[1])
+return 1
+`)
+
+func (s *service) TouchTokenFamily(ctx context.Context, familyID string) (bool, error) {
+	key := tokenFamilyKey(familyID)
+	active, err := touchTokenFamily.Run(ctx, s.client, []string{key}, time.Now().Unix()).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return active == 1, nil
+}
+
+// The family is removed from the user's sessions lazily, when listing them.
+func (s *service) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	key := tokenFamilyKey(familyID)
+	return s.client.Del(ctx, key).Err()
+}
+
+func (s *service) GetSession(ctx context.Context, familyID string) (*Session, bool, error) {
+	values, err := s.client.HGetAll(ctx, tokenFamilyKey(familyID)).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(values) == 0 {
+		return nil, false, nil
+	}
+
+	session, err := parseSession(familyID, values)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return session, true, nil
 }
 
-func (s *service) GetTokenUsed(ctx context.Context, token string) (bool, error) {
-	key := denylistTokenKey(token)
-	return s.client.Get(ctx, key).Bool()
+func (s *service) GetUserSessions(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	sessionsKey := userSessionsKey(userID.String())
+	familyIDs, err := s.client.SMembers(ctx, sessionsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(familyIDs))
+	for _, familyID := range familyIDs {
+		session, found, err := s.GetSession(ctx, familyID)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			// Expired or revoked since it was registered
+			if err := s.client.SRem(ctx, sessionsKey, familyID).Err(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		sessions = append(sessions, *session)
+	}
+
+	return sessions, nil
+}
+
+func (s *service) CreateOIDCFlow(ctx context.Context, state string, flow OIDCFlow, expiry time.Duration) error {
+	key := oidcFlowKey(state)
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key,
+		"provider", flow.Provider,
+		"verifier", flow.Verifier,
+		"nonce", flow.Nonce,
+		"user_id", flow.UserID.String(),
+	)
+	pipe.Expire(ctx, key, expiry)
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+func (s *service) ConsumeOIDCFlow(ctx context.Context, state string) (*OIDCFlow, bool, error) {
+	key := oidcFlowKey(state)
+
+	pipe := s.client.TxPipeline()
+	get := pipe.HGetAll(ctx, key)
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, false, err
+	}
+
+	values := get.Val()
+	if len(values) == 0 {
+		return nil, false, nil
+	}
+
+	userID, err := uuid.Parse(values["user_id"])
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse oidc flow user id: %w", err)
+	}
+
+	return &OIDCFlow{
+		Provider: values["provider"],
+		Verifier: values["verifier"],
+		Nonce:    values["nonce"],
+		UserID:   userID,
+	}, true, nil
+}
+
+func (s *service) CreateLoginChallenge(ctx context.Context, challengeID string, challenge LoginChallenge, expiry time.Duration) error {
+	key := loginChallengeKey(challengeID)
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key,
+		"user_id", challenge.UserID.String(),
+		"user_agent", challenge.UserAgent,
+		"ip", challenge.IP,
+		"attempts", 0,
+	)
+	pipe.Expire(ctx, key, expiry)
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+// attemptLoginChallenge counts the attempt and returns the challenge fields,
+// or nothing once the attempts are used up.
+var attemptLoginChallenge = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return {}
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if attempts > tonumber(ARGV[1]) then
+	redis.call('DEL', KEYS[1])
+	return {}
+end
+return redis.call('HMGET', KEYS[1], 'user_id', 'user_agent', 'ip')
+`)
+
+func (s *service) AttemptLoginChallenge(ctx context.Context, challengeID string, maxAttempts int) (*LoginChallenge, bool, error) {
+	key := loginChallengeKey(challengeID)
+	values, err := attemptLoginChallenge.Run(ctx, s.client, []string{key}, maxAttempts).StringSlice()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(values) != 3 {
+		return nil, false, nil
+	}
+
+	userID, err := uuid.Parse(values[0])
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse challenge user id: %w", err)
+	}
+
+	return &LoginChallenge{
+		UserID:    userID,
+		UserAgent: values[1],
+		IP:        values[2],
+	}, true, nil
+}
+
+func (s *service) DeleteLoginChallenge(ctx context.Context, challengeID string) error {
+	return s.client.Del(ctx, loginChallengeKey(challengeID)).Err()
+}
+
+func parseSession(familyID string, values map[string]string) (*Session, error) {
+	userID, err := uuid.Parse(values["user_id"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse session user id: %w", err)
+	}
+
+	createdAt, err := strconv.ParseInt(values["created_at"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse session creation time: %w", err)
+	}
+
+	lastSeenAt, err := strconv.ParseInt(values["last_seen_at"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse session last seen time: %w", err)
+	}
+
+	return &Session{
+		ID:         familyID,
+		UserID:     userID,
+		UserAgent:  values["user_agent"],
+		IP:         values["ip"],
+		CreatedAt:  time.Unix(createdAt, 0),
+		LastSeenAt: time.Unix(lastSeenAt, 0),
+	}, nil
 }
@@ -2,6 +2,9 @@ package club
 
 import (
 	"core/internal/member"
+	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -14,10 +17,62 @@ const (
 	InitiatorUser
 )
 
+// JoinPolicy controls whether a club is listed publicly and how users join it.
+type JoinPolicy string
+
+const (
+	JoinPolicyOpen     JoinPolicy = "open"     // Public, anyone can join directly
+	JoinPolicyApproval JoinPolicy = "approval" // Public, join requests must be approved
+	JoinPolicyInvite   JoinPolicy = "invite"   // Private, only invites and invite links
+)
+
+func (p JoinPolicy) IsPublic() bool {
+	return p == JoinPolicyOpen || p == JoinPolicyApproval
+}
+
 type Club struct {
-	ID        uuid.UUID `db:"id"`
-	Name      string    `db:"name"`
-	CreatedAt string    `db:"created_at"`
+	ID         uuid.UUID  `db:"id"`
+	Name       string     `db:"name"`
+	Location   string     `db:"location"`
+	JoinPolicy JoinPolicy `db:"join_policy"`
+	CreatedAt  string     `db:"created_at"`
+	// RequireTwoFactor restricts admins and owners to sessions that passed two-factor authentication
+	RequireTwoFactor bool `db:"require_two_factor"`
+	// DeletedAt is set while the club awaits being purged, and can still be restored
+	DeletedAt *time.Time `db:"deleted_at"`
+}
+
+// SearchFilter narrows down public clubs. Empty fields match everything.
+type SearchFilter struct {
+	Name     string
+	Location string
+	Game     string
+	Limit    int
+	Offset   int
+}
+
+// SearchResult is a public club found by a search, with the names of the
+// games played in it.
+type SearchResult struct {
+	Club
+	GameNames Names `db:"game_names"`
+}
+
+// Names is a list of names aggregated into a JSON array by a query.
+type Names []string
+
+func (n *Names) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("cannot scan %T into names", src)
+	}
+
+	return json.Unmarshal(b, n)
 }
 
 type Invite struct {
@@ -26,4 +81,19 @@ type Invite struct {
 	UserId    uuid.UUID   `db:"user_id"`
 	Initiator Initiator   `db:"initiator"`
 	Role      member.Role `db:"role"`
+	CreatedAt time.Time   `db:"created_at"`
+}
+
+// InviteLink is a shareable code that lets any user join the club with the
+// given role, until it expires or runs out of uses.
+type InviteLink struct {
+	ID        uuid.UUID   `db:"id"`
+	ClubID    uuid.UUID   `db:"club_id"`
+	Code      string      `db:"code"`
+	Role      member.Role `db:"role"`
+	MaxUses   *int        `db:"max_uses"`
+	Uses      int         `db:"uses"`
+	ExpiresAt *time.Time  `db:"expires_at"`
+	CreatedBy uuid.UUID   `db:"created_by"`
+	CreatedAt time.Time   `db:"created_at"`
 }
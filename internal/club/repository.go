@@ -2,35 +2,65 @@ package club
 
 import (
 	"context"
+	"core/internal/audit"
 	"core/internal/game"
 	"core/internal/member"
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
 var (
-	ErrDuplicateEntry = fmt.Errorf("duplicate entry")
-	ErrNotFound       = fmt.Errorf("not found")
+	ErrDuplicateEntry    = fmt.Errorf("duplicate entry")
+	ErrNotFound          = fmt.Errorf("not found")
+	ErrInviteLinkInvalid = fmt.Errorf("invite link is expired or used up")
+	ErrAlreadyMember     = fmt.Errorf("user is already a member of this club")
+	ErrInviteExists      = fmt.Errorf("user already has a pending invite")
+	ErrJoinNotAllowed    = fmt.Errorf("club can only be joined by invite")
 )
 
 type Repository interface {
 	GetClub(ctx context.Context, id uuid.UUID) (*Club, error)
 	GetClubs(ctx context.Context, ids []uuid.UUID) ([]Club, error)
-	CreateClub(ctx context.Context, Club *Club) (clubId uuid.UUID, err error)
-	DeleteClub(ctx context.Context, id uuid.UUID) error
-	UpdateClub(ctx context.Context, id uuid.UUID, name string) error
+	// CreateClub inserts the club along with its owner, so no club is left
+	// without one.
+	CreateClub(ctx context.Context, club *Club, ownerId uuid.UUID) (clubId uuid.UUID, err error)
+	// DeleteClub marks the club deleted, hiding it from every query until it
+	// is restored or purged.
+	DeleteClub(ctx context.Context, id uuid.UUID, entry *audit.Entry) error
+	GetDeletedClub(ctx context.Context, id uuid.UUID) (*Club, error)
+	RestoreClub(ctx context.Context, id uuid.UUID, entry *audit.Entry) error
+	// PurgeDeletedClubs removes clubs deleted before the given time for good.
+	PurgeDeletedClubs(ctx context.Context, deletedBefore time.Time) (int64, error)
+	// UpdateClub, SetRequireTwoFactor, DeleteClub and RestoreClub write the
+	// audit entry along with the change.
+	UpdateClub(ctx context.Context, club *Club, entry *audit.Entry) error
+	SetRequireTwoFactor(ctx context.Context, id uuid.UUID, required bool, entry *audit.Entry) error
+	SearchClubs(ctx context.Context, filter SearchFilter) ([]SearchResult, error)
 	GetGames(ctx context.Context, clubID uuid.UUID) ([]game.Game, error)
-	CreateMember(ctx context.Context, member *member.Member) error
+	// CreateMember, CreateInvite, AcceptInvite and CreateInviteLink write the
+	// audit entry along with the change, targeting the row they insert.
+	CreateMember(ctx context.Context, member *member.Member, entry *audit.Entry) error
 	IsMember(ctx context.Context, userId, clubId uuid.UUID) (bool, error)
-	CreateInvite(ctx context.Context, invite *Invite) error
+	CreateInvite(ctx context.Context, invite *Invite, entry *audit.Entry) error
 	GetPendingInvites(ctx context.Context, clubId uuid.UUID) ([]Invite, error)
 	GetUserInvites(ctx context.Context, userId uuid.UUID) ([]Invite, error)
 	GetInvite(ctx context.Context, id uuid.UUID) (*Invite, error)
 	DeleteInvite(ctx context.Context, id uuid.UUID) error
+	// AcceptInvite creates the invited membership and deletes the invite in a
+	// single transaction.
+	AcceptInvite(ctx context.Context, invite *Invite, member *member.Member, entry *audit.Entry) error
+	CreateInviteLink(ctx context.Context, link *InviteLink, entry *audit.Entry) (uuid.UUID, error)
+	GetInviteLinks(ctx context.Context, clubId uuid.UUID) ([]InviteLink, error)
+	GetInviteLink(ctx context.Context, id uuid.UUID) (*InviteLink, error)
+	DeleteInviteLink(ctx context.Context, id uuid.UUID) error
+	// RedeemInviteLink writes the audit entry along with the new membership,
+	// filling in the link's club and details, and the member as target.
+	RedeemInviteLink(ctx context.Context, code string, userId uuid.UUID, entry *audit.Entry) (*InviteLink, error)
 }
 
 type repository struct {
@@ -44,9 +74,9 @@ func NewRepository(db *sqlx.DB) Repository {
 }
 
 func (r *repository) GetClub(ctx context.Context, id uuid.UUID) (*Club, error) {
-	var c *Club
+	var c Club
 
-	err := r.db.GetContext(ctx, c, "SELECT * FROM clubs WHERE id = $1", id)
+	err := r.db.GetContext(ctx, &c, "SELECT * FROM clubs WHERE id = $1 AND deleted_at IS NULL", id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -54,13 +84,13 @@ func (r *repository) GetClub(ctx context.Context, id uuid.UUID) (*Club, error) {
 		return nil, err
 	}
 
-	return c, nil
+	return &c, nil
 }
 
 func (r *repository) GetClubs(ctx context.Context, ids []uuid.UUID) ([]Club, error) {
 	var clubs []Club
 
-	query, args, err := sqlx.In("SELECT * FROM clubs WHERE id IN (?)", ids)
+	query, args, err := sqlx.In("SELECT * FROM clubs WHERE id IN (?) AND deleted_at IS NULL", ids)
 	if err != nil {
 		return nil, err
 	}
@@ -74,40 +104,149 @@ func (r *repository) GetClubs(ctx context.Context, ids []uuid.UUID) ([]Club, err
 	return clubs, nil
 }
 
-func (r *repository) CreateClub(ctx context.Context, c *Club) (uuid.UUID, error) {
+func (r *repository) CreateClub(ctx context.Context, c *Club, ownerId uuid.UUID) (uuid.UUID, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	var id uuid.UUID
-	err := r.db.QueryRowContext(ctx,
-		"INSERT INTO clubs (name) VALUES ($1) RETURNING id",
-		c.Name).Scan(&id)
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO clubs (name, location, join_policy) VALUES ($1, $2, $3) RETURNING id",
+		c.Name, c.Location, c.JoinPolicy).Scan(&id)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, fmt.Errorf("failed to insert club: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO members (club_id, user_id, role) VALUES ($1, $2, $3)",
+		id, ownerId, member.RoleOwner)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create owner: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return id, nil
 }
 
-func (r *repository) DeleteClub(ctx context.Context, id uuid.UUID) error {
-	_, err := r.db.ExecContext(ctx, "DELETE FROM clubs WHERE id = $1", id)
+func (r *repository) DeleteClub(ctx context.Context, id uuid.UUID, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			"UPDATE clubs SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL",
+			id)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+func (r *repository) GetDeletedClub(ctx context.Context, id uuid.UUID) (*Club, error) {
+	var c Club
+
+	err := r.db.GetContext(ctx, &c, "SELECT * FROM clubs WHERE id = $1 AND deleted_at IS NOT NULL", id)
 	if err != nil {
-		return err
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
 	}
 
-	return nil
+	return &c, nil
+}
+
+func (r *repository) RestoreClub(ctx context.Context, id uuid.UUID, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			"UPDATE clubs SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL",
+			id)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
 }
 
-func (r *repository) UpdateClub(ctx context.Context, id uuid.UUID, name string) error {
-	_, err := r.db.ExecContext(ctx, "UPDATE clubs SET name = $1 WHERE id = $2", name, id)
+func (r *repository) PurgeDeletedClubs(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM clubs WHERE deleted_at < $1", deletedBefore)
 	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (r *repository) UpdateClub(ctx context.Context, c *Club, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			"UPDATE clubs SET name = $1, location = $2, join_policy = $3 WHERE id = $4 AND deleted_at IS NULL",
+			c.Name, c.Location, c.JoinPolicy, c.ID)
 		return err
+	})
+}
+
+func (r *repository) SetRequireTwoFactor(ctx context.Context, id uuid.UUID, required bool, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			"UPDATE clubs SET require_two_factor = $1 WHERE id = $2 AND deleted_at IS NULL",
+			required, id)
+		return err
+	})
+}
+
+func (r *repository) SearchClubs(ctx context.Context, filter SearchFilter) ([]SearchResult, error) {
+	var clubs []SearchResult
+
+	err := r.db.SelectContext(ctx, &clubs, `
+		SELECT c.*, COALESCE((
+			SELECT array_to_json(array_agg(g.name ORDER BY g.name))
+			FROM games g
+			WHERE g.club_id = c.id AND g.deleted_at IS NULL
+		), '[]') AS game_names
+		FROM clubs c
+		WHERE c.deleted_at IS NULL
+		AND c.join_policy IN ('open', 'approval')
+		AND ($1 = '' OR c.name ILIKE '%' || $1 || '%')
+		AND ($2 = '' OR c.location ILIKE '%' || $2 || '%')
+		AND ($3 = '' OR EXISTS (
+			SELECT 1 FROM games g WHERE g.club_id = c.id AND g.deleted_at IS NULL AND g.name ILIKE '%' || $3 || '%'
+		))
+		ORDER BY c.name, c.id
+		LIMIT $4 OFFSET $5`,
+		filter.Name, filter.Location, filter.Game, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return clubs, nil
 }
 
 func (r *repository) GetGames(ctx context.Context, clubID uuid.UUID) ([]game.Game, error) {
 	var games []game.Game
 
-	err := r.db.SelectContext(ctx, &games, "SELECT * FROM games WHERE club_id = $1", clubID)
+	err := r.db.SelectContext(ctx, &games, "SELECT * FROM games WHERE club_id = $1 AND deleted_at IS NULL", clubID)
 	if err != nil {
 		return nil, err
 	}
@@ -115,14 +254,18 @@ func (r *repository) GetGames(ctx context.Context, clubID uuid.UUID) ([]game.Gam
 	return games, nil
 }
 
-func (r *repository) CreateMember(ctx context.Context, member *member.Member) error {
-	_, err := r.db.ExecContext(ctx,
-		"INSERT INTO members (club_id, user_id, role) VALUES ($1, $2, $3)",
-		member.ClubID, member.UserID, member.Role)
-	if err != nil {
-		return err
-	}
-	return nil
+func (r *repository) CreateMember(ctx context.Context, member *member.Member, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			"INSERT INTO members (club_id, user_id, role) VALUES ($1, $2, $3) RETURNING id",
+			member.ClubID, member.UserID, member.Role).Scan(&member.ID)
+		if err != nil {
+			return err
+		}
+		entry.TargetID = &member.ID
+
+		return nil
+	})
 }
 
 func (r *repository) IsMember(ctx context.Context, userId, clubId uuid.UUID) (bool, error) {
@@ -136,14 +279,18 @@ func (r *repository) IsMember(ctx context.Context, userId, clubId uuid.UUID) (bo
 	return count > 0, nil
 }
 
-func (r *repository) CreateInvite(ctx context.Context, invite *Invite) error {
-	_, err := r.db.ExecContext(ctx,
-		"INSERT INTO club_invites (club_id, user_id, initiator) VALUES ($1, $2, $3)",
-		invite.ClubId, invite.UserId, invite.Initiator)
-	if err != nil {
-		return err
-	}
-	return nil
+func (r *repository) CreateInvite(ctx context.Context, invite *Invite, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			"INSERT INTO club_invites (club_id, user_id, initiator, role) VALUES ($1, $2, $3, $4) RETURNING id",
+			invite.ClubId, invite.UserId, invite.Initiator, invite.Role).Scan(&invite.ID)
+		if err != nil {
+			return err
+		}
+		entry.TargetID = &invite.ID
+
+		return nil
+	})
 }
 
 func (r *repository) GetPendingInvites(ctx context.Context, clubId uuid.UUID) ([]Invite, error) {
@@ -160,7 +307,7 @@ func (r *repository) GetPendingInvites(ctx context.Context, clubId uuid.UUID) ([
 func (r *repository) GetUserInvites(ctx context.Context, userId uuid.UUID) ([]Invite, error) {
 	var invites []Invite
 	err := r.db.SelectContext(ctx, &invites,
-		"SELECT * FROM club_invites WHERE user_id = $1",
+		"SELECT * FROM club_invites WHERE user_id = $1 AND club_id IN (SELECT id FROM clubs WHERE deleted_at IS NULL)",
 		userId)
 	if err != nil {
 		return nil, err
@@ -171,9 +318,12 @@ func (r *repository) GetUserInvites(ctx context.Context, userId uuid.UUID) ([]In
 func (r *repository) GetInvite(ctx context.Context, id uuid.UUID) (*Invite, error) {
 	var invite Invite
 	err := r.db.GetContext(ctx, &invite,
-		"SELECT * FROM club_invites WHERE id = $1",
+		"SELECT * FROM club_invites WHERE id = $1 AND club_id IN (SELECT id FROM clubs WHERE deleted_at IS NULL)",
 		id)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 	return &invite, nil
@@ -188,3 +338,168 @@ func (r *repository) DeleteInvite(ctx context.Context, id uuid.UUID) error {
 	}
 	return nil
 }
+
+func (r *repository) AcceptInvite(ctx context.Context, invite *Invite, member *member.Member, entry *audit.Entry) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		"DELETE FROM club_invites WHERE id = $1",
+		invite.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete invite: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	var count int
+	err = tx.GetContext(ctx, &count,
+		"SELECT COUNT(*) FROM members WHERE user_id = $1 AND club_id = $2",
+		member.UserID, member.ClubID)
+	if err != nil {
+		return fmt.Errorf("failed to check membership: %w", err)
+	}
+	if count > 0 {
+		return ErrAlreadyMember
+	}
+
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO members (club_id, user_id, role) VALUES ($1, $2, $3) RETURNING id",
+		member.ClubID, member.UserID, member.Role).Scan(&member.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create member: %w", err)
+	}
+
+	entry.TargetID = &member.ID
+	if err := audit.Insert(ctx, tx, entry); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) CreateInviteLink(ctx context.Context, link *InviteLink, entry *audit.Entry) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			"INSERT INTO club_invite_links (club_id, code, role, max_uses, expires_at, created_by) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+			link.ClubID, link.Code, link.Role, link.MaxUses, link.ExpiresAt, link.CreatedBy).Scan(&id)
+		if err != nil {
+			return err
+		}
+		entry.TargetID = &id
+
+		return nil
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return id, nil
+}
+
+func (r *repository) GetInviteLinks(ctx context.Context, clubId uuid.UUID) ([]InviteLink, error) {
+	var links []InviteLink
+	err := r.db.SelectContext(ctx, &links,
+		"SELECT * FROM club_invite_links WHERE club_id = $1 ORDER BY created_at DESC",
+		clubId)
+	if err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func (r *repository) GetInviteLink(ctx context.Context, id uuid.UUID) (*InviteLink, error) {
+	var link InviteLink
+	err := r.db.GetContext(ctx, &link,
+		"SELECT * FROM club_invite_links WHERE id = $1",
+		id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *repository) DeleteInviteLink(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM club_invite_links WHERE id = $1",
+		id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RedeemInviteLink consumes one use of the link and creates the membership in
+// a single transaction, so concurrent redemptions cannot exceed max_uses.
+func (r *repository) RedeemInviteLink(ctx context.Context, code string, userId uuid.UUID, entry *audit.Entry) (*InviteLink, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var link InviteLink
+	err = tx.GetContext(ctx, &link, `
+		UPDATE club_invite_links
+		SET uses = uses + 1
+		WHERE code = $1
+		AND club_id IN (SELECT id FROM clubs WHERE deleted_at IS NULL)
+		AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+		AND (max_uses IS NULL OR uses < max_uses)
+		RETURNING *`,
+		code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInviteLinkInvalid
+		}
+		return nil, fmt.Errorf("failed to use invite link: %w", err)
+	}
+
+	var count int
+	err = tx.GetContext(ctx, &count,
+		"SELECT COUNT(*) FROM members WHERE user_id = $1 AND club_id = $2",
+		userId, link.ClubID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check membership: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrAlreadyMember
+	}
+
+	var memberId uuid.UUID
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO members (club_id, user_id, role) VALUES ($1, $2, $3) RETURNING id",
+		link.ClubID, userId, link.Role).Scan(&memberId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create member: %w", err)
+	}
+
+	entry.ClubID = link.ClubID
+	entry.TargetID = &memberId
+	entry.Details = audit.Details{"inviteLinkId": link.ID}
+	entry.After = audit.Snapshot{"userId": userId, "role": link.Role}
+	if err := audit.Insert(ctx, tx, entry); err != nil {
+		return nil, fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &link, nil
+}
@@ -2,10 +2,17 @@ package club
 
 import (
 	"context"
+	"core/internal/activity"
+	"core/internal/audit"
 	"core/internal/game"
 	"core/internal/member"
 	"core/internal/subscription"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -14,27 +21,73 @@ type Service interface {
 	GetClub(ctx context.Context, id uuid.UUID) (*Club, error)
 	GetClubs(ctx context.Context, ids []uuid.UUID) ([]Club, error)
 	CreateClub(ctx context.Context, name string, userId uuid.UUID) (uuid.UUID, error)
-	DeleteClub(ctx context.Context, id uuid.UUID) error
-	UpdateClub(ctx context.Context, id uuid.UUID, name string) error
+	// DeleteClub hides the club from everyone, returning when it will be
+	// purged unless an owner restores it before.
+	DeleteClub(ctx context.Context, actorId, id uuid.UUID) (purgeAt time.Time, err error)
+	// RestoreClub brings back a deleted club, only its owners can do so.
+	RestoreClub(ctx context.Context, userId, clubId uuid.UUID) error
+	// PurgeDeletedClubs removes clubs whose retention period is over, along
+	// with everything recorded in them.
+	PurgeDeletedClubs(ctx context.Context) (int64, error)
+	UpdateClub(ctx context.Context, actorId, id uuid.UUID, name, location string, policy JoinPolicy) error
+	SetRequireTwoFactor(ctx context.Context, actorId, id uuid.UUID, required bool) error
+	SearchClubs(ctx context.Context, filter SearchFilter) ([]SearchResult, error)
+	JoinClub(ctx context.Context, clubId, userId uuid.UUID) (joined bool, err error)
 	GetGames(ctx context.Context, clubID uuid.UUID) ([]game.Game, error)
-	CreateInvite(ctx context.Context, clubId, userId uuid.UUID, initiator Initiator) error
+	// CreateInvite invites the user on behalf of invitedBy. Club invites may
+	// only hand out roles up to the inviting member's own.
+	CreateInvite(ctx context.Context, clubId, userId, invitedBy uuid.UUID, initiator Initiator, role member.Role) error
+	GetInvite(ctx context.Context, inviteId uuid.UUID) (*Invite, error)
 	GetPendingInvites(ctx context.Context, clubId uuid.UUID) ([]Invite, error)
 	GetUserInvites(ctx context.Context, userId uuid.UUID) ([]Invite, error)
-	AcceptInvite(ctx context.Context, inviteId uuid.UUID) error
+	// AcceptInvite lets the invited user, or whoever accepts a request to
+	// join, add the member.
+	AcceptInvite(ctx context.Context, actorId, inviteId uuid.UUID) error
 	RejectInvite(ctx context.Context, inviteId uuid.UUID) error
+
+	// Invite links
+	CreateInviteLink(ctx context.Context, clubId, createdBy uuid.UUID, role member.Role, expiresAt *time.Time, maxUses *int) (*InviteLink, error)
+	GetInviteLinks(ctx context.Context, clubId uuid.UUID) ([]InviteLink, error)
+	GetInviteLink(ctx context.Context, id uuid.UUID) (*InviteLink, error)
+	DeleteInviteLink(ctx context.Context, id uuid.UUID) error
+	RedeemInviteLink(ctx context.Context, code string, userId uuid.UUID) (clubId uuid.UUID, err error)
 }
 
+const (
+	inviteCodeBytes = 12
+	maxSearchLimit  = 50
+	// defaultRetentionPeriod is how long deleted clubs can be restored
+	defaultRetentionPeriod = 30 * 24 * time.Hour
+)
+
+var ErrNotOwner = fmt.Errorf("only owners can restore the club")
+
+var (
+	ErrInvalidInviteExpiry  = fmt.Errorf("invite link expiry must be in the future")
+	ErrInvalidInviteMaxUses = fmt.Errorf("invite link must allow at least one use")
+)
+
 type service struct {
+	l                   *slog.Logger
 	repo                Repository
 	memberService       member.Service
 	subscriptionService subscription.Service
+	activityService     activity.Service
+	retention           time.Duration
 }
 
-func NewService(repo Repository, memberService member.Service, subscriptionService subscription.Service) Service {
+func NewService(l *slog.Logger, repo Repository, memberService member.Service, subscriptionService subscription.Service, activityService activity.Service, retention time.Duration) Service {
+	if retention <= 0 {
+		retention = defaultRetentionPeriod
+	}
+
 	return &service{
+		l:                   l,
 		repo:                repo,
 		memberService:       memberService,
 		subscriptionService: subscriptionService,
+		activityService:     activityService,
+		retention:           retention,
 	}
 }
 
@@ -62,52 +115,199 @@ func (s *service) CreateClub(ctx context.Context, name string, userId uuid.UUID)
 		return uuid.Nil, fmt.Errorf("club name must be between 2 and 50 characters")
 	}
 
-	// Create club
+	// Create club with the user as its owner
 	club := &Club{
-		Name: name,
+		Name:       name,
+		JoinPolicy: JoinPolicyInvite,
 	}
-	clubId, err := s.repo.CreateClub(ctx, club)
+	clubId, err := s.repo.CreateClub(ctx, club, userId)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("failed to create club: %w", err)
 	}
 
-	// Create initial owner member
-	member := &member.Member{
-		ClubID: clubId,
-		UserID: userId,
-		Role:   member.RoleOwner,
-	}
-	if err := s.memberService.CreateMember(ctx, member); err != nil {
-		// If member creation fails, delete the club to maintain consistency
-		if delErr := s.repo.DeleteClub(ctx, clubId); delErr != nil {
-			return uuid.Nil, fmt.Errorf("failed to create member and cleanup club: %w (cleanup error: %v)", err, delErr)
+	return clubId, nil
+}
+
+func (s *service) DeleteClub(ctx context.Context, actorId, id uuid.UUID) (time.Time, error) {
+	if err := s.repo.DeleteClub(ctx, id, audit.NewEntry(id, actorId, audit.ActionClubDeleted, uuid.Nil)); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return time.Time{}, ErrNotFound
 		}
-		return uuid.Nil, fmt.Errorf("failed to create initial owner member: %w", err)
+		return time.Time{}, fmt.Errorf("failed to delete club: %w", err)
 	}
 
-	return clubId, nil
+	// Drop the club from the memberships embedded in the members' tokens
+	if err := s.memberService.ClubMembershipsChanged(ctx, id); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Now().Add(s.retention), nil
 }
 
-func (s *service) DeleteClub(ctx context.Context, id uuid.UUID) error {
-	if err := s.repo.DeleteClub(ctx, id); err != nil {
-		return fmt.Errorf("failed to delete club: %w", err)
+func (s *service) RestoreClub(ctx context.Context, userId, clubId uuid.UUID) error {
+	club, err := s.repo.GetDeletedClub(ctx, clubId)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get deleted club: %w", err)
+	}
+	// Past its retention the club is only waiting for the next purge
+	if time.Since(*club.DeletedAt) > s.retention {
+		return ErrNotFound
 	}
 
-	return nil
+	m, err := s.memberService.GetClubMember(ctx, clubId, userId)
+	if err != nil {
+		if errors.Is(err, member.ErrNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get member: %w", err)
+	}
+	if m.Role != member.RoleOwner {
+		return ErrNotOwner
+	}
+
+	if err := s.repo.RestoreClub(ctx, clubId, audit.NewEntry(clubId, userId, audit.ActionClubRestored, uuid.Nil)); err != nil {
+		return fmt.Errorf("failed to restore club: %w", err)
+	}
+
+	return s.memberService.ClubMembershipsChanged(ctx, clubId)
+}
+
+func (s *service) PurgeDeletedClubs(ctx context.Context) (int64, error) {
+	n, err := s.repo.PurgeDeletedClubs(ctx, time.Now().Add(-s.retention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted clubs: %w", err)
+	}
+
+	return n, nil
 }
 
-func (s *service) UpdateClub(ctx context.Context, id uuid.UUID, name string) error {
+func (s *service) UpdateClub(ctx context.Context, actorId, id uuid.UUID, name, location string, policy JoinPolicy) error {
 	if len(name) < 2 || len(name) > 50 {
 		return fmt.Errorf("club name must be between 2 and 50 characters")
 	}
+	if len(location) > 100 {
+		return fmt.Errorf("club location must be at most 100 characters")
+	}
+
+	switch policy {
+	case JoinPolicyOpen, JoinPolicyApproval, JoinPolicyInvite:
+	default:
+		return fmt.Errorf("invalid join policy: %s", policy)
+	}
+
+	before, err := s.repo.GetClub(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get club: %w", err)
+	}
+
+	club := &Club{
+		ID:         id,
+		Name:       name,
+		Location:   location,
+		JoinPolicy: policy,
+	}
 
-	if err := s.repo.UpdateClub(ctx, id, name); err != nil {
+	entry := audit.NewEntry(id, actorId, audit.ActionClubUpdated, uuid.Nil)
+	entry.Before = snapshot(before)
+	entry.After = snapshot(club)
+
+	if err := s.repo.UpdateClub(ctx, club, entry); err != nil {
 		return fmt.Errorf("failed to update club: %w", err)
 	}
 
 	return nil
 }
 
+func (s *service) SetRequireTwoFactor(ctx context.Context, actorId, id uuid.UUID, required bool) error {
+	before, err := s.repo.GetClub(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get club: %w", err)
+	}
+
+	entry := audit.NewEntry(id, actorId, audit.ActionClubSecurityChanged, uuid.Nil)
+	entry.Before = audit.Snapshot{"requireTwoFactor": before.RequireTwoFactor}
+	entry.After = audit.Snapshot{"requireTwoFactor": required}
+
+	if err := s.repo.SetRequireTwoFactor(ctx, id, required, entry); err != nil {
+		return fmt.Errorf("failed to set two factor requirement: %w", err)
+	}
+
+	return nil
+}
+
+// snapshot captures the club settings the audit log tracks.
+func snapshot(club *Club) audit.Snapshot {
+	return audit.Snapshot{
+		"name":       club.Name,
+		"location":   club.Location,
+		"joinPolicy": club.JoinPolicy,
+	}
+}
+
+func (s *service) SearchClubs(ctx context.Context, filter SearchFilter) ([]SearchResult, error) {
+	if filter.Limit <= 0 || filter.Limit > maxSearchLimit {
+		filter.Limit = maxSearchLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	clubs, err := s.repo.SearchClubs(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search clubs: %w", err)
+	}
+
+	return clubs, nil
+}
+
+// JoinClub lets a user join a club according to its join policy. Open clubs
+// are joined immediately, clubs requiring approval receive a join request, and
+// invite-only clubs cannot be joined this way.
+func (s *service) JoinClub(ctx context.Context, clubId, userId uuid.UUID) (bool, error) {
+	club, err := s.repo.GetClub(ctx, clubId)
+	if err != nil {
+		return false, fmt.Errorf("failed to get club: %w", err)
+	}
+
+	switch club.JoinPolicy {
+	case JoinPolicyOpen:
+		isMember, err := s.repo.IsMember(ctx, userId, clubId)
+		if err != nil {
+			return false, fmt.Errorf("failed to check membership: %w", err)
+		}
+		if isMember {
+			return false, ErrAlreadyMember
+		}
+
+		member := &member.Member{
+			ClubID: clubId,
+			UserID: &userId,
+			Role:   member.RoleMember,
+		}
+		entry := audit.NewEntry(clubId, userId, audit.ActionMemberJoined, uuid.Nil)
+		entry.After = audit.Snapshot{"userId": userId, "role": member.Role}
+
+		if err := s.repo.CreateMember(ctx, member, entry); err != nil {
+			return false, fmt.Errorf("failed to create member: %w", err)
+		}
+
+		s.memberJoined(ctx, clubId, userId)
+
+		return true, nil
+	case JoinPolicyApproval:
+		if err := s.CreateInvite(ctx, clubId, userId, userId, InitiatorUser, member.RoleMember); err != nil {
+			return false, err
+		}
+
+		return false, nil
+	default:
+		return false, ErrJoinNotAllowed
+	}
+}
+
 func (s *service) GetGames(ctx context.Context, clubID uuid.UUID) ([]game.Game, error) {
 	games, err := s.repo.GetGames(ctx, clubID)
 	if err != nil {
@@ -117,14 +317,23 @@ func (s *service) GetGames(ctx context.Context, clubID uuid.UUID) ([]game.Game,
 	return games, nil
 }
 
-func (s *service) CreateInvite(ctx context.Context, clubId, userId uuid.UUID, initiator Initiator) error {
+func (s *service) CreateInvite(ctx context.Context, clubId, userId, invitedBy uuid.UUID, initiator Initiator, role member.Role) error {
+	if role == "" {
+		role = member.RoleMember
+	}
+	if initiator == IniatorClub {
+		if err := s.checkInviter(ctx, clubId, invitedBy, role); err != nil {
+			return err
+		}
+	}
+
 	// Check if user is already a member
 	isMember, err := s.repo.IsMember(ctx, userId, clubId)
 	if err != nil {
 		return fmt.Errorf("failed to check membership: %w", err)
 	}
 	if isMember {
-		return fmt.Errorf("user is already a member of this club")
+		return ErrAlreadyMember
 	}
 
 	// Check if invite already exists
@@ -134,7 +343,7 @@ func (s *service) CreateInvite(ctx context.Context, clubId, userId uuid.UUID, in
 	}
 	for _, invite := range invites {
 		if invite.UserId == userId {
-			return fmt.Errorf("user already has a pending invite")
+			return ErrInviteExists
 		}
 	}
 
@@ -142,15 +351,28 @@ func (s *service) CreateInvite(ctx context.Context, clubId, userId uuid.UUID, in
 		ClubId:    clubId,
 		UserId:    userId,
 		Initiator: initiator,
+		Role:      role,
 	}
 
-	if err := s.repo.CreateInvite(ctx, invite); err != nil {
+	entry := audit.NewEntry(clubId, invitedBy, audit.ActionInviteCreated, uuid.Nil)
+	entry.After = audit.Snapshot{"userId": userId, "role": role, "initiator": initiator}
+
+	if err := s.repo.CreateInvite(ctx, invite, entry); err != nil {
 		return fmt.Errorf("failed to create invite: %w", err)
 	}
 
 	return nil
 }
 
+func (s *service) GetInvite(ctx context.Context, inviteId uuid.UUID) (*Invite, error) {
+	invite, err := s.repo.GetInvite(ctx, inviteId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite: %w", err)
+	}
+
+	return invite, nil
+}
+
 func (s *service) GetPendingInvites(ctx context.Context, clubId uuid.UUID) ([]Invite, error) {
 	return s.repo.GetPendingInvites(ctx, clubId)
 }
@@ -159,36 +381,33 @@ func (s *service) GetUserInvites(ctx context.Context, userId uuid.UUID) ([]Invit
 	return s.repo.GetUserInvites(ctx, userId)
 }
 
-func (s *service) AcceptInvite(ctx context.Context, inviteId uuid.UUID) error {
+func (s *service) AcceptInvite(ctx context.Context, actorId, inviteId uuid.UUID) error {
 	invite, err := s.repo.GetInvite(ctx, inviteId)
 	if err != nil {
 		return fmt.Errorf("failed to get invite: %w", err)
 	}
 
-	// Check if user is already a member
-	isMember, err := s.repo.IsMember(ctx, invite.UserId, invite.ClubId)
-	if err != nil {
-		return fmt.Errorf("failed to check membership: %w", err)
-	}
-	if isMember {
-		return fmt.Errorf("user is already a member of this club")
+	role := invite.Role
+	if role == "" {
+		role = member.RoleMember
 	}
 
-	// Create member
+	// Create member with the role the invite was issued for
 	member := &member.Member{
 		ClubID: invite.ClubId,
-		UserID: invite.UserId,
-		Role:   member.RoleMember,
-	}
-	if err := s.repo.CreateMember(ctx, member); err != nil {
-		return fmt.Errorf("failed to create member: %w", err)
+		UserID: &invite.UserId,
+		Role:   role,
 	}
+	entry := audit.NewEntry(invite.ClubId, actorId, audit.ActionMemberJoined, uuid.Nil)
+	entry.Details = audit.Details{"inviteId": invite.ID}
+	entry.After = audit.Snapshot{"userId": invite.UserId, "role": role}
 
-	// Delete invite
-	if err := s.repo.DeleteInvite(ctx, inviteId); err != nil {
-		return fmt.Errorf("failed to delete invite: %w", err)
+	if err := s.repo.AcceptInvite(ctx, invite, member, entry); err != nil {
+		return fmt.Errorf("failed to accept invite: %w", err)
 	}
 
+	s.memberJoined(ctx, invite.ClubId, invite.UserId)
+
 	return nil
 }
 
@@ -199,3 +418,130 @@ func (s *service) RejectInvite(ctx context.Context, inviteId uuid.UUID) error {
 
 	return nil
 }
+
+func (s *service) CreateInviteLink(ctx context.Context, clubId, createdBy uuid.UUID, role member.Role, expiresAt *time.Time, maxUses *int) (*InviteLink, error) {
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return nil, ErrInvalidInviteExpiry
+	}
+	if maxUses != nil && *maxUses < 1 {
+		return nil, ErrInvalidInviteMaxUses
+	}
+
+	if role == "" {
+		role = member.RoleMember
+	}
+	if err := s.checkInviter(ctx, clubId, createdBy, role); err != nil {
+		return nil, err
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invite code: %w", err)
+	}
+
+	link := &InviteLink{
+		ClubID:    clubId,
+		Code:      code,
+		Role:      role,
+		MaxUses:   maxUses,
+		ExpiresAt: expiresAt,
+		CreatedBy: createdBy,
+	}
+
+	entry := audit.NewEntry(clubId, createdBy, audit.ActionInviteLinkCreated, uuid.Nil)
+	entry.After = audit.Snapshot{"role": role, "maxUses": maxUses, "expiresAt": expiresAt}
+
+	id, err := s.repo.CreateInviteLink(ctx, link, entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invite link: %w", err)
+	}
+	link.ID = id
+
+	return link, nil
+}
+
+func (s *service) GetInviteLinks(ctx context.Context, clubId uuid.UUID) ([]InviteLink, error) {
+	links, err := s.repo.GetInviteLinks(ctx, clubId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite links: %w", err)
+	}
+
+	return links, nil
+}
+
+func (s *service) GetInviteLink(ctx context.Context, id uuid.UUID) (*InviteLink, error) {
+	link, err := s.repo.GetInviteLink(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite link: %w", err)
+	}
+
+	return link, nil
+}
+
+func (s *service) DeleteInviteLink(ctx context.Context, id uuid.UUID) error {
+	if err := s.repo.DeleteInviteLink(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete invite link: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) RedeemInviteLink(ctx context.Context, code string, userId uuid.UUID) (uuid.UUID, error) {
+	entry := audit.NewEntry(uuid.Nil, userId, audit.ActionMemberJoined, uuid.Nil)
+
+	link, err := s.repo.RedeemInviteLink(ctx, code, userId, entry)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to redeem invite link: %w", err)
+	}
+
+	s.memberJoined(ctx, link.ClubID, userId)
+
+	return link.ClubID, nil
+}
+
+// checkInviter returns member.ErrRoleTooHigh unless the inviting user ranks at
+// least as high as the role they hand out.
+func (s *service) checkInviter(ctx context.Context, clubId, userId uuid.UUID, role member.Role) error {
+	inviter, err := s.memberService.GetClubMember(ctx, clubId, userId)
+	if err != nil {
+		if errors.Is(err, member.ErrNotFound) {
+			return member.ErrRoleTooHigh
+		}
+		return fmt.Errorf("failed to get inviting member: %w", err)
+	}
+
+	if !inviter.Role.AtLeast(role) {
+		return member.ErrRoleTooHigh
+	}
+
+	return nil
+}
+
+// memberJoined announces the user's new membership in the club's feed. The
+// membership is already in place, so failures are only logged.
+func (s *service) memberJoined(ctx context.Context, clubId, userId uuid.UUID) {
+	m, err := s.memberService.GetClubMember(ctx, clubId, userId)
+	if err != nil {
+		s.l.Error("failed to get joined member", "club", clubId, "user", userId, "error", err)
+		return
+	}
+
+	err = s.activityService.Record(ctx, &activity.Activity{
+		ClubID:   clubId,
+		Kind:     activity.KindMemberJoined,
+		MemberID: &m.ID,
+		Data:     activity.Data{"role": m.Role},
+	})
+	if err != nil {
+		s.l.Error("failed to record activity", "kind", activity.KindMemberJoined, "error", err)
+	}
+}
+
+func generateInviteCode() (string, error) {
+	b := make([]byte, inviteCodeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
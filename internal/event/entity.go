@@ -0,0 +1,70 @@
+package event
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Event struct {
+	ID        uuid.UUID `db:"id"`
+	ClubID    uuid.UUID `db:"club_id"`
+	Name      string    `db:"name"`
+	StartsAt  time.Time `db:"starts_at"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type Attendance struct {
+	ID          uuid.UUID `db:"id"`
+	EventID     uuid.UUID `db:"event_id"`
+	MemberID    uuid.UUID `db:"member_id"`
+	CheckedInAt time.Time `db:"checked_in_at"`
+}
+
+type AttendanceStatistic struct {
+	ID            uuid.UUID  `db:"id"`
+	MemberID      uuid.UUID  `db:"member_id"`
+	Sessions      int        `db:"sessions"`
+	Streak        int        `db:"streak"`
+	LongestStreak int        `db:"longest_streak"`
+	LastEventID   *uuid.UUID `db:"last_event_id"`
+	CreatedAt     time.Time  `db:"created_at"`
+	UpdatedAt     time.Time  `db:"updated_at"`
+}
+
+// AttendedEvent is one of the club's events and whether a member attended it.
+type AttendedEvent struct {
+	EventID  uuid.UUID `db:"event_id"`
+	Attended bool      `db:"attended"`
+}
+
+// NewAttendanceStatistic derives a member's statistic from the club's events
+// in the order they start. The streak is the run of consecutive events ending
+// at the latest one attended, so late check-ins still count towards it.
+func NewAttendanceStatistic(memberID uuid.UUID, history []AttendedEvent) *AttendanceStatistic {
+	stat := &AttendanceStatistic{
+		MemberID: memberID,
+	}
+
+	run := 0
+	for _, e := range history {
+		if !e.Attended {
+			run = 0
+			continue
+		}
+
+		run++
+		stat.Sessions++
+		stat.Streak = run
+		stat.LongestStreak = max(stat.LongestStreak, run)
+		stat.LastEventID = &e.EventID
+	}
+
+	return stat
+}
+
+// Player is a checked in member and their rating in the game being played.
+type Player struct {
+	MemberID uuid.UUID
+	Rating   float64
+}
@@ -0,0 +1,224 @@
+package event
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrNotFound         = fmt.Errorf("not found")
+	ErrAlreadyCheckedIn = fmt.Errorf("already checked in")
+)
+
+type Repository interface {
+	GetEvent(ctx context.Context, id uuid.UUID) (*Event, error)
+	GetEvents(ctx context.Context, clubID uuid.UUID) ([]Event, error)
+	CreateEvent(ctx context.Context, event *Event) (uuid.UUID, error)
+	// DeleteEvent removes the event and rebuilds its attendees' statistics.
+	DeleteEvent(ctx context.Context, id uuid.UUID) error
+	// CheckIn records the attendance and recomputes the member's attendance
+	// statistic in one transaction.
+	CheckIn(ctx context.Context, attendance *Attendance) error
+	GetAttendances(ctx context.Context, eventID uuid.UUID) ([]Attendance, error)
+	IsClubMember(ctx context.Context, clubID, memberID uuid.UUID) (bool, error)
+	GetAttendanceStatistic(ctx context.Context, memberID uuid.UUID) (*AttendanceStatistic, error)
+}
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (r *repository) GetEvent(ctx context.Context, id uuid.UUID) (*Event, error) {
+	var event Event
+
+	err := r.db.GetContext(ctx, &event, "SELECT * FROM events WHERE id = $1", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+func (r *repository) GetEvents(ctx context.Context, clubID uuid.UUID) ([]Event, error) {
+	var events []Event
+
+	err := r.db.SelectContext(ctx, &events,
+		"SELECT * FROM events WHERE club_id = $1 ORDER BY starts_at DESC",
+		clubID)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (r *repository) CreateEvent(ctx context.Context, event *Event) (uuid.UUID, error) {
+	var id uuid.UUID
+
+	err := r.db.QueryRowContext(ctx,
+		"INSERT INTO events (club_id, name, starts_at) VALUES ($1, $2, $3) RETURNING id",
+		event.ClubID, event.Name, event.StartsAt).Scan(&id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+func (r *repository) DeleteEvent(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locked like check-ins, so the attendees' statistics are rebuilt from
+	// the attendances left once the event is gone
+	var attendees []uuid.UUID
+	err = tx.SelectContext(ctx, &attendees, `
+		SELECT id FROM members
+		WHERE id IN (SELECT member_id FROM event_attendances WHERE event_id = $1)
+		ORDER BY id
+		FOR UPDATE`,
+		id)
+	if err != nil {
+		return fmt.Errorf("failed to lock attendees: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM events WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+
+	for _, memberID := range attendees {
+		if err := RebuildAttendanceStatistic(ctx, tx, memberID); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) CheckIn(ctx context.Context, attendance *Attendance) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Check-ins of the same member wait for each other, so every statistic
+	// is computed from all of their attendances
+	_, err = tx.ExecContext(ctx, "SELECT 1 FROM members WHERE id = $1 FOR UPDATE", attendance.MemberID)
+	if err != nil {
+		return fmt.Errorf("failed to lock member: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO event_attendances (event_id, member_id) VALUES ($1, $2) ON CONFLICT (event_id, member_id) DO NOTHING",
+		attendance.EventID, attendance.MemberID)
+	if err != nil {
+		return fmt.Errorf("failed to create attendance: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return ErrAlreadyCheckedIn
+	}
+
+	if err := RebuildAttendanceStatistic(ctx, tx, attendance.MemberID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *repository) GetAttendances(ctx context.Context, eventID uuid.UUID) ([]Attendance, error) {
+	var attendances []Attendance
+
+	err := r.db.SelectContext(ctx, &attendances,
+		"SELECT * FROM event_attendances WHERE event_id = $1 ORDER BY checked_in_at",
+		eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	return attendances, nil
+}
+
+func (r *repository) GetAttendanceStatistic(ctx context.Context, memberID uuid.UUID) (*AttendanceStatistic, error) {
+	var stat AttendanceStatistic
+
+	err := r.db.GetContext(ctx, &stat, "SELECT * FROM attendance_statistics WHERE member_id = $1", memberID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &stat, nil
+}
+
+func (r *repository) IsClubMember(ctx context.Context, clubID, memberID uuid.UUID) (bool, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count,
+		"SELECT COUNT(*) FROM members WHERE club_id = $1 AND id = $2",
+		clubID, memberID)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RebuildAttendanceStatistic derives the member's attendance statistic from
+// their attendances using db, which may be a transaction so the statistic
+// changes together with the attendances.
+func RebuildAttendanceStatistic(ctx context.Context, db sqlx.ExtContext, memberID uuid.UUID) error {
+	var history []AttendedEvent
+	err := sqlx.SelectContext(ctx, db, &history, `
+		SELECT e.id AS event_id, a.id IS NOT NULL AS attended
+		FROM events e
+		LEFT JOIN event_attendances a ON a.event_id = e.id AND a.member_id = $1
+		WHERE e.club_id = (SELECT club_id FROM members WHERE id = $1)
+		ORDER BY e.starts_at, e.id`,
+		memberID)
+	if err != nil {
+		return fmt.Errorf("failed to get attendance history: %w", err)
+	}
+
+	stat := NewAttendanceStatistic(memberID, history)
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO attendance_statistics (member_id, sessions, streak, longest_streak, last_event_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (member_id) DO UPDATE SET
+			sessions = EXCLUDED.sessions,
+			streak = EXCLUDED.streak,
+			longest_streak = EXCLUDED.longest_streak,
+			last_event_id = EXCLUDED.last_event_id`,
+		stat.MemberID, stat.Sessions, stat.Streak, stat.LongestStreak, stat.LastEventID)
+	if err != nil {
+		return fmt.Errorf("failed to upsert attendance statistic: %w", err)
+	}
+
+	return nil
+}
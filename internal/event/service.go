@@ -0,0 +1,206 @@
+package event
+
+import (
+	"context"
+	"core/internal/rating"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	GetEvent(ctx context.Context, id uuid.UUID) (*Event, error)
+	GetEvents(ctx context.Context, clubID uuid.UUID) ([]Event, error)
+	CreateEvent(ctx context.Context, clubID uuid.UUID, name string, startsAt time.Time) (uuid.UUID, error)
+	DeleteEvent(ctx context.Context, id uuid.UUID) error
+	CheckIn(ctx context.Context, eventID, memberID uuid.UUID) error
+	GetAttendees(ctx context.Context, eventID uuid.UUID) ([]Attendance, error)
+	GetAttendanceStatistic(ctx context.Context, memberID uuid.UUID) (*AttendanceStatistic, error)
+	// GetPlayers lists the event's attendees by their rating in the game, best
+	// first, to pick from when entering a match.
+	GetPlayers(ctx context.Context, eventID, gameID uuid.UUID) ([]Player, error)
+	// BalanceTeams splits the event's attendees into teams of even size with
+	// ratings in the game as close as possible.
+	BalanceTeams(ctx context.Context, eventID, gameID uuid.UUID, teams int) ([][]Player, error)
+}
+
+var ErrNotEnoughPlayers = fmt.Errorf("not enough players checked in")
+
+type service struct {
+	repo          Repository
+	ratingService rating.Service
+}
+
+func NewService(repo Repository, ratingService rating.Service) Service {
+	return &service{
+		repo:          repo,
+		ratingService: ratingService,
+	}
+}
+
+func (s *service) GetEvent(ctx context.Context, id uuid.UUID) (*Event, error) {
+	event, err := s.repo.GetEvent(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	}
+
+	return event, nil
+}
+
+func (s *service) GetEvents(ctx context.Context, clubID uuid.UUID) ([]Event, error) {
+	events, err := s.repo.GetEvents(ctx, clubID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (s *service) CreateEvent(ctx context.Context, clubID uuid.UUID, name string, startsAt time.Time) (uuid.UUID, error) {
+	// Validate event name
+	if len(name) < 1 || len(name) > 50 {
+		return uuid.Nil, fmt.Errorf("event name must be between 1 and 50 characters")
+	}
+
+	event := &Event{
+		ClubID:   clubID,
+		Name:     name,
+		StartsAt: startsAt,
+	}
+
+	id, err := s.repo.CreateEvent(ctx, event)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create event: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *service) DeleteEvent(ctx context.Context, id uuid.UUID) error {
+	if err := s.repo.DeleteEvent(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) CheckIn(ctx context.Context, eventID, memberID uuid.UUID) error {
+	event, err := s.repo.GetEvent(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to get event: %w", err)
+	}
+
+	// Validate the member belongs to the club hosting the event
+	isMember, err := s.repo.IsClubMember(ctx, event.ClubID, memberID)
+	if err != nil {
+		return fmt.Errorf("failed to check club membership: %w", err)
+	}
+	if !isMember {
+		return fmt.Errorf("member is not part of the club hosting this event")
+	}
+
+	attendance := &Attendance{
+		EventID:  eventID,
+		MemberID: memberID,
+	}
+	if err := s.repo.CheckIn(ctx, attendance); err != nil {
+		return fmt.Errorf("failed to check in: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) GetAttendees(ctx context.Context, eventID uuid.UUID) ([]Attendance, error) {
+	attendances, err := s.repo.GetAttendances(ctx, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attendances: %w", err)
+	}
+
+	return attendances, nil
+}
+
+func (s *service) GetAttendanceStatistic(ctx context.Context, memberID uuid.UUID) (*AttendanceStatistic, error) {
+	stat, err := s.repo.GetAttendanceStatistic(ctx, memberID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return &AttendanceStatistic{MemberID: memberID}, nil
+		}
+		return nil, fmt.Errorf("failed to get attendance statistic: %w", err)
+	}
+
+	return stat, nil
+}
+
+func (s *service) GetPlayers(ctx context.Context, eventID, gameID uuid.UUID) ([]Player, error) {
+	attendances, err := s.repo.GetAttendances(ctx, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attendances: %w", err)
+	}
+
+	memberIDs := make([]uuid.UUID, len(attendances))
+	for i, a := range attendances {
+		memberIDs[i] = a.MemberID
+	}
+
+	ratings, err := s.ratingService.GetMemberRatings(ctx, memberIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ratings: %w", err)
+	}
+
+	ratingByMember := make(map[uuid.UUID]float64, len(ratings))
+	for _, r := range ratings {
+		if r.GameID == gameID {
+			ratingByMember[r.MemberID] = r.Ordinal()
+		}
+	}
+
+	players := make([]Player, len(memberIDs))
+	for i, memberID := range memberIDs {
+		r, ok := ratingByMember[memberID]
+		if !ok {
+			// Members who haven't played the game yet
+			r = rating.Initial(memberID, gameID).Ordinal()
+		}
+		players[i] = Player{
+			MemberID: memberID,
+			Rating:   r,
+		}
+	}
+
+	sort.SliceStable(players, func(i, j int) bool {
+		return players[i].Rating > players[j].Rating
+	})
+
+	return players, nil
+}
+
+func (s *service) BalanceTeams(ctx context.Context, eventID, gameID uuid.UUID, teams int) ([][]Player, error) {
+	players, err := s.GetPlayers(ctx, eventID, gameID)
+	if err != nil {
+		return nil, err
+	}
+	if teams < 2 || len(players) < teams {
+		return nil, ErrNotEnoughPlayers
+	}
+
+	// Going from the best player down, each joins the smallest team, and of
+	// those the one with the lowest total rating
+	balanced := make([][]Player, teams)
+	totals := make([]float64, teams)
+	for _, p := range players {
+		pick := 0
+		for i := 1; i < teams; i++ {
+			if len(balanced[i]) < len(balanced[pick]) ||
+				(len(balanced[i]) == len(balanced[pick]) && totals[i] < totals[pick]) {
+				pick = i
+			}
+		}
+		balanced[pick] = append(balanced[pick], p)
+		totals[pick] += p.Rating
+	}
+
+	return balanced, nil
+}
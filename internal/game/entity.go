@@ -1,6 +1,10 @@
 package game
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Mode int
 
@@ -25,9 +29,11 @@ func (m Mode) String() string {
 }
 
 type Game struct {
-	ID     uuid.UUID `db:"id"`
-	ClubID uuid.UUID `db:"club_id"`
-	Name   string    `db:"name"`
+	ID        uuid.UUID  `db:"id"`
+	ClubID    uuid.UUID  `db:"club_id"`
+	Name      string     `db:"name"`
+	CreatedAt time.Time  `db:"created_at"`
+	DeletedAt *time.Time `db:"deleted_at"`
 }
 
 type Gamemode struct {
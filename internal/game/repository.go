@@ -2,17 +2,32 @@ package game
 
 import (
 	"context"
+	"core/internal/audit"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
+var ErrNotFound = fmt.Errorf("not found")
+
 type Repository interface {
 	GetGame(ctx context.Context, id uuid.UUID) (*Game, error)
 	GetGames(ctx context.Context, ids []uuid.UUID) ([]Game, error)
-	CreateGame(ctx context.Context, game *Game) (uuid.UUID, error)
-	UpdateGame(ctx context.Context, game *Game) error
-	DeleteGame(ctx context.Context, id uuid.UUID) error
+	// CreateGame, UpdateGame, DeleteGame and RestoreGame write the audit entry
+	// along with the change, CreateGame makes the new game its target.
+	CreateGame(ctx context.Context, game *Game, entry *audit.Entry) (uuid.UUID, error)
+	UpdateGame(ctx context.Context, game *Game, entry *audit.Entry) error
+	// DeleteGame marks the game deleted, hiding it and its matches until it
+	// is restored or purged.
+	DeleteGame(ctx context.Context, id uuid.UUID, entry *audit.Entry) error
+	GetDeletedGame(ctx context.Context, id uuid.UUID) (*Game, error)
+	RestoreGame(ctx context.Context, id uuid.UUID, entry *audit.Entry) error
+	// PurgeDeletedGames removes games deleted before the given time for good.
+	PurgeDeletedGames(ctx context.Context, deletedBefore time.Time) (int64, error)
 	GetGameModes(ctx context.Context, gameID uuid.UUID) ([]Gamemode, error)
 	AddGameMode(ctx context.Context, gameID uuid.UUID, mode Mode) error
 	RemoveGameMode(ctx context.Context, gameID uuid.UUID, mode Mode) error
@@ -28,20 +43,23 @@ func NewRepository(db *sqlx.DB) *repository {
 }
 
 func (r *repository) GetGame(ctx context.Context, id uuid.UUID) (*Game, error) {
-	var game *Game
+	var game Game
 
-	err := r.db.GetContext(ctx, game, "SELECT * FROM games WHERE id = $1", id)
+	err := r.db.GetContext(ctx, &game, "SELECT * FROM games WHERE id = $1 AND deleted_at IS NULL", id)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 
-	return game, nil
+	return &game, nil
 }
 
 func (r *repository) GetGames(ctx context.Context, ids []uuid.UUID) ([]Game, error) {
 	var games []Game
 
-	query, args, err := sqlx.In("SELECT * FROM games WHERE id IN (?)", ids)
+	query, args, err := sqlx.In("SELECT * FROM games WHERE id IN (?) AND deleted_at IS NULL", ids)
 	if err != nil {
 		return nil, err
 	}
@@ -55,12 +73,20 @@ func (r *repository) GetGames(ctx context.Context, ids []uuid.UUID) ([]Game, err
 	return games, nil
 }
 
-func (r *repository) CreateGame(ctx context.Context, game *Game) (uuid.UUID, error) {
+func (r *repository) CreateGame(ctx context.Context, game *Game, entry *audit.Entry) (uuid.UUID, error) {
 	var id uuid.UUID
 
-	err := r.db.QueryRowContext(ctx,
-		"INSERT INTO games (club_id, name) VALUES ($1, $2) RETURNING id",
-		game.ClubID, game.Name).Scan(&id)
+	err := audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			"INSERT INTO games (club_id, name) VALUES ($1, $2) RETURNING id",
+			game.ClubID, game.Name).Scan(&id)
+		if err != nil {
+			return err
+		}
+		entry.TargetID = &id
+
+		return nil
+	})
 	if err != nil {
 		return uuid.Nil, err
 	}
@@ -68,25 +94,79 @@ func (r *repository) CreateGame(ctx context.Context, game *Game) (uuid.UUID, err
 	return id, nil
 }
 
-func (r *repository) UpdateGame(ctx context.Context, game *Game) error {
-	_, err := r.db.ExecContext(ctx,
-		"UPDATE games SET club_id = $1, name = $2 WHERE id = $3",
-		game.ClubID, game.Name, game.ID,
-	)
-	if err != nil {
+func (r *repository) UpdateGame(ctx context.Context, game *Game, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			"UPDATE games SET club_id = $1, name = $2 WHERE id = $3 AND deleted_at IS NULL",
+			game.ClubID, game.Name, game.ID,
+		)
 		return err
+	})
+}
+
+func (r *repository) DeleteGame(ctx context.Context, id uuid.UUID, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			"UPDATE games SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL",
+			id)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+func (r *repository) GetDeletedGame(ctx context.Context, id uuid.UUID) (*Game, error) {
+	var game Game
+
+	err := r.db.GetContext(ctx, &game, "SELECT * FROM games WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
 	}
 
-	return nil
+	return &game, nil
 }
 
-func (r *repository) DeleteGame(ctx context.Context, id uuid.UUID) error {
-	_, err := r.db.ExecContext(ctx, "DELETE FROM games WHERE id = $1", id)
+func (r *repository) RestoreGame(ctx context.Context, id uuid.UUID, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			"UPDATE games SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL",
+			id)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+func (r *repository) PurgeDeletedGames(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM games WHERE deleted_at < $1", deletedBefore)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return res.RowsAffected()
 }
 
 func (r *repository) GetGameModes(ctx context.Context, gameID uuid.UUID) ([]Gamemode, error) {
@@ -121,7 +201,7 @@ func (r *repository) RemoveGameMode(ctx context.Context, gameID uuid.UUID, mode
 func (r *repository) IsGameNameUnique(ctx context.Context, clubID uuid.UUID, name string, excludeGameID uuid.UUID) (bool, error) {
 	var count int
 	err := r.db.GetContext(ctx, &count,
-		"SELECT COUNT(*) FROM games WHERE club_id = $1 AND name = $2 AND id != $3",
+		"SELECT COUNT(*) FROM games WHERE club_id = $1 AND name = $2 AND id != $3 AND deleted_at IS NULL",
 		clubID, name, excludeGameID)
 	if err != nil {
 		return false, err
@@ -2,18 +2,34 @@ package game
 
 import (
 	"context"
+	"core/internal/audit"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+var ErrDuplicateName = fmt.Errorf("game name already exists in this club")
+
+// defaultRetentionPeriod is how long deleted games can be restored
+const defaultRetentionPeriod = 30 * 24 * time.Hour
+
 type Service interface {
 	// Games
 	GetGame(ctx context.Context, id uuid.UUID) (*Game, error)
 	GetGames(ctx context.Context, ids []uuid.UUID) ([]Game, error)
-	CreateGame(ctx context.Context, clubID uuid.UUID, name string) (uuid.UUID, error)
-	UpdateGame(ctx context.Context, game *Game) error
-	DeleteGame(ctx context.Context, id uuid.UUID) error
+	CreateGame(ctx context.Context, actorID, clubID uuid.UUID, name string) (uuid.UUID, error)
+	UpdateGame(ctx context.Context, actorID uuid.UUID, game *Game) error
+	// DeleteGame hides the game and its matches, returning when it will be
+	// purged unless restored before.
+	DeleteGame(ctx context.Context, actorID, id uuid.UUID) (purgeAt time.Time, err error)
+	// RestoreGame brings back a deleted game of the club, unless a game of the
+	// same name was created in the meantime.
+	RestoreGame(ctx context.Context, actorID, clubID, id uuid.UUID) (*Game, error)
+	// PurgeDeletedGames removes games whose retention period is over, along
+	// with their matches, ratings and statistics.
+	PurgeDeletedGames(ctx context.Context) (int64, error)
 
 	// Game modes
 	GetGameModes(ctx context.Context, gameID uuid.UUID) ([]Gamemode, error)
@@ -22,11 +38,16 @@ type Service interface {
 }
 
 type service struct {
-	repo Repository
+	repo      Repository
+	retention time.Duration
 }
 
-func NewService(repo Repository) *service {
-	return &service{repo}
+func NewService(repo Repository, retention time.Duration) *service {
+	if retention <= 0 {
+		retention = defaultRetentionPeriod
+	}
+
+	return &service{repo, retention}
 }
 
 func (s *service) GetGame(ctx context.Context, id uuid.UUID) (*Game, error) {
@@ -37,7 +58,7 @@ func (s *service) GetGames(ctx context.Context, ids []uuid.UUID) ([]Game, error)
 	return s.repo.GetGames(ctx, ids)
 }
 
-func (s *service) CreateGame(ctx context.Context, clubID uuid.UUID, name string) (uuid.UUID, error) {
+func (s *service) CreateGame(ctx context.Context, actorID, clubID uuid.UUID, name string) (uuid.UUID, error) {
 	// Validate game name
 	if len(name) < 1 || len(name) > 50 {
 		return uuid.Nil, fmt.Errorf("game name must be between 1 and 50 characters")
@@ -49,7 +70,7 @@ func (s *service) CreateGame(ctx context.Context, clubID uuid.UUID, name string)
 		return uuid.Nil, fmt.Errorf("failed to check game name uniqueness: %w", err)
 	}
 	if !unique {
-		return uuid.Nil, fmt.Errorf("game name already exists in this club")
+		return uuid.Nil, ErrDuplicateName
 	}
 
 	game := &Game{
@@ -57,10 +78,18 @@ func (s *service) CreateGame(ctx context.Context, clubID uuid.UUID, name string)
 		Name:   name,
 	}
 
-	return s.repo.CreateGame(ctx, game)
+	entry := audit.NewEntry(clubID, actorID, audit.ActionGameCreated, uuid.Nil)
+	entry.After = audit.Snapshot{"name": name}
+
+	id, err := s.repo.CreateGame(ctx, game, entry)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
 }
 
-func (s *service) UpdateGame(ctx context.Context, game *Game) error {
+func (s *service) UpdateGame(ctx context.Context, actorID uuid.UUID, game *Game) error {
 	// Validate game name
 	if len(game.Name) < 1 || len(game.Name) > 50 {
 		return fmt.Errorf("game name must be between 1 and 50 characters")
@@ -72,14 +101,82 @@ func (s *service) UpdateGame(ctx context.Context, game *Game) error {
 		return fmt.Errorf("failed to check game name uniqueness: %w", err)
 	}
 	if !unique {
-		return fmt.Errorf("game name already exists in this club")
+		return ErrDuplicateName
+	}
+
+	before, err := s.repo.GetGame(ctx, game.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get game: %w", err)
+	}
+
+	entry := audit.NewEntry(game.ClubID, actorID, audit.ActionGameUpdated, game.ID)
+	entry.Before = audit.Snapshot{"name": before.Name}
+	entry.After = audit.Snapshot{"name": game.Name}
+
+	return s.repo.UpdateGame(ctx, game, entry)
+}
+
+func (s *service) DeleteGame(ctx context.Context, actorID, id uuid.UUID) (time.Time, error) {
+	game, err := s.repo.GetGame(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return time.Time{}, ErrNotFound
+		}
+		return time.Time{}, fmt.Errorf("failed to get game: %w", err)
+	}
+
+	entry := audit.NewEntry(game.ClubID, actorID, audit.ActionGameDeleted, id)
+	entry.Before = audit.Snapshot{"name": game.Name}
+
+	if err := s.repo.DeleteGame(ctx, id, entry); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return time.Time{}, ErrNotFound
+		}
+		return time.Time{}, fmt.Errorf("failed to delete game: %w", err)
+	}
+
+	return time.Now().Add(s.retention), nil
+}
+
+func (s *service) RestoreGame(ctx context.Context, actorID, clubID, id uuid.UUID) (*Game, error) {
+	game, err := s.repo.GetDeletedGame(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get deleted game: %w", err)
+	}
+	// Past its retention the game is only waiting for the next purge
+	if game.ClubID != clubID || time.Since(*game.DeletedAt) > s.retention {
+		return nil, ErrNotFound
+	}
+
+	unique, err := s.repo.IsGameNameUnique(ctx, game.ClubID, game.Name, game.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check game name uniqueness: %w", err)
+	}
+	if !unique {
+		return nil, ErrDuplicateName
 	}
 
-	return s.repo.UpdateGame(ctx, game)
+	entry := audit.NewEntry(clubID, actorID, audit.ActionGameRestored, id)
+	entry.After = audit.Snapshot{"name": game.Name}
+
+	if err := s.repo.RestoreGame(ctx, id, entry); err != nil {
+		return nil, fmt.Errorf("failed to restore game: %w", err)
+	}
+	game.DeletedAt = nil
+
+	return game, nil
 }
 
-func (s *service) DeleteGame(ctx context.Context, id uuid.UUID) error {
-	return s.repo.DeleteGame(ctx, id)
+func (s *service) PurgeDeletedGames(ctx context.Context) (int64, error) {
+	n, err := s.repo.PurgeDeletedGames(ctx, time.Now().Add(-s.retention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted games: %w", err)
+	}
+
+	return n, nil
 }
 
 func (s *service) GetGameModes(ctx context.Context, gameID uuid.UUID) ([]Gamemode, error) {
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type logMailer struct {
+	l   *slog.Logger
+	dir string
+}
+
+// NewLogMailer logs emails instead of sending them, for development and tests.
+// If dir is set, each email is also written to a file there.
+func NewLogMailer(l *slog.Logger, dir string) Mailer {
+	return &logMailer{
+		l:   l,
+		dir: dir,
+	}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	m.l.Info("Mail", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+
+	if m.dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%d.txt", time.Now().UnixNano())
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	if err := os.WriteFile(filepath.Join(m.dir, name), []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+
+	return nil
+}
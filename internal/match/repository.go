@@ -2,8 +2,10 @@ package match
 
 import (
 	"context"
+	"core/internal/audit"
 	"core/internal/member"
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
@@ -13,13 +15,18 @@ import (
 var ErrNotFound = fmt.Errorf("not found")
 
 type Repository interface {
-	CreateMatch(ctx context.Context, m *Match) (uuid.UUID, error)
+	// CreateMatch writes the audit entry along with the match, which becomes
+	// the entry's target.
+	CreateMatch(ctx context.Context, m *Match, entry *audit.Entry) (uuid.UUID, error)
+	GetMatch(ctx context.Context, id uuid.UUID) (*Match, error)
 	GetMatches(ctx context.Context, clubID uuid.UUID) ([]Match, error)
 	GetMatchesByGame(ctx context.Context, clubID, gameID uuid.UUID) ([]Match, error)
+	// GetMatchesByMembers returns the matches any of the members played in.
+	GetMatchesByMembers(ctx context.Context, memberIDs []uuid.UUID) ([]Match, error)
 	GetTeam(ctx context.Context, teamID uuid.UUID) (*Team, error)
 	CreateTeam(ctx context.Context, clubID uuid.UUID, memberIDs []uuid.UUID) (uuid.UUID, error)
 	TeamOfMembersExists(ctx context.Context, clubID uuid.UUID, memberIDs []uuid.UUID) (bool, uuid.UUID, error)
-	IsClubMember(ctx context.Context, clubID, userID uuid.UUID) (bool, error)
+	IsClubMember(ctx context.Context, clubID, memberID uuid.UUID) (bool, error)
 }
 
 type repository struct {
@@ -32,7 +39,7 @@ func NewRepository(db *sqlx.DB) Repository {
 	}
 }
 
-func (r *repository) CreateMatch(ctx context.Context, m *Match) (uuid.UUID, error) {
+func (r *repository) CreateMatch(ctx context.Context, m *Match, entry *audit.Entry) (uuid.UUID, error) {
 	var matchID uuid.UUID
 
 	// Start a transaction
@@ -60,6 +67,11 @@ func (r *repository) CreateMatch(ctx context.Context, m *Match) (uuid.UUID, erro
 		}
 	}
 
+	entry.TargetID = &matchID
+	if err := audit.Insert(ctx, tx, entry); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
 	// Commit the transaction
 	if err = tx.Commit(); err != nil {
 		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
@@ -68,9 +80,23 @@ func (r *repository) CreateMatch(ctx context.Context, m *Match) (uuid.UUID, erro
 	return matchID, nil
 }
 
-func (r *repository) GetMatches(ctx context.Context, clubID uuid.UUID) ([]Match, error) {
-	matchesMap := make(map[uuid.UUID]*Match)
+func (r *repository) GetMatch(ctx context.Context, id uuid.UUID) (*Match, error) {
+	var m Match
+
+	err := r.db.GetContext(ctx, &m,
+		"SELECT id, club_id, game_id, mode AS gamemode, ranked, sets, created_at FROM matches WHERE id = $1 AND game_id IN (SELECT id FROM games WHERE deleted_at IS NULL)",
+		id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
 
+	return &m, nil
+}
+
+func (r *repository) GetMatches(ctx context.Context, clubID uuid.UUID) ([]Match, error) {
 	rows, err := r.db.QueryxContext(ctx, `
 			SELECT
 					m.id AS match_id,
@@ -85,13 +111,15 @@ func (r *repository) GetMatches(ctx context.Context, clubID uuid.UUID) ([]Match,
 					mem.id AS member_id,
 					mem.club_id AS member_club_id,
 					mem.user_id AS member_user_id,
-					mem.role AS member_role
+					mem.role AS member_role,
+					mem.nickname AS member_nickname
 			FROM matches m
 			LEFT JOIN match_teams mt ON m.id = mt.match_id
 			LEFT JOIN teams t ON mt.team_id = t.id
 			LEFT JOIN team_members tm ON t.id = tm.team_id
 			LEFT JOIN members mem ON tm.member_id = mem.id
 			WHERE m.club_id = $1
+			AND m.game_id IN (SELECT id FROM games WHERE deleted_at IS NULL)
 			ORDER BY m.id, t.id, mem.id; -- Crucial for correct grouping
 	`, clubID)
 	if err != nil {
@@ -99,57 +127,10 @@ func (r *repository) GetMatches(ctx context.Context, clubID uuid.UUID) ([]Match,
 	}
 	defer rows.Close()
 
-	for rows.Next() {
-		var m Match
-		var t Team
-		var mem member.Member
-
-		err = rows.Scan(
-			&m.ID, &m.ClubID, &m.GameID, &m.Gamemode, &m.Ranked, &m.Sets, &m.CreatedAt,
-			&t.ID, &t.ClubID,
-			&mem.ID, &mem.ClubID, &mem.UserID, &mem.Role,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		match, ok := matchesMap[m.ID]
-		if !ok {
-			match = &m
-			match.Teams = make([]Team, 0)
-			matchesMap[m.ID] = match
-		}
-
-		if t.ID != uuid.Nil {
-			teamExists := false
-			for i := range match.Teams {
-				if match.Teams[i].ID == t.ID {
-					match.Teams[i].Members = append(match.Teams[i].Members, mem)
-					teamExists = true
-					break
-				}
-			}
-			if !teamExists {
-				t.Members = []member.Member{mem}
-				match.Teams = append(match.Teams, t)
-			}
-		}
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	matches := make([]Match, 0, len(matchesMap))
-	for _, matchPtr := range matchesMap {
-		matches = append(matches, *matchPtr)
-	}
-
-	return matches, nil
+	return collectMatches(rows)
 }
 
 func (r *repository) GetMatchesByGame(ctx context.Context, clubID uuid.UUID, gameID uuid.UUID) ([]Match, error) {
-	matchesMap := make(map[uuid.UUID]*Match)
-
 	rows, err := r.db.QueryxContext(ctx, `
         SELECT
             m.id AS match_id,
@@ -164,13 +145,15 @@ func (r *repository) GetMatchesByGame(ctx context.Context, clubID uuid.UUID, gam
             mem.id AS member_id,
             mem.club_id AS member_club_id,
             mem.user_id AS member_user_id,
-            mem.role AS member_role
+            mem.role AS member_role,
+            mem.nickname AS member_nickname
         FROM matches m
         LEFT JOIN match_teams mt ON m.id = mt.match_id
         LEFT JOIN teams t ON mt.team_id = t.id
         LEFT JOIN team_members tm ON t.id = tm.team_id
         LEFT JOIN members mem ON tm.member_id = mem.id
         WHERE m.club_id = $1 AND m.game_id = $2
+        AND m.game_id IN (SELECT id FROM games WHERE deleted_at IS NULL)
         ORDER BY m.id, t.id, mem.id;
     `, clubID, gameID)
 	if err != nil {
@@ -178,15 +161,67 @@ func (r *repository) GetMatchesByGame(ctx context.Context, clubID uuid.UUID, gam
 	}
 	defer rows.Close()
 
+	return collectMatches(rows)
+}
+
+func (r *repository) GetMatchesByMembers(ctx context.Context, memberIDs []uuid.UUID) ([]Match, error) {
+	query, args, err := sqlx.In(`
+        SELECT
+            m.id AS match_id,
+            m.club_id AS match_club_id,
+            m.game_id AS match_game_id,
+            m.mode AS match_mode,
+            m.ranked AS match_ranked,
+            m.sets AS match_sets,
+            m.created_at AS match_created_at,
+            t.id AS team_id,
+            t.club_id AS team_club_id,
+            mem.id AS member_id,
+            mem.club_id AS member_club_id,
+            mem.user_id AS member_user_id,
+            mem.role AS member_role,
+            mem.nickname AS member_nickname
+        FROM matches m
+        LEFT JOIN match_teams mt ON m.id = mt.match_id
+        LEFT JOIN teams t ON mt.team_id = t.id
+        LEFT JOIN team_members tm ON t.id = tm.team_id
+        LEFT JOIN members mem ON tm.member_id = mem.id
+        WHERE m.id IN (
+            SELECT pmt.match_id
+            FROM match_teams pmt
+            JOIN team_members ptm ON ptm.team_id = pmt.team_id
+            WHERE ptm.member_id IN (?)
+        )
+        AND m.game_id IN (SELECT id FROM games WHERE deleted_at IS NULL)
+        ORDER BY m.id, t.id, mem.id;
+    `, memberIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryxContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return collectMatches(rows)
+}
+
+// collectMatches groups rows of matches joined with their teams and members,
+// ordered by match, team and member.
+func collectMatches(rows *sqlx.Rows) ([]Match, error) {
+	matchesMap := make(map[uuid.UUID]*Match)
+
 	for rows.Next() {
 		var m Match
 		var t Team
 		var mem member.Member
 
-		err = rows.Scan(
+		err := rows.Scan(
 			&m.ID, &m.ClubID, &m.GameID, &m.Gamemode, &m.Ranked, &m.Sets, &m.CreatedAt,
 			&t.ID, &t.ClubID,
-			&mem.ID, &mem.ClubID, &mem.UserID, &mem.Role,
+			&mem.ID, &mem.ClubID, &mem.UserID, &mem.Role, &mem.Nickname,
 		)
 		if err != nil {
 			return nil, err
@@ -240,11 +275,12 @@ func (r *repository) GetTeam(ctx context.Context, teamID uuid.UUID) (*Team, erro
 	}
 
 	const membersQuery = `
-        SELECT *
+        SELECT m.*
         FROM members m
         JOIN team_members tm ON tm.member_id = m.id
+        LEFT JOIN users u ON u.id = m.user_id
         WHERE tm.team_id = $1
-        ORDER BY m.display_name`
+        ORDER BY COALESCE(m.nickname, NULLIF(u.display_name, ''), u.name)`
 
 	var members []member.Member
 	err = r.db.SelectContext(ctx, &members, membersQuery, teamID)
@@ -326,11 +362,11 @@ func (r *repository) TeamOfMembersExists(ctx context.Context, clubID uuid.UUID,
 	return true, teamID, nil
 }
 
-func (r *repository) IsClubMember(ctx context.Context, clubID, userID uuid.UUID) (bool, error) {
+func (r *repository) IsClubMember(ctx context.Context, clubID, memberID uuid.UUID) (bool, error) {
 	var count int
 	err := r.db.GetContext(ctx, &count,
-		"SELECT COUNT(*) FROM members WHERE club_id = $1 AND user_id = $2",
-		clubID, userID)
+		"SELECT COUNT(*) FROM members WHERE club_id = $1 AND id = $2",
+		clubID, memberID)
 	if err != nil {
 		return false, err
 	}
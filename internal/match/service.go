@@ -2,33 +2,46 @@ package match
 
 import (
 	"context"
+	"core/internal/activity"
+	"core/internal/audit"
 	"core/internal/game"
 	"core/internal/rating"
 	"core/internal/statistic"
 	"fmt"
+	"log/slog"
+	"math"
 
 	"github.com/google/uuid"
 )
 
 type Service interface {
-	CreateMatch(ctx context.Context, clubID, gameID uuid.UUID, teams []Team, sets []string, mode game.Mode) (uuid.UUID, error)
+	// CreateMatch records a match on behalf of the acting user, uuid.Nil for
+	// API keys.
+	CreateMatch(ctx context.Context, actorID, clubID, gameID uuid.UUID, teams []Team, sets []string, mode game.Mode) (uuid.UUID, error)
+	GetMatch(ctx context.Context, id uuid.UUID) (*Match, error)
 	GetMatches(ctx context.Context, clubID uuid.UUID, gameID *uuid.UUID) ([]Match, error)
+	// GetMemberMatches returns the matches any of the members played in.
+	GetMemberMatches(ctx context.Context, memberIDs []uuid.UUID) ([]Match, error)
 	GetOrCreateTeams(ctx context.Context, clubID uuid.UUID, members [][]uuid.UUID) ([]Team, error)
 }
 
 type service struct {
+	l         *slog.Logger
 	repo      Repository
 	game      game.Service
 	rating    rating.Service
 	statistic statistic.Service
+	activity  activity.Service
 }
 
-func NewService(repo Repository, game game.Service, rating rating.Service, statistic statistic.Service) Service {
+func NewService(l *slog.Logger, repo Repository, game game.Service, rating rating.Service, statistic statistic.Service, activity activity.Service) Service {
 	return &service{
+		l:         l,
 		repo:      repo,
 		game:      game,
 		rating:    rating,
 		statistic: statistic,
+		activity:  activity,
 	}
 }
 
@@ -39,7 +52,7 @@ func (s *service) validateTeamMembers(ctx context.Context, clubID uuid.UUID, mem
 			return fmt.Errorf("failed to check club membership: %w", err)
 		}
 		if !isMember {
-			return fmt.Errorf("user %d is not a member of club %d", memberID, clubID)
+			return fmt.Errorf("member %s does not belong to club %s", memberID, clubID)
 		}
 	}
 	return nil
@@ -82,7 +95,7 @@ func (s *service) validateGameMode(ctx context.Context, gameID uuid.UUID, mode g
 	return nil
 }
 
-func (s *service) CreateMatch(ctx context.Context, clubID, gameID uuid.UUID, teams []Team, sets []string, mode game.Mode) (uuid.UUID, error) {
+func (s *service) CreateMatch(ctx context.Context, actorID, clubID, gameID uuid.UUID, teams []Team, sets []string, mode game.Mode) (uuid.UUID, error) {
 	// Validate game exists in club
 	g, err := s.game.GetGame(ctx, gameID)
 	if err != nil {
@@ -96,7 +109,7 @@ func (s *service) CreateMatch(ctx context.Context, clubID, gameID uuid.UUID, tea
 	for _, team := range teams {
 		memberIDs := make([]uuid.UUID, len(team.Members))
 		for i, member := range team.Members {
-			memberIDs[i] = member.UserID
+			memberIDs[i] = member.ID
 		}
 		if err := s.validateTeamMembers(ctx, clubID, memberIDs); err != nil {
 			return uuid.Nil, err
@@ -117,56 +130,106 @@ func (s *service) CreateMatch(ctx context.Context, clubID, gameID uuid.UUID, tea
 		Ranked:   true, // Set ranked to true by default
 	}
 
-	matchID, err := s.repo.CreateMatch(ctx, m)
+	teamMembers := make([][]uuid.UUID, len(teams))
+	for i, team := range teams {
+		teamMembers[i] = make([]uuid.UUID, len(team.Members))
+		for j, member := range team.Members {
+			teamMembers[i][j] = member.ID
+		}
+	}
+
+	entry := audit.NewEntry(clubID, actorID, audit.ActionMatchRecorded, uuid.Nil)
+	entry.After = audit.Snapshot{
+		"gameId": gameID,
+		"mode":   mode.String(),
+		"teams":  teamMembers,
+		"sets":   sets,
+	}
+
+	matchID, err := s.repo.CreateMatch(ctx, m, entry)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("failed to create match: %w", err)
 	}
 
+	var activities []*activity.Activity
+
 	// Update statistics for each player
 	for i, team := range teams {
 		for _, member := range team.Members {
 			// For now, we'll consider the first team as the winner
 			won := i == 0
 			drawn := false
-			if err := s.statistic.UpdateStatistics(ctx, member.ID, gameID, won, drawn); err != nil {
+			stats, newBest, err := s.statistic.UpdateStatistics(ctx, member.ID, gameID, won, drawn)
+			if err != nil {
 				// Log the error but don't fail the match creation
-				fmt.Printf("failed to update statistics for member %d: %v\n", member.ID, err)
+				s.l.Error("failed to update statistics", "member", member.ID, "error", err)
+				continue
+			}
+
+			if kind, ok := activity.StreakKind(stats.Streak, newBest); ok {
+				activities = append(activities, &activity.Activity{
+					ClubID:    clubID,
+					Kind:      kind,
+					MemberID:  &member.ID,
+					GameID:    &gameID,
+					SubjectID: &matchID,
+					Data:      activity.Data{"streak": stats.Streak},
+				})
 			}
 		}
 	}
 
 	// Update ratings if the match is ranked
+	swings := []activity.Data{}
 	if m.Ranked {
-		// Convert teams to member IDs for rating update
-		teamsByMemberIDs := make([][]uuid.UUID, len(teams))
-		for i, team := range teams {
-			memberIDs := make([]uuid.UUID, len(team.Members))
-			for j, member := range team.Members {
-				memberIDs[j] = member.ID
-			}
-			teamsByMemberIDs[i] = memberIDs
+		changes, err := s.rating.UpdateRatingsByRanks(ctx, teamMembers, rating.Ranks(len(teams)))
+		if err != nil {
+			// Log the error but don't fail the match creation
+			s.l.Error("failed to update ratings", "match", matchID, "error", err)
 		}
 
-		// For now, we'll consider the first team as the winner
-		// In a real implementation, you would determine the ranks based on the match results
-		ranks := make([]int, len(teams))
-		for i := range teams {
-			if i == 0 {
-				ranks[i] = 1 // First place
-			} else {
-				ranks[i] = 2 // Second place
+		for _, change := range changes {
+			if math.Abs(change.After-change.Before) >= activity.NotableRatingSwing {
+				swings = append(swings, activity.Data{
+					"memberId": change.MemberID,
+					"before":   change.Before,
+					"after":    change.After,
+				})
 			}
 		}
+	}
 
-		if err := s.rating.UpdateRatingsByRanks(ctx, teamsByMemberIDs, ranks); err != nil {
+	// The match leads its streaks in the feed
+	activities = append([]*activity.Activity{{
+		ClubID:    clubID,
+		Kind:      activity.KindMatchRecorded,
+		GameID:    &gameID,
+		SubjectID: &matchID,
+		Data: activity.Data{
+			"teams":  teamMembers,
+			"sets":   sets,
+			"swings": swings,
+		},
+	}}, activities...)
+	for _, a := range activities {
+		if err := s.activity.Record(ctx, a); err != nil {
 			// Log the error but don't fail the match creation
-			fmt.Printf("failed to update ratings: %v\n", err)
+			s.l.Error("failed to record activity", "kind", a.Kind, "error", err)
 		}
 	}
 
 	return matchID, nil
 }
 
+func (s *service) GetMatch(ctx context.Context, id uuid.UUID) (*Match, error) {
+	m, err := s.repo.GetMatch(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get match: %w", err)
+	}
+
+	return m, nil
+}
+
 func (s *service) GetMatches(ctx context.Context, clubID uuid.UUID, gameID *uuid.UUID) ([]Match, error) {
 	var matches []Match
 	var err error
@@ -218,3 +281,16 @@ func (s *service) GetOrCreateTeams(ctx context.Context, clubID uuid.UUID, member
 
 	return teams, nil
 }
+
+func (s *service) GetMemberMatches(ctx context.Context, memberIDs []uuid.UUID) ([]Match, error) {
+	if len(memberIDs) == 0 {
+		return []Match{}, nil
+	}
+
+	matches, err := s.repo.GetMatchesByMembers(ctx, memberIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get matches by members: %w", err)
+	}
+
+	return matches, nil
+}
@@ -1,6 +1,10 @@
 package member
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Role string
 
@@ -13,9 +17,67 @@ const (
 	RoleOwner    Role = "owner"
 )
 
+// Level ranks roles from least to most privileged.
+func (r Role) Level() int {
+	switch r {
+	case RoleObserver:
+		return 1
+	case RoleMember:
+		return 2
+	case RoleManager:
+		return 3
+	case RoleAdmin:
+		return 4
+	case RoleOwner:
+		return 5
+	default:
+		return 0
+	}
+}
+
+// AtLeast reports whether r is as privileged as other or more.
+func (r Role) AtLeast(other Role) bool {
+	return r.Level() >= other.Level()
+}
+
 type Member struct {
 	ID     uuid.UUID `db:"id"`
 	ClubID uuid.UUID `db:"club_id"`
-	UserID uuid.UUID `db:"user_id"`
-	Role   Role      `db:"role"`
+	// UserID is nil for guests
+	UserID *uuid.UUID `db:"user_id"`
+	Role   Role       `db:"role"`
+	// Nickname replaces the user's display name within the club, guests are
+	// only known by it
+	Nickname  *string   `db:"nickname"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// IsGuest reports whether the member plays without an account. Guests can be
+// recorded in matches, but hold no role in the club.
+func (m *Member) IsGuest() bool {
+	return m.UserID == nil
+}
+
+// MergeResult describes what merging one member into another changes.
+type MergeResult struct {
+	// Matches is how many matches move over to the remaining member
+	Matches int
+	// CollapsedTeams is how many teams were dropped for having the same
+	// members as another team after the merge
+	CollapsedTeams int
+	// CombinedStatistics is how many games both members had statistics for
+	CombinedStatistics int
+	// Games whose ratings were recomputed
+	Games []uuid.UUID
+}
+
+// OwnershipTransfer hands the club over from an owner to another member once
+// that member accepts.
+type OwnershipTransfer struct {
+	ID           uuid.UUID `db:"id"`
+	ClubID       uuid.UUID `db:"club_id"`
+	FromMemberID uuid.UUID `db:"from_member_id"`
+	ToMemberID   uuid.UUID `db:"to_member_id"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	CreatedAt    time.Time `db:"created_at"`
 }
@@ -0,0 +1,138 @@
+package member
+
+import (
+	"context"
+	"core/internal/audit"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ownershipTransferExpiry is how long the receiving member has to accept.
+const ownershipTransferExpiry = 7 * 24 * time.Hour
+
+func (s *service) StartOwnershipTransfer(ctx context.Context, actorUserId, clubId, toMemberId uuid.UUID) (*OwnershipTransfer, error) {
+	from, err := s.repo.GetClubMember(ctx, clubId, actorUserId)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrNotOwner
+		}
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+	if from.Role != RoleOwner {
+		return nil, ErrNotOwner
+	}
+
+	to, err := s.repo.GetMember(ctx, toMemberId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+	if to.ClubID != clubId {
+		return nil, ErrDifferentClub
+	}
+	if to.ID == from.ID {
+		return nil, ErrSameMember
+	}
+	if to.IsGuest() {
+		return nil, ErrGuest
+	}
+	if to.Role == RoleOwner {
+		return nil, ErrOwner
+	}
+
+	transfer := &OwnershipTransfer{
+		ClubID:       clubId,
+		FromMemberID: from.ID,
+		ToMemberID:   to.ID,
+		ExpiresAt:    time.Now().Add(ownershipTransferExpiry),
+	}
+	if err := s.repo.CreateOwnershipTransfer(ctx, transfer); err != nil {
+		return nil, fmt.Errorf("failed to create ownership transfer: %w", err)
+	}
+
+	return transfer, nil
+}
+
+func (s *service) GetOwnershipTransfer(ctx context.Context, clubId uuid.UUID) (*OwnershipTransfer, error) {
+	transfer, err := s.repo.GetOwnershipTransfer(ctx, clubId)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get ownership transfer: %w", err)
+	}
+
+	return transfer, nil
+}
+
+func (s *service) CancelOwnershipTransfer(ctx context.Context, clubId uuid.UUID) error {
+	if err := s.repo.DeleteOwnershipTransfer(ctx, clubId); err != nil {
+		return fmt.Errorf("failed to delete ownership transfer: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) AcceptOwnershipTransfer(ctx context.Context, userId, clubId uuid.UUID) error {
+	transfer, to, err := s.getReceivedTransfer(ctx, userId, clubId)
+	if err != nil {
+		return err
+	}
+
+	// The offer lapses if its owner has since lost the club
+	from, err := s.repo.GetMember(ctx, transfer.FromMemberID)
+	if err != nil {
+		return fmt.Errorf("failed to get member: %w", err)
+	}
+	if from.Role != RoleOwner {
+		return ErrNotFound
+	}
+
+	entry := &audit.Entry{
+		ClubID:   clubId,
+		ActorID:  &userId,
+		Action:   audit.ActionOwnershipTransferred,
+		TargetID: &to.ID,
+		Details: audit.Details{
+			"fromMemberId": from.ID,
+			"toMemberId":   to.ID,
+		},
+	}
+	if err := s.repo.TransferOwnership(ctx, transfer, entry); err != nil {
+		return fmt.Errorf("failed to transfer ownership: %w", err)
+	}
+
+	if err := s.membershipsChanged(ctx, from); err != nil {
+		return err
+	}
+	return s.membershipsChanged(ctx, to)
+}
+
+func (s *service) DeclineOwnershipTransfer(ctx context.Context, userId, clubId uuid.UUID) error {
+	if _, _, err := s.getReceivedTransfer(ctx, userId, clubId); err != nil {
+		return err
+	}
+
+	return s.CancelOwnershipTransfer(ctx, clubId)
+}
+
+// getReceivedTransfer returns the club's pending transfer and its receiving
+// member, or ErrNotFound unless the user is on the receiving end.
+func (s *service) getReceivedTransfer(ctx context.Context, userId, clubId uuid.UUID) (*OwnershipTransfer, *Member, error) {
+	transfer, err := s.GetOwnershipTransfer(ctx, clubId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	to, err := s.repo.GetMember(ctx, transfer.ToMemberID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get member: %w", err)
+	}
+	if to.IsGuest() || *to.UserID != userId {
+		return nil, nil, ErrNotFound
+	}
+
+	return transfer, to, nil
+}
@@ -2,18 +2,52 @@ package member
 
 import (
 	"context"
+	"core/internal/audit"
+	"core/internal/event"
+	"core/internal/rating"
+	"core/internal/statistic"
+	"database/sql"
+	"errors"
+	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
+var (
+	ErrNotFound = fmt.Errorf("not found")
+	// ErrOpposingTeams is returned when merging members who played against each other.
+	ErrOpposingTeams = fmt.Errorf("members played against each other")
+)
+
 type Repository interface {
 	GetMembersInClub(ctx context.Context, clubId uuid.UUID) ([]Member, error)
 	GetUserMemberships(ctx context.Context, userId uuid.UUID) ([]Member, error)
 	GetMember(ctx context.Context, id uuid.UUID) (*Member, error)
+	GetClubMember(ctx context.Context, clubId, userId uuid.UUID) (*Member, error)
 	CreateMember(ctx context.Context, member *Member) error
-	UpdateRole(ctx context.Context, memberId uuid.UUID, role Role) error
-	DeleteMember(ctx context.Context, memberId uuid.UUID) error
+	// CreateGuest inserts a member without a user, filling in its ID, and
+	// writes the audit entry targeting it.
+	CreateGuest(ctx context.Context, member *Member, entry *audit.Entry) error
+	// MergeMembers moves everything recorded for one member over to another,
+	// deletes the first one and writes the audit entry. With dryRun nothing is
+	// kept, only the result is reported. Returns ErrOpposingTeams if the two
+	// played against each other.
+	MergeMembers(ctx context.Context, fromId, intoId uuid.UUID, entry *audit.Entry, dryRun bool) (*MergeResult, error)
+	// UpdateRole, UpdateNickname and DeleteMember write the audit entry along
+	// with the change. UpdateRole and DeleteMember return ErrLastOwner rather
+	// than leave the club without an owner.
+	UpdateRole(ctx context.Context, memberId uuid.UUID, role Role, entry *audit.Entry) error
+	UpdateNickname(ctx context.Context, memberId uuid.UUID, nickname *string, entry *audit.Entry) error
+	DeleteMember(ctx context.Context, memberId uuid.UUID, entry *audit.Entry) error
+	// CreateOwnershipTransfer replaces any pending transfer of the club.
+	CreateOwnershipTransfer(ctx context.Context, transfer *OwnershipTransfer) error
+	// GetOwnershipTransfer returns the club's pending transfer unless it expired.
+	GetOwnershipTransfer(ctx context.Context, clubId uuid.UUID) (*OwnershipTransfer, error)
+	DeleteOwnershipTransfer(ctx context.Context, clubId uuid.UUID) error
+	// TransferOwnership makes the receiving member owner and the former owner
+	// an admin, completing the transfer and writing the audit entry.
+	TransferOwnership(ctx context.Context, transfer *OwnershipTransfer, entry *audit.Entry) error
 }
 
 type repository struct {
@@ -38,7 +72,12 @@ func (r *repository) GetMembersInClub(ctx context.Context, clubId uuid.UUID) ([]
 
 func (r *repository) GetUserMemberships(ctx context.Context, userId uuid.UUID) ([]Member, error) {
 	var memberships []Member
-	err := r.db.SelectContext(ctx, &memberships, "SELECT * FROM members WHERE user_id = $1", userId)
+	err := r.db.SelectContext(ctx, &memberships, `
+		SELECT m.*
+		FROM members m
+		JOIN clubs c ON c.id = m.club_id
+		WHERE m.user_id = $1 AND c.deleted_at IS NULL`,
+		userId)
 	if err != nil {
 		return nil, err
 	}
@@ -50,6 +89,21 @@ func (r *repository) GetMember(ctx context.Context, id uuid.UUID) (*Member, erro
 	var member Member
 	err := r.db.GetContext(ctx, &member, "SELECT * FROM members WHERE id = $1", id)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *repository) GetClubMember(ctx context.Context, clubId, userId uuid.UUID) (*Member, error) {
+	var member Member
+	err := r.db.GetContext(ctx, &member, "SELECT * FROM members WHERE club_id = $1 AND user_id = $2", clubId, userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 	return &member, nil
@@ -65,20 +119,297 @@ func (r *repository) CreateMember(ctx context.Context, member *Member) error {
 	return nil
 }
 
-func (r *repository) UpdateRole(ctx context.Context, memberId uuid.UUID, role Role) error {
-	_, err := r.db.ExecContext(ctx, "UPDATE members SET role = $1 WHERE id = $2", role, memberId)
-	if err != nil {
+func (r *repository) CreateGuest(ctx context.Context, member *Member, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			"INSERT INTO members (club_id, role, nickname) VALUES ($1, $2, $3) RETURNING id, created_at",
+			member.ClubID, member.Role, member.Nickname).Scan(&member.ID, &member.CreatedAt)
+		if err != nil {
+			return err
+		}
+		entry.TargetID = &member.ID
+
+		return nil
+	})
+}
+
+func (r *repository) UpdateRole(ctx context.Context, memberId uuid.UUID, role Role, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		if role != RoleOwner {
+			if err := keepOwner(ctx, tx, memberId); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.ExecContext(ctx, "UPDATE members SET role = $1 WHERE id = $2", role, memberId)
+		return err
+	})
+}
+
+func (r *repository) UpdateNickname(ctx context.Context, memberId uuid.UUID, nickname *string, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, "UPDATE members SET nickname = $1 WHERE id = $2", nickname, memberId)
 		return err
+	})
+}
+
+func (r *repository) DeleteMember(ctx context.Context, memberId uuid.UUID, entry *audit.Entry) error {
+	return audit.WithEntry(ctx, r.db, entry, func(tx *sqlx.Tx) error {
+		if err := keepOwner(ctx, tx, memberId); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, "DELETE FROM members WHERE id = $1", memberId)
+		return err
+	})
+}
+
+// keepOwner returns ErrLastOwner if the member is their club's only owner.
+// The owners stay locked until tx ends, so owners stepping down at the same
+// time can't both see the other one remaining.
+func keepOwner(ctx context.Context, tx *sqlx.Tx, memberId uuid.UUID) error {
+	var owners []uuid.UUID
+	err := tx.SelectContext(ctx, &owners, `
+		SELECT id FROM members
+		WHERE club_id = (SELECT club_id FROM members WHERE id = $1) AND role = 'owner'
+		ORDER BY id
+		FOR UPDATE`,
+		memberId)
+	if err != nil {
+		return fmt.Errorf("failed to lock owners: %w", err)
+	}
+
+	if len(owners) == 1 && owners[0] == memberId {
+		return ErrLastOwner
+	}
+
+	return nil
+}
+
+func (r *repository) MergeMembers(ctx context.Context, fromId, intoId uuid.UUID, entry *audit.Entry, dryRun bool) (*MergeResult, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// A match between the two can't be told apart once they are one member
+	var opposing int
+	err = tx.GetContext(ctx, &opposing, `
+		SELECT COUNT(*)
+		FROM match_teams fm
+		JOIN team_members ftm ON ftm.team_id = fm.team_id AND ftm.member_id = $1
+		JOIN match_teams im ON im.match_id = fm.match_id AND im.team_id <> fm.team_id
+		JOIN team_members itm ON itm.team_id = im.team_id AND itm.member_id = $2`,
+		fromId, intoId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for matches against each other: %w", err)
+	}
+	if opposing > 0 {
+		return nil, ErrOpposingTeams
+	}
+
+	result := &MergeResult{}
+	err = tx.GetContext(ctx, &result.Matches, `
+		SELECT COUNT(DISTINCT mt.match_id)
+		FROM match_teams mt
+		JOIN team_members tm ON tm.team_id = mt.team_id
+		WHERE tm.member_id = $1`,
+		fromId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count matches: %w", err)
+	}
+
+	err = tx.SelectContext(ctx, &result.Games, `
+		SELECT DISTINCT m.game_id
+		FROM matches m
+		JOIN match_teams mt ON mt.match_id = m.id
+		JOIN team_members tm ON tm.team_id = mt.team_id
+		WHERE tm.member_id = $1`,
+		fromId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get games: %w", err)
+	}
+
+	err = tx.GetContext(ctx, &result.CombinedStatistics, `
+		SELECT COUNT(*)
+		FROM statistics f
+		JOIN statistics s ON s.game_id = f.game_id AND s.member_id = $2
+		WHERE f.member_id = $1`,
+		fromId, intoId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count statistics: %w", err)
 	}
 
+	// Each step first settles the rows both members have, which the unique
+	// constraints would otherwise reject, then hands over the rest. Ratings
+	// are only kept consistent here, they are recomputed from the matches
+	// afterwards.
+	for _, query := range []string{
+		// Teams both played in keep the target only
+		`DELETE FROM team_members tm WHERE tm.member_id = $1
+			AND EXISTS (SELECT 1 FROM team_members o WHERE o.team_id = tm.team_id AND o.member_id = $2)`,
+		"UPDATE team_members SET member_id = $2 WHERE member_id = $1",
+
+		// The target's statistics are recomputed from the merged history afterwards
+		"DELETE FROM statistics WHERE member_id = $1",
+
+		// Of two ratings the more certain one is kept
+		`UPDATE ratings r SET mu = f.mu, sigma = f.sigma
+			FROM ratings f WHERE f.member_id = $1 AND r.member_id = $2 AND f.game_id = r.game_id AND f.sigma < r.sigma`,
+		"DELETE FROM ratings WHERE member_id = $1 AND game_id IN (SELECT game_id FROM ratings WHERE member_id = $2)",
+		"UPDATE ratings SET member_id = $2 WHERE member_id = $1",
+
+		`DELETE FROM event_attendances ea WHERE ea.member_id = $1
+			AND EXISTS (SELECT 1 FROM event_attendances o WHERE o.event_id = ea.event_id AND o.member_id = $2)`,
+		"UPDATE event_attendances SET member_id = $2 WHERE member_id = $1",
+
+		"DELETE FROM attendance_statistics WHERE member_id = $1",
+
+		"UPDATE activities SET member_id = $2 WHERE member_id = $1",
+
+		"DELETE FROM members WHERE id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, query, fromId, intoId); err != nil {
+			return nil, fmt.Errorf("failed to move member data: %w", err)
+		}
+	}
+
+	// Teams are reused by their members, so teams that now have the same
+	// members collapse into the oldest of them
+	var duplicates []struct {
+		ID   uuid.UUID `db:"id"`
+		Keep uuid.UUID `db:"keep"`
+	}
+	err = tx.SelectContext(ctx, &duplicates, `
+		WITH sets AS (
+			SELECT tm.team_id AS id, array_agg(tm.member_id ORDER BY tm.member_id) AS members
+			FROM team_members tm
+			GROUP BY tm.team_id
+			HAVING bool_or(tm.member_id = $1)
+		), ranked AS (
+			SELECT id, first_value(id) OVER (PARTITION BY members ORDER BY id) AS keep
+			FROM sets
+		)
+		SELECT id, keep FROM ranked WHERE id <> keep`,
+		intoId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicate teams: %w", err)
+	}
+
+	for _, duplicate := range duplicates {
+		if _, err := tx.ExecContext(ctx, "UPDATE match_teams SET team_id = $1 WHERE team_id = $2", duplicate.Keep, duplicate.ID); err != nil {
+			return nil, fmt.Errorf("failed to move matches to team: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM teams WHERE id = $1", duplicate.ID); err != nil {
+			return nil, fmt.Errorf("failed to delete duplicate team: %w", err)
+		}
+	}
+	result.CollapsedTeams = len(duplicates)
+
+	// Ratings and statistics are replayed with the merged member in the same
+	// transaction, so they never disagree with the matches and events. Adding
+	// up the two members' statistics would count shared matches and events twice.
+	for _, gameID := range result.Games {
+		if err := rating.Recompute(ctx, tx, gameID); err != nil {
+			return nil, fmt.Errorf("failed to recompute ratings: %w", err)
+		}
+		if err := statistic.Recompute(ctx, tx, intoId, gameID); err != nil {
+			return nil, fmt.Errorf("failed to recompute statistics: %w", err)
+		}
+	}
+	if err := event.RebuildAttendanceStatistic(ctx, tx, intoId); err != nil {
+		return nil, fmt.Errorf("failed to rebuild attendance statistic: %w", err)
+	}
+
+	entry.Details = audit.Details{
+		"fromMemberId":       fromId,
+		"intoMemberId":       intoId,
+		"matches":            result.Matches,
+		"collapsedTeams":     result.CollapsedTeams,
+		"combinedStatistics": result.CombinedStatistics,
+	}
+	if err := audit.Insert(ctx, tx, entry); err != nil {
+		return nil, fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	// A preview goes through the whole merge to report exactly what it would
+	// do, and is then rolled back
+	if dryRun {
+		return result, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *repository) CreateOwnershipTransfer(ctx context.Context, transfer *OwnershipTransfer) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO ownership_transfers (club_id, from_member_id, to_member_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (club_id) DO UPDATE
+		SET from_member_id = EXCLUDED.from_member_id, to_member_id = EXCLUDED.to_member_id,
+			expires_at = EXCLUDED.expires_at, created_at = CURRENT_TIMESTAMP
+		RETURNING id, created_at`,
+		transfer.ClubID, transfer.FromMemberID, transfer.ToMemberID, transfer.ExpiresAt).Scan(&transfer.ID, &transfer.CreatedAt)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
-func (r *repository) DeleteMember(ctx context.Context, memberId uuid.UUID) error {
-	_, err := r.db.ExecContext(ctx, "DELETE FROM members WHERE id = $1", memberId)
+func (r *repository) GetOwnershipTransfer(ctx context.Context, clubId uuid.UUID) (*OwnershipTransfer, error) {
+	var transfer OwnershipTransfer
+	err := r.db.GetContext(ctx, &transfer,
+		"SELECT * FROM ownership_transfers WHERE club_id = $1 AND expires_at > CURRENT_TIMESTAMP",
+		clubId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+func (r *repository) DeleteOwnershipTransfer(ctx context.Context, clubId uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM ownership_transfers WHERE club_id = $1", clubId)
 	if err != nil {
 		return err
 	}
+	return nil
+}
+
+func (r *repository) TransferOwnership(ctx context.Context, transfer *OwnershipTransfer, entry *audit.Entry) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, step := range []struct {
+		query string
+		args  []any
+	}{
+		{"UPDATE members SET role = 'owner' WHERE id = $1", []any{transfer.ToMemberID}},
+		{"UPDATE members SET role = 'admin' WHERE id = $1 AND role = 'owner'", []any{transfer.FromMemberID}},
+		{"DELETE FROM ownership_transfers WHERE id = $1", []any{transfer.ID}},
+	} {
+		if _, err := tx.ExecContext(ctx, step.query, step.args...); err != nil {
+			return fmt.Errorf("failed to transfer ownership: %w", err)
+		}
+	}
+
+	if err := audit.Insert(ctx, tx, entry); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 
 	return nil
 }
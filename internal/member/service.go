@@ -2,6 +2,9 @@ package member
 
 import (
 	"context"
+	"core/internal/audit"
+	"core/internal/cache"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
@@ -9,21 +12,75 @@ import (
 
 type Service interface {
 	GetMembersInClub(ctx context.Context, clubId uuid.UUID) ([]Member, error)
+	// GetUserMemberships returns the user's memberships in clubs that are not deleted.
 	GetUserMemberships(ctx context.Context, userId uuid.UUID) ([]Member, error)
 	GetMember(ctx context.Context, id uuid.UUID) (*Member, error)
+	// GetClubMember returns the user's membership in the club, even if the
+	// club is deleted.
+	GetClubMember(ctx context.Context, clubId, userId uuid.UUID) (*Member, error)
+	// ClubMembershipsChanged makes the tokens of all club members pick up
+	// their memberships again, e.g. after the club was deleted.
+	ClubMembershipsChanged(ctx context.Context, clubId uuid.UUID) error
 	CreateMember(ctx context.Context, member *Member) error
-	UpdateRole(ctx context.Context, memberId uuid.UUID, role Role) error
-	DeleteMember(ctx context.Context, memberId uuid.UUID) error
+	// UpdateRole changes a member's role on behalf of the acting user, who
+	// can neither grant a role above their own nor change members ranking
+	// above them. Ownership can only be transferred, and the last owner keeps
+	// their role.
+	UpdateRole(ctx context.Context, actorUserId, memberId uuid.UUID, role Role) error
+	// UpdateNickname sets the member's nickname on behalf of the acting user,
+	// nil removes it.
+	UpdateNickname(ctx context.Context, actorUserId, memberId uuid.UUID, nickname *string) error
+	// DeleteMember removes a member on behalf of the acting user, who can't
+	// remove members ranking above them. The last owner can't be removed.
+	DeleteMember(ctx context.Context, actorUserId, memberId uuid.UUID) error
+	// LeaveClub removes the user's own membership, unless they are the last owner.
+	LeaveClub(ctx context.Context, userId, clubId uuid.UUID) error
 	IsMember(ctx context.Context, userId, clubId uuid.UUID) (bool, error)
+	// CreateGuest adds a player without an account to the club.
+	CreateGuest(ctx context.Context, actorUserId, clubId uuid.UUID, name string) (*Member, error)
+	// PreviewMerge reports what MergeMembers would do without changing anything.
+	PreviewMerge(ctx context.Context, fromId, intoId, actorId uuid.UUID) (*MergeResult, error)
+	// MergeMembers moves the matches, statistics, ratings and attendance of
+	// one member over to another member of the same club and deletes the
+	// first. The ratings of the result's games are recomputed along the way.
+	// The acting user can't merge members ranking above them.
+	MergeMembers(ctx context.Context, fromId, intoId, actorId uuid.UUID) (*MergeResult, error)
+	// ClaimGuest merges the guest into the member the guest turned out to be.
+	ClaimGuest(ctx context.Context, guestId, memberId, actorId uuid.UUID) (*MergeResult, error)
+	// StartOwnershipTransfer offers the club to another member, replacing any
+	// pending offer. Only owners can transfer the club.
+	StartOwnershipTransfer(ctx context.Context, actorUserId, clubId, toMemberId uuid.UUID) (*OwnershipTransfer, error)
+	// GetOwnershipTransfer returns the club's pending transfer, or ErrNotFound.
+	GetOwnershipTransfer(ctx context.Context, clubId uuid.UUID) (*OwnershipTransfer, error)
+	CancelOwnershipTransfer(ctx context.Context, clubId uuid.UUID) error
+	// AcceptOwnershipTransfer makes the user owner if the pending transfer is
+	// theirs, the former owner becomes an admin.
+	AcceptOwnershipTransfer(ctx context.Context, userId, clubId uuid.UUID) error
+	// DeclineOwnershipTransfer drops the pending transfer if it is the user's.
+	DeclineOwnershipTransfer(ctx context.Context, userId, clubId uuid.UUID) error
 }
 
+var (
+	ErrNotGuest      = fmt.Errorf("member is not a guest")
+	ErrGuest         = fmt.Errorf("member is a guest")
+	ErrDifferentClub = fmt.Errorf("members belong to different clubs")
+	ErrSameMember    = fmt.Errorf("cannot merge a member into itself")
+	ErrOwner         = fmt.Errorf("member is the club owner")
+	ErrLastOwner     = fmt.Errorf("the club must keep an owner")
+	ErrOwnerRole     = fmt.Errorf("ownership can only be transferred")
+	ErrRoleTooHigh   = fmt.Errorf("role is above your own")
+	ErrNotOwner      = fmt.Errorf("only owners can transfer the club")
+)
+
 type service struct {
-	repo Repository
+	repo  Repository
+	cache cache.Service
 }
 
-func NewService(repo Repository) Service {
+func NewService(repo Repository, cache cache.Service) Service {
 	return &service{
-		repo: repo,
+		repo:  repo,
+		cache: cache,
 	}
 }
 
@@ -39,6 +96,33 @@ func (s *service) GetMember(ctx context.Context, id uuid.UUID) (*Member, error)
 	return s.repo.GetMember(ctx, id)
 }
 
+func (s *service) GetClubMember(ctx context.Context, clubId, userId uuid.UUID) (*Member, error) {
+	member, err := s.repo.GetClubMember(ctx, clubId, userId)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+
+	return member, nil
+}
+
+func (s *service) ClubMembershipsChanged(ctx context.Context, clubId uuid.UUID) error {
+	members, err := s.repo.GetMembersInClub(ctx, clubId)
+	if err != nil {
+		return fmt.Errorf("failed to get members: %w", err)
+	}
+
+	for i := range members {
+		if err := s.membershipsChanged(ctx, &members[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *service) CreateMember(ctx context.Context, member *Member) error {
 	if err := s.repo.CreateMember(ctx, member); err != nil {
 		return fmt.Errorf("failed to create member: %w", err)
@@ -46,17 +130,122 @@ func (s *service) CreateMember(ctx context.Context, member *Member) error {
 	return nil
 }
 
-func (s *service) UpdateRole(ctx context.Context, memberId uuid.UUID, role Role) error {
-	if err := s.repo.UpdateRole(ctx, memberId, role); err != nil {
+func (s *service) UpdateRole(ctx context.Context, actorUserId, memberId uuid.UUID, role Role) error {
+	member, err := s.repo.GetMember(ctx, memberId)
+	if err != nil {
+		return fmt.Errorf("failed to get member: %w", err)
+	}
+	if member.IsGuest() {
+		return ErrGuest
+	}
+	if role == RoleOwner && member.Role != RoleOwner {
+		return ErrOwnerRole
+	}
+
+	if err := s.checkActor(ctx, actorUserId, member, role); err != nil {
+		return err
+	}
+
+	entry := audit.NewEntry(member.ClubID, actorUserId, audit.ActionMemberRoleChanged, member.ID)
+	entry.Before = audit.Snapshot{"role": member.Role}
+	entry.After = audit.Snapshot{"role": role}
+
+	if err := s.repo.UpdateRole(ctx, memberId, role, entry); err != nil {
+		if errors.Is(err, ErrLastOwner) {
+			return ErrLastOwner
+		}
 		return fmt.Errorf("failed to update role: %w", err)
 	}
+
+	return s.membershipsChanged(ctx, member)
+}
+
+func (s *service) UpdateNickname(ctx context.Context, actorUserId, memberId uuid.UUID, nickname *string) error {
+	member, err := s.repo.GetMember(ctx, memberId)
+	if err != nil {
+		return fmt.Errorf("failed to get member: %w", err)
+	}
+
+	entry := audit.NewEntry(member.ClubID, actorUserId, audit.ActionMemberRenamed, member.ID)
+	entry.Before = audit.Snapshot{"nickname": member.Nickname}
+	entry.After = audit.Snapshot{"nickname": nickname}
+
+	if err := s.repo.UpdateNickname(ctx, memberId, nickname, entry); err != nil {
+		return fmt.Errorf("failed to update nickname: %w", err)
+	}
+
 	return nil
 }
 
-func (s *service) DeleteMember(ctx context.Context, memberId uuid.UUID) error {
-	if err := s.repo.DeleteMember(ctx, memberId); err != nil {
+func (s *service) DeleteMember(ctx context.Context, actorUserId, memberId uuid.UUID) error {
+	member, err := s.repo.GetMember(ctx, memberId)
+	if err != nil {
+		return fmt.Errorf("failed to get member: %w", err)
+	}
+
+	if err := s.checkActor(ctx, actorUserId, member, RoleNone); err != nil {
+		return err
+	}
+
+	return s.deleteMember(ctx, member, audit.NewEntry(member.ClubID, actorUserId, audit.ActionMemberRemoved, member.ID))
+}
+
+func (s *service) LeaveClub(ctx context.Context, userId, clubId uuid.UUID) error {
+	member, err := s.repo.GetClubMember(ctx, clubId, userId)
+	if err != nil {
+		return fmt.Errorf("failed to get member: %w", err)
+	}
+
+	return s.deleteMember(ctx, member, audit.NewEntry(clubId, userId, audit.ActionMemberLeft, member.ID))
+}
+
+// deleteMember removes the member along with the entry, which receives the
+// member's state before.
+func (s *service) deleteMember(ctx context.Context, member *Member, entry *audit.Entry) error {
+	entry.Before = audit.Snapshot{
+		"userId":   member.UserID,
+		"role":     member.Role,
+		"nickname": member.Nickname,
+	}
+
+	if err := s.repo.DeleteMember(ctx, member.ID, entry); err != nil {
+		if errors.Is(err, ErrLastOwner) {
+			return ErrLastOwner
+		}
 		return fmt.Errorf("failed to delete member: %w", err)
 	}
+
+	return s.membershipsChanged(ctx, member)
+}
+
+// checkActor returns ErrRoleTooHigh unless the acting user ranks at least as
+// high as both the member they act on and the role they grant.
+func (s *service) checkActor(ctx context.Context, actorUserId uuid.UUID, member *Member, role Role) error {
+	actor, err := s.repo.GetClubMember(ctx, member.ClubID, actorUserId)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrRoleTooHigh
+		}
+		return fmt.Errorf("failed to get acting member: %w", err)
+	}
+
+	if !actor.Role.AtLeast(member.Role) || !actor.Role.AtLeast(role) {
+		return ErrRoleTooHigh
+	}
+
+	return nil
+}
+
+// membershipsChanged makes the roles embedded in the member's access tokens
+// stale, so authorization falls back to the database until they refresh.
+func (s *service) membershipsChanged(ctx context.Context, member *Member) error {
+	if member.IsGuest() {
+		return nil
+	}
+
+	if err := s.cache.SetMembershipsChanged(ctx, *member.UserID); err != nil {
+		return fmt.Errorf("failed to invalidate memberships in tokens: %w", err)
+	}
 	return nil
 }
 
@@ -74,3 +263,89 @@ func (s *service) IsMember(ctx context.Context, userId, clubId uuid.UUID) (bool,
 
 	return false, nil
 }
+
+func (s *service) CreateGuest(ctx context.Context, actorUserId, clubId uuid.UUID, name string) (*Member, error) {
+	guest := &Member{
+		ClubID:   clubId,
+		Role:     RoleNone,
+		Nickname: &name,
+	}
+	entry := audit.NewEntry(clubId, actorUserId, audit.ActionGuestCreated, uuid.Nil)
+	entry.After = audit.Snapshot{"nickname": name}
+	if err := s.repo.CreateGuest(ctx, guest, entry); err != nil {
+		return nil, fmt.Errorf("failed to create guest: %w", err)
+	}
+
+	return guest, nil
+}
+
+func (s *service) PreviewMerge(ctx context.Context, fromId, intoId, actorId uuid.UUID) (*MergeResult, error) {
+	return s.merge(ctx, fromId, intoId, actorId, audit.ActionMembersMerged, true)
+}
+
+func (s *service) MergeMembers(ctx context.Context, fromId, intoId, actorId uuid.UUID) (*MergeResult, error) {
+	return s.merge(ctx, fromId, intoId, actorId, audit.ActionMembersMerged, false)
+}
+
+func (s *service) ClaimGuest(ctx context.Context, guestId, memberId, actorId uuid.UUID) (*MergeResult, error) {
+	guest, err := s.repo.GetMember(ctx, guestId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest: %w", err)
+	}
+	if !guest.IsGuest() {
+		return nil, ErrNotGuest
+	}
+
+	return s.merge(ctx, guestId, memberId, actorId, audit.ActionGuestClaimed, false)
+}
+
+func (s *service) merge(ctx context.Context, fromId, intoId, actorId uuid.UUID, action audit.Action, dryRun bool) (*MergeResult, error) {
+	if fromId == intoId {
+		return nil, ErrSameMember
+	}
+
+	from, err := s.repo.GetMember(ctx, fromId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+	into, err := s.repo.GetMember(ctx, intoId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member: %w", err)
+	}
+
+	if from.ClubID != into.ClubID {
+		return nil, ErrDifferentClub
+	}
+	if into.IsGuest() {
+		return nil, ErrGuest
+	}
+	// The club would be left without an owner
+	if from.Role == RoleOwner {
+		return nil, ErrOwner
+	}
+
+	if err := s.checkActor(ctx, actorId, from, RoleNone); err != nil {
+		return nil, err
+	}
+	if err := s.checkActor(ctx, actorId, into, RoleNone); err != nil {
+		return nil, err
+	}
+
+	entry := audit.NewEntry(into.ClubID, actorId, action, into.ID)
+
+	result, err := s.repo.MergeMembers(ctx, fromId, intoId, entry, dryRun)
+	if err != nil {
+		if errors.Is(err, ErrOpposingTeams) {
+			return nil, ErrOpposingTeams
+		}
+		return nil, fmt.Errorf("failed to merge members: %w", err)
+	}
+
+	if !dryRun {
+		if err := s.membershipsChanged(ctx, from); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
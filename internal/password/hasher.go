@@ -0,0 +1,171 @@
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// prefix marks hashes in the current encoding: "$pv1$p=<pepper id>" followed
+// by the algorithm's own encoding, which starts with "$".
+const prefix = "$pv1$p="
+
+// Config is the hashing policy. Hashes not matching it are replaced the next
+// time the user logs in.
+type Config struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+	// Peppers holds secrets by ID. PepperID selects the one for new hashes,
+	// the others still verify hashes created before a rotation.
+	Peppers  map[string]string
+	PepperID string
+}
+
+// Hasher hashes and verifies passwords according to the policy.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches the hash, and if so whether
+	// the hash should be replaced because it was created under another policy.
+	Verify(password, hash string) (match, rehash bool, err error)
+}
+
+type hasher struct {
+	config Config
+}
+
+func NewHasher(config Config) (Hasher, error) {
+	switch config.Algorithm {
+	case "", AlgorithmBcrypt:
+		config.Algorithm = AlgorithmBcrypt
+		if config.BcryptCost == 0 {
+			config.BcryptCost = bcrypt.DefaultCost
+		}
+		if config.BcryptCost < bcrypt.MinCost || config.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case AlgorithmArgon2id:
+		config.Argon2 = config.Argon2.withDefaults()
+	default:
+		return nil, fmt.Errorf("unsupported password algorithm: %s", config.Algorithm)
+	}
+
+	if _, ok := config.Peppers[config.PepperID]; !ok {
+		return nil, fmt.Errorf("pepper %q is not configured", config.PepperID)
+	}
+	if strings.Contains(config.PepperID, "$") {
+		return nil, fmt.Errorf("pepper id must not contain '$'")
+	}
+
+	return &hasher{
+		config: config,
+	}, nil
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	peppered := h.pepper(h.config.PepperID, password)
+
+	var inner string
+	var err error
+	switch h.config.Algorithm {
+	case AlgorithmArgon2id:
+		inner, err = hashArgon2id(peppered, h.config.Argon2)
+	default:
+		var b []byte
+		b, err = bcrypt.GenerateFromPassword(peppered, h.config.BcryptCost)
+		inner = string(b)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return prefix + h.config.PepperID + inner, nil
+}
+
+func (h *hasher) Verify(password, hash string) (bool, bool, error) {
+	// Accounts without a password, e.g. created through a provider
+	if hash == "" {
+		return false, false, nil
+	}
+
+	if !strings.HasPrefix(hash, prefix) {
+		return h.verifyLegacy(password, hash)
+	}
+
+	pepperID, inner, ok := strings.Cut(strings.TrimPrefix(hash, prefix), "$")
+	if !ok {
+		return false, false, fmt.Errorf("malformed password hash")
+	}
+	inner = "$" + inner
+
+	if _, ok := h.config.Peppers[pepperID]; !ok {
+		return false, false, fmt.Errorf("password hash uses unknown pepper %q", pepperID)
+	}
+	peppered := h.pepper(pepperID, password)
+
+	var match bool
+	var current bool
+	switch {
+	case strings.HasPrefix(inner, "$argon2id$"):
+		var params Argon2Params
+		var err error
+		match, params, err = verifyArgon2id(peppered, inner)
+		if err != nil {
+			return false, false, err
+		}
+		current = h.config.Algorithm == AlgorithmArgon2id && params == h.config.Argon2
+	default:
+		err := bcrypt.CompareHashAndPassword([]byte(inner), peppered)
+		if err != nil && !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, fmt.Errorf("failed to compare password: %w", err)
+		}
+		match = err == nil
+
+		cost, err := bcrypt.Cost([]byte(inner))
+		if err != nil {
+			return false, false, fmt.Errorf("failed to read bcrypt cost: %w", err)
+		}
+		current = h.config.Algorithm == AlgorithmBcrypt && cost == h.config.BcryptCost
+	}
+	if !match {
+		return false, false, nil
+	}
+
+	return true, !current || pepperID != h.config.PepperID, nil
+}
+
+// verifyLegacy checks bcrypt hashes of the password with a pepper appended,
+// as created before hashes were versioned. Any configured pepper may have
+// been used. They are always rehashed.
+func (h *hasher) verifyLegacy(password, hash string) (bool, bool, error) {
+	for _, pepper := range h.config.Peppers {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password+pepper))
+		if err == nil {
+			return true, true, nil
+		}
+		if !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, fmt.Errorf("failed to compare legacy password: %w", err)
+		}
+	}
+
+	return false, false, nil
+}
+
+// pepper keys the password with the secret. The digest is always 44 bytes, so
+// long passwords are not truncated at bcrypt's 72 byte limit.
+func (h *hasher) pepper(pepperID, password string) []byte {
+	mac := hmac.New(sha256.New, []byte(h.config.Peppers[pepperID]))
+	mac.Write([]byte(password))
+
+	return []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
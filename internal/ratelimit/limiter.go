@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+var ErrLimited = fmt.Errorf("too many requests")
+
+// LimitedError is returned when a limit or lockout applies, telling the
+// client when to try again. It matches ErrLimited.
+type LimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LimitedError) Error() string {
+	return fmt.Sprintf("too many requests, retry after %s", e.RetryAfter)
+}
+
+func (e *LimitedError) Is(target error) bool {
+	return target == ErrLimited
+}
+
+// RetryAfterSeconds rounds up, so clients retrying on time are not limited again.
+func (e *LimitedError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// Limit allows a number of requests per fixed window. The zero Limit
+// allows everything.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Window > 0
+}
+
+// Rules are the limits of a route group, counted per client IP, per account
+// the request is about and across all clients.
+type Rules struct {
+	PerIP      Limit
+	PerAccount Limit
+	Global     Limit
+}
+
+// Lockout locks a key out after Threshold consecutive failures, for Base at
+// first and twice as long with every further failure, up to Max. Failures
+// are forgotten once Window passes without another.
+type Lockout struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+}
+
+func (l Lockout) Enabled() bool {
+	return l.Threshold > 0 && l.Base > 0
+}
+
+// duration is how long the key is locked out after its nth failure.
+func (l Lockout) duration(failures int) time.Duration {
+	if failures < l.Threshold {
+		return 0
+	}
+
+	lock := l.Base
+	for i := l.Threshold; i < failures; i++ {
+		lock *= 2
+		if l.Max > 0 && lock >= l.Max {
+			return l.Max
+		}
+	}
+	if l.Max > 0 && lock > l.Max {
+		return l.Max
+	}
+
+	return lock
+}
+
+// Limiter counts requests and failures per key.
+type Limiter interface {
+	// Allow counts a request against the limit, returning a LimitedError if it
+	// is exceeded. Disabled limits always allow.
+	Allow(ctx context.Context, key string, limit Limit) error
+	// CheckLockout returns a LimitedError while the key is locked out.
+	CheckLockout(ctx context.Context, key string) error
+	// Fail records a failure for the key, locking it out once the lockout's
+	// threshold is reached.
+	Fail(ctx context.Context, key string, lockout Lockout) error
+	// Reset forgets the key's failures and lifts its lockout.
+	Reset(ctx context.Context, key string) error
+}
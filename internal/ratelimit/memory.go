@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often expired entries are removed from memory.
+const sweepInterval = time.Minute
+
+type window struct {
+	count     int
+	expiresAt time.Time
+}
+
+type failures struct {
+	count       int
+	expiresAt   time.Time
+	lockedUntil time.Time
+}
+
+type memoryLimiter struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	windows   map[string]*window
+	failures  map[string]*failures
+	lastSweep time.Time
+}
+
+// NewMemoryLimiter keeps counts in the process, for tests and single
+// instance deployments. now may be nil to use the system clock.
+func NewMemoryLimiter(now func() time.Time) Limiter {
+	if now == nil {
+		now = time.Now
+	}
+
+	return &memoryLimiter{
+		now:      now,
+		windows:  map[string]*window{},
+		failures: map[string]*failures{},
+	}
+}
+
+func (l *memoryLimiter) Allow(_ context.Context, key string, limit Limit) error {
+	if !limit.Enabled() {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	w, ok := l.windows[key]
+	if !ok || !now.Before(w.expiresAt) {
+		w = &window{expiresAt: now.Add(limit.Window)}
+		l.windows[key] = w
+	}
+
+	w.count++
+	if w.count > limit.Requests {
+		return &LimitedError{RetryAfter: w.expiresAt.Sub(now)}
+	}
+
+	return nil
+}
+
+func (l *memoryLimiter) CheckLockout(_ context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	f, ok := l.failures[key]
+	if ok && now.Before(f.lockedUntil) {
+		return &LimitedError{RetryAfter: f.lockedUntil.Sub(now)}
+	}
+
+	return nil
+}
+
+func (l *memoryLimiter) Fail(_ context.Context, key string, lockout Lockout) error {
+	if !lockout.Enabled() {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	f, ok := l.failures[key]
+	if !ok || (!f.expiresAt.IsZero() && !now.Before(f.expiresAt)) {
+		f = &failures{}
+		l.failures[key] = f
+	}
+
+	f.count++
+	if lockout.Window > 0 {
+		f.expiresAt = now.Add(lockout.Window)
+	}
+	if lock := lockout.duration(f.count); lock > 0 {
+		f.lockedUntil = now.Add(lock)
+	}
+
+	return nil
+}
+
+func (l *memoryLimiter) Reset(_ context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.failures, key)
+
+	return nil
+}
+
+// sweep drops expired entries, so keys seen once don't accumulate. The
+// caller must hold the lock.
+func (l *memoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, w := range l.windows {
+		if !now.Before(w.expiresAt) {
+			delete(l.windows, key)
+		}
+	}
+	for key, f := range l.failures {
+		if !f.expiresAt.IsZero() && !now.Before(f.expiresAt) && !now.Before(f.lockedUntil) {
+			delete(l.failures, key)
+		}
+	}
+}
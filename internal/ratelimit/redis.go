@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type redisLimiter struct {
+	client *redis.Client
+}
+
+// NewRedisLimiter shares its counts between all instances of the API.
+func NewRedisLimiter(client *redis.Client) Limiter {
+	return &redisLimiter{
+		client: client,
+	}
+}
+
+// allow counts the request in the current window, which starts with the
+// first request, and returns the window's remaining time once it is full.
+var allow = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+if count > tonumber(ARGV[2]) then
+	return redis.call('PTTL', KEYS[1])
+end
+return 0
+`)
+
+func (l *redisLimiter) Allow(ctx context.Context, key string, limit Limit) error {
+	if !limit.Enabled() {
+		return nil
+	}
+
+	retryAfter, err := allow.Run(ctx, l.client, []string{limitKey(key)}, limit.Window.Milliseconds(), limit.Requests).Int64()
+	if err != nil {
+		return err
+	}
+	if retryAfter > 0 {
+		return &LimitedError{RetryAfter: time.Duration(retryAfter) * time.Millisecond}
+	}
+
+	return nil
+}
+
+func (l *redisLimiter) CheckLockout(ctx context.Context, key string) error {
+	ttl, err := l.client.PTTL(ctx, lockoutKey(key)).Result()
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		return &LimitedError{RetryAfter: ttl}
+	}
+
+	return nil
+}
+
+func (l *redisLimiter) Fail(ctx context.Context, key string, lockout Lockout) error {
+	if !lockout.Enabled() {
+		return nil
+	}
+
+	pipe := l.client.TxPipeline()
+	incr := pipe.Incr(ctx, failuresKey(key))
+	if lockout.Window > 0 {
+		pipe.PExpire(ctx, failuresKey(key), lockout.Window)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	lock := lockout.duration(int(incr.Val()))
+	if lock <= 0 {
+		return nil
+	}
+
+	return l.client.Set(ctx, lockoutKey(key), true, lock).Err()
+}
+
+func (l *redisLimiter) Reset(ctx context.Context, key string) error {
+	return l.client.Del(ctx, failuresKey(key), lockoutKey(key)).Err()
+}
+
+func limitKey(key string) string {
+	return "ratelimit:" + key
+}
+
+func failuresKey(key string) string {
+	return "failures:" + key
+}
+
+func lockoutKey(key string) string {
+	return "lockout:" + key
+}
@@ -20,3 +20,49 @@ type Rating struct {
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
 }
+
+// Initial is the rating a member starts out with in a game.
+func Initial(memberID, gameID uuid.UUID) Rating {
+	return Rating{
+		MemberID: memberID,
+		GameID:   gameID,
+		Mu:       startMu,
+		Sigma:    startSigma,
+	}
+}
+
+// Ordinal is the rating shown to players, a conservative estimate of their skill.
+func (r Rating) Ordinal() float64 {
+	return r.Mu - 3*r.Sigma
+}
+
+// Change is how a match moved a member's rating.
+type Change struct {
+	MemberID uuid.UUID
+	GameID   uuid.UUID
+	Before   float64
+	After    float64
+}
+
+// RatedMatch is a match as far as ratings are concerned, the member IDs of each
+// team and the rank each team placed.
+type RatedMatch struct {
+	Teams [][]uuid.UUID
+	Ranks []int
+}
+
+// Ranks ranks the teams of a match.
+// For now, we'll consider the first team as the winner
+// In a real implementation, you would determine the ranks based on the match results
+func Ranks(teams int) []int {
+	ranks := make([]int, teams)
+	for i := range ranks {
+		if i == 0 {
+			ranks[i] = 1 // First place
+		} else {
+			ranks[i] = 2 // Second place
+		}
+	}
+
+	return ranks
+}
@@ -2,6 +2,7 @@ package rating
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -37,7 +38,7 @@ func (r *repository) GetRatingByMemberId(ctx context.Context, memberId uuid.UUID
 func (r *repository) GetRatingsByMemberIds(ctx context.Context, memberIds []uuid.UUID) ([]Rating, error) {
 	var ratings []Rating
 
-	query, args, err := sqlx.In("SELECT * FROM ratings WHERE member_id IN (?)", memberIds)
+	query, args, err := sqlx.In("SELECT * FROM ratings WHERE member_id IN (?) AND game_id IN (SELECT id FROM games WHERE deleted_at IS NULL)", memberIds)
 	if err != nil {
 		return nil, err
 	}
@@ -98,3 +99,74 @@ func (r *repository) UpdateRating(ctx context.Context, rating *Rating) error {
 
 	return nil
 }
+
+// getRankedMatches returns the teams of the game's ranked matches, the matches
+// in the order they were played and their teams in the order they placed.
+func getRankedMatches(ctx context.Context, db sqlx.QueryerContext, gameID uuid.UUID) ([]RatedMatch, error) {
+	rows, err := db.QueryContext(ctx, `
+        SELECT m.id, mt.team_number, tm.member_id
+        FROM matches m
+        JOIN match_teams mt ON mt.match_id = m.id
+        JOIN team_members tm ON tm.team_id = mt.team_id
+        WHERE m.game_id = $1 AND m.ranked
+        ORDER BY m.created_at, m.id, mt.team_number, tm.member_id`,
+		gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		matches           []RatedMatch
+		lastMatch         uuid.UUID
+		lastTeam          int64
+		matchID, memberID uuid.UUID
+		teamNumber        int64
+	)
+	for rows.Next() {
+		if err := rows.Scan(&matchID, &teamNumber, &memberID); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case matchID != lastMatch:
+			matches = append(matches, RatedMatch{Teams: [][]uuid.UUID{{memberID}}})
+		case teamNumber != lastTeam:
+			last := &matches[len(matches)-1]
+			last.Teams = append(last.Teams, []uuid.UUID{memberID})
+		default:
+			last := &matches[len(matches)-1]
+			team := len(last.Teams) - 1
+			last.Teams[team] = append(last.Teams[team], memberID)
+		}
+		lastMatch, lastTeam = matchID, teamNumber
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range matches {
+		matches[i].Ranks = Ranks(len(matches[i].Teams))
+	}
+
+	return matches, nil
+}
+
+// replaceGameRatings swaps all ratings of the game for the given ones.
+func replaceGameRatings(ctx context.Context, db sqlx.ExecerContext, gameID uuid.UUID, ratings []Rating) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM ratings WHERE game_id = $1", gameID); err != nil {
+		return fmt.Errorf("failed to delete ratings: %w", err)
+	}
+
+	for _, rating := range ratings {
+		_, err := db.ExecContext(ctx,
+			"INSERT INTO ratings (member_id, game_id, mu, sigma) VALUES ($1, $2, $3, $4)",
+			rating.MemberID, gameID, rating.Mu, rating.Sigma)
+		if err != nil {
+			return fmt.Errorf("failed to create rating: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -6,11 +6,14 @@ import (
 
 	"github.com/Sebsh1/openskill.go"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
 type Service interface {
 	CreateRating(ctx context.Context, memberID, gameID uuid.UUID) (uuid.UUID, error)
-	UpdateRatingsByRanks(ctx context.Context, teamsByMemberIDs [][]uuid.UUID, ranks []int) error
+	// UpdateRatingsByRanks rates a match and returns how each rating changed.
+	UpdateRatingsByRanks(ctx context.Context, teamsByMemberIDs [][]uuid.UUID, ranks []int) ([]Change, error)
+	GetMemberRatings(ctx context.Context, memberIDs []uuid.UUID) ([]Rating, error)
 }
 
 type service struct {
@@ -26,14 +29,9 @@ func NewService(repo Repository) Service {
 }
 
 func (s *service) CreateRating(ctx context.Context, memberID, gameID uuid.UUID) (uuid.UUID, error) {
-	rating := &Rating{
-		MemberID: memberID,
-		GameID:   gameID,
-		Mu:       startMu,
-		Sigma:    startSigma,
-	}
+	rating := Initial(memberID, gameID)
 
-	id, err := s.repo.CreateRating(ctx, rating)
+	id, err := s.repo.CreateRating(ctx, &rating)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("failed to create rating: %w", err)
 	}
@@ -41,11 +39,11 @@ func (s *service) CreateRating(ctx context.Context, memberID, gameID uuid.UUID)
 	return id, nil
 }
 
-func (s *service) UpdateRatingsByRanks(ctx context.Context, teamsByMemberIDs [][]uuid.UUID, ranks []int) error {
+func (s *service) UpdateRatingsByRanks(ctx context.Context, teamsByMemberIDs [][]uuid.UUID, ranks []int) ([]Change, error) {
 	ids, shape := flatten(teamsByMemberIDs)
 	ratings, err := s.repo.GetRatingsByMemberIds(ctx, ids)
 	if err != nil {
-		return fmt.Errorf("failed to get ratings: %w", err)
+		return nil, fmt.Errorf("failed to get ratings: %w", err)
 	}
 
 	openSkillRatings := make([]openskill.Rating, len(ratings))
@@ -58,25 +56,32 @@ func (s *service) UpdateRatingsByRanks(ctx context.Context, teamsByMemberIDs [][
 
 	teams, err := unflatten(openSkillRatings, shape)
 	if err != nil {
-		return fmt.Errorf("failed to unflatten ratings: %w", err)
+		return nil, fmt.Errorf("failed to unflatten ratings: %w", err)
 	}
 
 	updatedRatings, err := s.rater.Rate(teams, ranks, nil, nil)
 	if err != nil {
-		return fmt.Errorf("failed to rate teams: %w", err)
+		return nil, fmt.Errorf("failed to rate teams: %w", err)
 	}
 
 	newRatings, _ := flatten(updatedRatings)
+	changes := make([]Change, len(ratings))
 	for i := range ratings {
+		changes[i] = Change{
+			MemberID: ratings[i].MemberID,
+			GameID:   ratings[i].GameID,
+			Before:   ratings[i].Ordinal(),
+		}
 		ratings[i].Mu = newRatings[i].Mu
 		ratings[i].Sigma = newRatings[i].Sigma
+		changes[i].After = ratings[i].Ordinal()
 	}
 
 	if err := s.repo.UpdateRatings(ctx, ratings); err != nil {
-		return fmt.Errorf("failed to update ratings: %w", err)
+		return nil, fmt.Errorf("failed to update ratings: %w", err)
 	}
 
-	return nil
+	return changes, nil
 }
 
 func flatten[T any](matrix [][]T) ([]T, []int) {
@@ -115,3 +120,69 @@ func unflatten[T any](flattened []T, shape []int) ([][]T, error) {
 
 	return matrix, nil
 }
+
+func (s *service) GetMemberRatings(ctx context.Context, memberIDs []uuid.UUID) ([]Rating, error) {
+	if len(memberIDs) == 0 {
+		return []Rating{}, nil
+	}
+
+	ratings, err := s.repo.GetRatingsByMemberIds(ctx, memberIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ratings: %w", err)
+	}
+
+	return ratings, nil
+}
+
+// Recompute rates the game's ranked matches again from the start and replaces
+// all ratings of the game, using db, which may be a transaction so the ratings
+// change together with the matches they are based on.
+func Recompute(ctx context.Context, db sqlx.ExtContext, gameID uuid.UUID) error {
+	matches, err := getRankedMatches(ctx, db, gameID)
+	if err != nil {
+		return fmt.Errorf("failed to get ranked matches: %w", err)
+	}
+
+	rater := openskill.DefaultPlackettLuceModel()
+	current := make(map[uuid.UUID]openskill.Rating)
+	for _, m := range matches {
+		teams := make([][]openskill.Rating, len(m.Teams))
+		for i, team := range m.Teams {
+			teams[i] = make([]openskill.Rating, len(team))
+			for j, memberID := range team {
+				r, ok := current[memberID]
+				if !ok {
+					r = openskill.Rating{Mu: startMu, Sigma: startSigma}
+				}
+				teams[i][j] = r
+			}
+		}
+
+		rated, err := rater.Rate(teams, m.Ranks, nil, nil)
+		if err != nil {
+			return fmt.Errorf("failed to rate teams: %w", err)
+		}
+
+		for i, team := range m.Teams {
+			for j, memberID := range team {
+				current[memberID] = rated[i][j]
+			}
+		}
+	}
+
+	ratings := make([]Rating, 0, len(current))
+	for memberID, r := range current {
+		ratings = append(ratings, Rating{
+			MemberID: memberID,
+			GameID:   gameID,
+			Mu:       r.Mu,
+			Sigma:    r.Sigma,
+		})
+	}
+
+	if err := replaceGameRatings(ctx, db, gameID, ratings); err != nil {
+		return fmt.Errorf("failed to replace ratings: %w", err)
+	}
+
+	return nil
+}
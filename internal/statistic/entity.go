@@ -7,13 +7,44 @@ import (
 )
 
 type Statistic struct {
-	ID        uuid.UUID `db:"id"`
-	MemberId  uuid.UUID `db:"member_id"`
-	GameId    uuid.UUID `db:"game_id"`
-	Wins      int       `db:"wins"`
-	Draws     int       `db:"draws"`
-	Losses    int       `db:"losses"`
-	Streak    int       `db:"streak"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	ID       uuid.UUID `db:"id"`
+	MemberId uuid.UUID `db:"member_id"`
+	GameId   uuid.UUID `db:"game_id"`
+	Wins     int       `db:"wins"`
+	Draws    int       `db:"draws"`
+	Losses   int       `db:"losses"`
+	Streak   int       `db:"streak"`
+	// LongestStreak is the member's best win streak in the game
+	LongestStreak int       `db:"longest_streak"`
+	CreatedAt     time.Time `db:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at"`
+}
+
+// Record counts a match result, returning whether it set a new best streak.
+func (s *Statistic) Record(won, drawn bool) bool {
+	if won {
+		s.Wins++
+		if s.Streak > 0 {
+			s.Streak++
+		} else {
+			s.Streak = 1
+		}
+	} else if drawn {
+		s.Draws++
+		s.Streak = 0
+	} else {
+		s.Losses++
+		if s.Streak < 0 {
+			s.Streak--
+		} else {
+			s.Streak = -1
+		}
+	}
+
+	newBest := s.Streak > s.LongestStreak
+	if newBest {
+		s.LongestStreak = s.Streak
+	}
+
+	return newBest
 }
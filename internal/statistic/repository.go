@@ -11,6 +11,7 @@ import (
 type Repository interface {
 	GetStatistics(ctx context.Context, memberID, gameID uuid.UUID) (*Statistic, error)
 	GetStatisticsByGame(ctx context.Context, gameID uuid.UUID) ([]Statistic, error)
+	GetStatisticsByMembers(ctx context.Context, memberIDs []uuid.UUID) ([]Statistic, error)
 	CreateStatistics(ctx context.Context, stats *Statistic) (uuid.UUID, error)
 	UpdateStatistics(ctx context.Context, stats *Statistic) error
 }
@@ -47,11 +48,25 @@ func (r *repository) GetStatisticsByGame(ctx context.Context, gameID uuid.UUID)
 	return stats, nil
 }
 
+func (r *repository) GetStatisticsByMembers(ctx context.Context, memberIDs []uuid.UUID) ([]Statistic, error) {
+	query, args, err := sqlx.In("SELECT * FROM statistics WHERE member_id IN (?) AND game_id IN (SELECT id FROM games WHERE deleted_at IS NULL)", memberIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build statistics query: %w", err)
+	}
+
+	var stats []Statistic
+	err = r.db.SelectContext(ctx, &stats, r.db.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statistics by members: %w", err)
+	}
+	return stats, nil
+}
+
 func (r *repository) CreateStatistics(ctx context.Context, stats *Statistic) (uuid.UUID, error) {
 	var id uuid.UUID
 	err := r.db.QueryRowContext(ctx,
-		"INSERT INTO statistics (member_id, game_id, wins, losses, draws, streak) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
-		stats.MemberId, stats.GameId, stats.Wins, stats.Losses, stats.Draws, stats.Streak).Scan(&id)
+		"INSERT INTO statistics (member_id, game_id, wins, losses, draws, streak, longest_streak) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+		stats.MemberId, stats.GameId, stats.Wins, stats.Losses, stats.Draws, stats.Streak, stats.LongestStreak).Scan(&id)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("failed to create statistics: %w", err)
 	}
@@ -60,10 +75,50 @@ func (r *repository) CreateStatistics(ctx context.Context, stats *Statistic) (uu
 
 func (r *repository) UpdateStatistics(ctx context.Context, stats *Statistic) error {
 	_, err := r.db.ExecContext(ctx,
-		"UPDATE statistics SET wins = $1, losses = $2, draws = $3, streak = $4, updated_at = CURRENT_TIMESTAMP WHERE member_id = $5 AND game_id = $6",
-		stats.Wins, stats.Losses, stats.Draws, stats.Streak, stats.MemberId, stats.GameId)
+		"UPDATE statistics SET wins = $1, losses = $2, draws = $3, streak = $4, longest_streak = $5, updated_at = CURRENT_TIMESTAMP WHERE member_id = $6 AND game_id = $7",
+		stats.Wins, stats.Losses, stats.Draws, stats.Streak, stats.LongestStreak, stats.MemberId, stats.GameId)
 	if err != nil {
 		return fmt.Errorf("failed to update statistics: %w", err)
 	}
 	return nil
 }
+
+// Recompute replays the member's matches in the game into their statistic
+// using db, which may be a transaction so the statistic changes together with
+// the matches. As when matches are recorded, the first team wins.
+func Recompute(ctx context.Context, db sqlx.ExtContext, memberID, gameID uuid.UUID) error {
+	var results []bool
+	err := sqlx.SelectContext(ctx, db, &results, `
+		SELECT mt.team_number = 1
+		FROM matches m
+		JOIN match_teams mt ON mt.match_id = m.id
+		JOIN team_members tm ON tm.team_id = mt.team_id
+		WHERE tm.member_id = $1 AND m.game_id = $2
+		ORDER BY m.created_at, m.id`,
+		memberID, gameID)
+	if err != nil {
+		return fmt.Errorf("failed to get match results: %w", err)
+	}
+
+	stats := &Statistic{MemberId: memberID, GameId: gameID}
+	for _, won := range results {
+		stats.Record(won, false)
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO statistics (member_id, game_id, wins, losses, draws, streak, longest_streak)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (member_id, game_id) DO UPDATE SET
+			wins = EXCLUDED.wins,
+			losses = EXCLUDED.losses,
+			draws = EXCLUDED.draws,
+			streak = EXCLUDED.streak,
+			longest_streak = EXCLUDED.longest_streak,
+			updated_at = CURRENT_TIMESTAMP`,
+		stats.MemberId, stats.GameId, stats.Wins, stats.Losses, stats.Draws, stats.Streak, stats.LongestStreak)
+	if err != nil {
+		return fmt.Errorf("failed to upsert statistics: %w", err)
+	}
+
+	return nil
+}
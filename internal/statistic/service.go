@@ -8,9 +8,13 @@ import (
 )
 
 type Service interface {
-	UpdateStatistics(ctx context.Context, memberID, gameID uuid.UUID, won, drawn bool) error
+	// UpdateStatistics counts the match result and reports whether the win
+	// streak became the member's longest in the game.
+	UpdateStatistics(ctx context.Context, memberID, gameID uuid.UUID, won, drawn bool) (stats *Statistic, newBest bool, err error)
 	GetStatistics(ctx context.Context, memberID, gameID uuid.UUID) (*Statistic, error)
 	GetStatisticsByGame(ctx context.Context, gameID uuid.UUID) ([]Statistic, error)
+	// GetStatisticsByMembers returns the statistics of all games the members played.
+	GetStatisticsByMembers(ctx context.Context, memberIDs []uuid.UUID) ([]Statistic, error)
 }
 
 type service struct {
@@ -23,7 +27,7 @@ func NewService(repo Repository) Service {
 	}
 }
 
-func (s *service) UpdateStatistics(ctx context.Context, memberID, gameID uuid.UUID, won, drawn bool) error {
+func (s *service) UpdateStatistics(ctx context.Context, memberID, gameID uuid.UUID, won, drawn bool) (*Statistic, bool, error) {
 	stats, err := s.repo.GetStatistics(ctx, memberID, gameID)
 	if err != nil {
 		// If statistics don't exist, create new ones
@@ -38,24 +42,7 @@ func (s *service) UpdateStatistics(ctx context.Context, memberID, gameID uuid.UU
 	}
 
 	// Update statistics based on match result
-	if won {
-		stats.Wins++
-		if stats.Streak > 0 {
-			stats.Streak++
-		} else {
-			stats.Streak = 1
-		}
-	} else if drawn {
-		stats.Draws++
-		stats.Streak = 0
-	} else {
-		stats.Losses++
-		if stats.Streak < 0 {
-			stats.Streak--
-		} else {
-			stats.Streak = -1
-		}
-	}
+	newBest := stats.Record(won, drawn)
 
 	if stats.ID == uuid.Nil {
 		// Create new statistics
@@ -66,10 +53,10 @@ func (s *service) UpdateStatistics(ctx context.Context, memberID, gameID uuid.UU
 	}
 
 	if err != nil {
-		return fmt.Errorf("failed to update statistics: %w", err)
+		return nil, false, fmt.Errorf("failed to update statistics: %w", err)
 	}
 
-	return nil
+	return stats, newBest, nil
 }
 
 func (s *service) GetStatistics(ctx context.Context, memberID, gameID uuid.UUID) (*Statistic, error) {
@@ -87,3 +74,15 @@ func (s *service) GetStatisticsByGame(ctx context.Context, gameID uuid.UUID) ([]
 	}
 	return stats, nil
 }
+
+func (s *service) GetStatisticsByMembers(ctx context.Context, memberIDs []uuid.UUID) ([]Statistic, error) {
+	if len(memberIDs) == 0 {
+		return []Statistic{}, nil
+	}
+
+	stats, err := s.repo.GetStatisticsByMembers(ctx, memberIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statistics by members: %w", err)
+	}
+	return stats, nil
+}
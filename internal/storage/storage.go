@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+var ErrNotFound = fmt.Errorf("blob not found")
+
+// Storage keeps blobs such as avatars outside the database. Keys are slash
+// separated paths chosen by the caller.
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns ErrNotFound if there is no blob under the key.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete succeeds if there is no blob under the key.
+	Delete(ctx context.Context, key string) error
+}
@@ -12,6 +12,7 @@ type Repository interface {
 	Create(ctx context.Context, userID uuid.UUID) error
 	GetByUserID(ctx context.Context, userID uuid.UUID) (*Subscription, error)
 	Update(ctx context.Context, userID uuid.UUID, tier Tier) error
+	GetClubTiers(ctx context.Context, clubIDs []uuid.UUID) (map[uuid.UUID]Tier, error)
 }
 
 type repository struct {
@@ -52,3 +53,33 @@ func (r *repository) Update(ctx context.Context, userID uuid.UUID, tier Tier) er
 
 	return nil
 }
+
+func (r *repository) GetClubTiers(ctx context.Context, clubIDs []uuid.UUID) (map[uuid.UUID]Tier, error) {
+	var rows []struct {
+		ClubID uuid.UUID `db:"club_id"`
+		Tier   Tier      `db:"tier"`
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT DISTINCT ON (m.club_id) m.club_id, s.tier
+		FROM members m
+		JOIN subscriptions s ON s.user_id = m.user_id
+		WHERE m.club_id IN (?) AND m.role = 'owner'
+		ORDER BY m.club_id, m.created_at, m.id`, clubIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	query = r.db.Rebind(query)
+	err = r.db.SelectContext(ctx, &rows, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	tiers := make(map[uuid.UUID]Tier, len(rows))
+	for _, row := range rows {
+		tiers[row.ClubID] = row.Tier
+	}
+
+	return tiers, nil
+}
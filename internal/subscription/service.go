@@ -2,6 +2,7 @@ package subscription
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/google/uuid"
 )
@@ -10,6 +11,9 @@ type Service interface {
 	Create(ctx context.Context, userID uuid.UUID) error
 	GetByUserID(ctx context.Context, userID uuid.UUID) (*Subscription, error)
 	Update(ctx context.Context, userID uuid.UUID, tier Tier) error
+	// GetClubTiers returns the tier of each club, the tier of its owner's
+	// subscription. Clubs without an owner holding a subscription are TierNone.
+	GetClubTiers(ctx context.Context, clubIDs []uuid.UUID) (map[uuid.UUID]Tier, error)
 }
 
 type service struct {
@@ -33,3 +37,25 @@ func (s *service) GetByUserID(ctx context.Context, userID uuid.UUID) (*Subscript
 func (s *service) Update(ctx context.Context, userID uuid.UUID, tier Tier) error {
 	return s.repo.Update(ctx, userID, tier)
 }
+
+func (s *service) GetClubTiers(ctx context.Context, clubIDs []uuid.UUID) (map[uuid.UUID]Tier, error) {
+	tiers := make(map[uuid.UUID]Tier, len(clubIDs))
+	if len(clubIDs) == 0 {
+		return tiers, nil
+	}
+
+	found, err := s.repo.GetClubTiers(ctx, clubIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get club tiers: %w", err)
+	}
+
+	for _, id := range clubIDs {
+		tier, ok := found[id]
+		if !ok {
+			tier = TierNone
+		}
+		tiers[id] = tier
+	}
+
+	return tiers, nil
+}
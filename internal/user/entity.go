@@ -7,11 +7,63 @@ import (
 )
 
 type User struct {
-	ID        uuid.UUID `db:"id"`
-	Email     string    `db:"email"`
-	Name      string    `db:"name"`
-	Hash      string    `db:"hash"`
-	CreatedAt time.Time `db:"created_at"`
-	LastLogin time.Time `db:"last_login"`
-	UpdatedAt time.Time `db:"updated_at"`
+	ID        uuid.UUID  `db:"id"`
+	Email     string     `db:"email"`
+	Name      string     `db:"name"`
+	Hash      string     `db:"hash"`
+	CreatedAt time.Time  `db:"created_at"`
+	LastLogin *time.Time `db:"last_login"`
+	UpdatedAt time.Time  `db:"updated_at"`
+
+	EmailVerifiedAt *time.Time `db:"email_verified_at"`
+	// DeletionScheduledAt is when the account will be anonymized, unless the
+	// user cancels before then
+	DeletionScheduledAt *time.Time `db:"deletion_scheduled_at"`
+	DeletedAt           *time.Time `db:"deleted_at"`
+
+	// DisplayName is shown instead of the name when set
+	DisplayName string `db:"display_name"`
+	Bio         string `db:"bio"`
+	// AvatarKey locates the avatar in blob storage
+	AvatarKey         *string    `db:"avatar_key"`
+	ProfileVisibility Visibility `db:"profile_visibility"`
+	StatsVisibility   Visibility `db:"stats_visibility"`
+}
+
+// Visibility decides who may see parts of a user's profile.
+type Visibility string
+
+const (
+	VisibilityPublic Visibility = "public"
+	// VisibilityClubs limits to users sharing a club with the user
+	VisibilityClubs   Visibility = "clubs"
+	VisibilityPrivate Visibility = "private"
+)
+
+// VisibleTo reports whether a viewer may see what has this visibility.
+func (v Visibility) VisibleTo(self, sharesClub bool) bool {
+	switch v {
+	case VisibilityPublic:
+		return true
+	case VisibilityClubs:
+		return self || sharesClub
+	default:
+		return self
+	}
+}
+
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// PublicName is how the user is shown to others.
+func (u *User) PublicName() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.Name
+}
+
+func (u *User) Deleted() bool {
+	return u.DeletedAt != nil
 }
@@ -0,0 +1,145 @@
+package user
+
+import (
+	"context"
+	"core/internal/storage"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/google/uuid"
+)
+
+// MaxAvatarSize bounds uploaded avatars in bytes.
+const MaxAvatarSize = 1 << 20
+
+var (
+	ErrInvalidAvatar     = fmt.Errorf("avatar must be a png, jpeg, gif or webp image")
+	ErrInvalidVisibility = fmt.Errorf("invalid visibility")
+)
+
+// avatarTypes maps the accepted image types to the extension they are stored
+// with, which is how their type is known again when serving them.
+var avatarTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+func (s *service) GetUsers(ctx context.Context, ids []uuid.UUID) ([]User, error) {
+	users, err := s.repo.GetUsers(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	return users, nil
+}
+
+func (s *service) UpdateProfile(ctx context.Context, user *User) error {
+	for _, v := range []Visibility{user.ProfileVisibility, user.StatsVisibility} {
+		if v != VisibilityPublic && v != VisibilityClubs && v != VisibilityPrivate {
+			return fmt.Errorf("%w: %s", ErrInvalidVisibility, v)
+		}
+	}
+
+	if err := s.repo.UpdateProfile(ctx, user); err != nil {
+		return fmt.Errorf("failed to update profile of user with id %s: %w", user.ID, err)
+	}
+
+	return nil
+}
+
+func (s *service) GetPreferredGames(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	gameIDs, err := s.repo.GetPreferredGames(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferred games: %w", err)
+	}
+
+	return gameIDs, nil
+}
+
+func (s *service) SetPreferredGames(ctx context.Context, userID uuid.UUID, gameIDs []uuid.UUID) error {
+	if err := s.repo.SetPreferredGames(ctx, userID, gameIDs); err != nil {
+		return fmt.Errorf("failed to set preferred games: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) SetAvatar(ctx context.Context, userID uuid.UUID, data []byte) error {
+	if len(data) > MaxAvatarSize {
+		return ErrInvalidAvatar
+	}
+	ext, ok := avatarTypes[http.DetectContentType(data)]
+	if !ok {
+		return ErrInvalidAvatar
+	}
+
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user with id %s: %w", userID, err)
+	}
+
+	// A new key for every upload keeps cached copies of the old one from
+	// being served in its place
+	key := path.Join("avatars", userID.String(), uuid.NewString()+ext)
+	if err := s.blobs.Put(ctx, key, data); err != nil {
+		return fmt.Errorf("failed to store avatar: %w", err)
+	}
+
+	if err := s.repo.SetAvatarKey(ctx, userID, &key); err != nil {
+		return fmt.Errorf("failed to set avatar: %w", err)
+	}
+
+	return s.deleteAvatarBlob(ctx, user.AvatarKey)
+}
+
+func (s *service) GetAvatar(ctx context.Context, user *User) ([]byte, string, error) {
+	if user.AvatarKey == nil {
+		return nil, "", ErrNotFound
+	}
+
+	data, err := s.blobs.Get(ctx, *user.AvatarKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", fmt.Errorf("failed to get avatar: %w", err)
+	}
+
+	contentType := "application/octet-stream"
+	for t, ext := range avatarTypes {
+		if path.Ext(*user.AvatarKey) == ext {
+			contentType = t
+		}
+	}
+
+	return data, contentType, nil
+}
+
+func (s *service) DeleteAvatar(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user with id %s: %w", userID, err)
+	}
+
+	if err := s.repo.SetAvatarKey(ctx, userID, nil); err != nil {
+		return fmt.Errorf("failed to remove avatar: %w", err)
+	}
+
+	return s.deleteAvatarBlob(ctx, user.AvatarKey)
+}
+
+func (s *service) deleteAvatarBlob(ctx context.Context, key *string) error {
+	if key == nil {
+		return nil
+	}
+
+	if err := s.blobs.Delete(ctx, *key); err != nil {
+		return fmt.Errorf("failed to delete avatar: %w", err)
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"errors"
 
@@ -16,16 +17,38 @@ const UniqueViolationCode = "23505"
 var (
 	ErrDuplicateEntry = fmt.Errorf("already exists")
 	ErrNotFound       = fmt.Errorf("not found")
+	ErrWrongPassword  = fmt.Errorf("wrong password")
+	// ErrDeletionNotScheduled is returned when cancelling a deletion that was
+	// not requested, or already carried out.
+	ErrDeletionNotScheduled = fmt.Errorf("deletion is not scheduled")
 )
 
+// DeletedName replaces the name of deleted users wherever they still appear.
+const DeletedName = "Deleted user"
+
 type Repository interface {
 	GetUser(ctx context.Context, id uuid.UUID) (*User, error)
 	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	GetUsers(ctx context.Context, ids []uuid.UUID) ([]User, error)
 	CreateUser(ctx context.Context, user *User) (uuid.UUID, error)
-	DeleteUser(ctx context.Context, id uuid.UUID) error
+	ScheduleDeletion(ctx context.Context, userID uuid.UUID, at time.Time) error
+	// CancelDeletion returns ErrDeletionNotScheduled unless a deletion is pending.
+	CancelDeletion(ctx context.Context, userID uuid.UUID) error
+	// GetUsersDueForDeletion returns the users whose grace period is over.
+	GetUsersDueForDeletion(ctx context.Context) ([]uuid.UUID, error)
+	// AnonymizeUser scrubs the user's personal data and everything they log in
+	// with, but keeps their memberships so matches stay intact.
+	AnonymizeUser(ctx context.Context, userID uuid.UUID) error
 	UpdateUser(ctx context.Context, user *User) error
 	UpdatePassword(ctx context.Context, userID uuid.UUID, hash string) error
+	// UpdateProfile saves the display name, bio and visibility settings.
+	UpdateProfile(ctx context.Context, user *User) error
+	SetAvatarKey(ctx context.Context, userID uuid.UUID, key *string) error
+	// GetPreferredGames returns the game IDs in the user's order.
+	GetPreferredGames(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	SetPreferredGames(ctx context.Context, userID uuid.UUID, gameIDs []uuid.UUID) error
 	UpdateLastLogin(ctx context.Context, userID uuid.UUID) error
+	SetEmailVerified(ctx context.Context, userID uuid.UUID) error
 }
 
 type repository struct {
@@ -66,6 +89,24 @@ func (r *repository) GetUserByEmail(ctx context.Context, email string) (*User, e
 	return &user, nil
 }
 
+func (r *repository) GetUsers(ctx context.Context, ids []uuid.UUID) ([]User, error) {
+	if len(ids) == 0 {
+		return []User{}, nil
+	}
+
+	query, args, err := sqlx.In("SELECT * FROM users WHERE id IN (?)", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []User
+	if err := r.db.SelectContext(ctx, &users, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
 func (r *repository) CreateUser(ctx context.Context, user *User) (uuid.UUID, error) {
 	var id uuid.UUID
 
@@ -79,17 +120,99 @@ func (r *repository) CreateUser(ctx context.Context, user *User) (uuid.UUID, err
 	return id, nil
 }
 
-func (r *repository) DeleteUser(ctx context.Context, id uuid.UUID) error {
-	_, err := r.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+func (r *repository) ScheduleDeletion(ctx context.Context, userID uuid.UUID, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE users SET deletion_scheduled_at = $1 WHERE id = $2 AND deleted_at IS NULL",
+		at, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) CancelDeletion(ctx context.Context, userID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE users SET deletion_scheduled_at = NULL WHERE id = $1 AND deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL",
+		userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return ErrDeletionNotScheduled
+	}
+
+	return nil
+}
+
+func (r *repository) GetUsersDueForDeletion(ctx context.Context) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	err := r.db.SelectContext(ctx, &userIDs,
+		"SELECT id FROM users WHERE deletion_scheduled_at <= CURRENT_TIMESTAMP AND deleted_at IS NULL")
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+func (r *repository) AnonymizeUser(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The address must stay unique, and can't receive mail
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users
+		SET email = 'deleted-' || id || '@deleted.invalid', name = $1, hash = '',
+			email_verified_at = NULL, last_login = NULL,
+			display_name = '', bio = '', avatar_key = NULL,
+			profile_visibility = 'private', stats_visibility = 'private',
+			deletion_scheduled_at = NULL, deleted_at = CURRENT_TIMESTAMP
+		WHERE id = $2`,
+		DeletedName, userID)
+	if err != nil {
+		return fmt.Errorf("failed to anonymize user: %w", err)
+	}
+
+	for _, query := range []string{
+		"DELETE FROM user_identities WHERE user_id = $1",
+		"DELETE FROM user_two_factor WHERE user_id = $1",
+		"DELETE FROM user_recovery_codes WHERE user_id = $1",
+		"DELETE FROM user_tokens WHERE user_id = $1",
+		"DELETE FROM api_keys WHERE user_id = $1",
+		"DELETE FROM club_invites WHERE user_id = $1",
+		"DELETE FROM user_preferred_games WHERE user_id = $1",
+		// Memberships keep the matches together, without any privileges
+		"UPDATE members SET role = 'none' WHERE user_id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return fmt.Errorf("failed to remove user data: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 
 	return nil
 }
 
 func (r *repository) UpdateUser(ctx context.Context, user *User) error {
-	_, err := r.db.ExecContext(ctx, "UPDATE users SET email = $1, name = $2 WHERE id = $3", user.Email, user.Name, user.ID)
+	// A new email address has to be verified again
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET email = $1, name = $2,
+			email_verified_at = CASE WHEN email = $1 THEN email_verified_at ELSE NULL END
+		WHERE id = $3`,
+		user.Email, user.Name, user.ID)
 	if err != nil {
 		return err
 	}
@@ -106,6 +229,67 @@ func (r *repository) UpdatePassword(ctx context.Context, userID uuid.UUID, hash
 	return nil
 }
 
+func (r *repository) UpdateProfile(ctx context.Context, user *User) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET display_name = $1, bio = $2, profile_visibility = $3, stats_visibility = $4
+		WHERE id = $5`,
+		user.DisplayName, user.Bio, user.ProfileVisibility, user.StatsVisibility, user.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) SetAvatarKey(ctx context.Context, userID uuid.UUID, key *string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET avatar_key = $1 WHERE id = $2", key, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) GetPreferredGames(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	var gameIDs []uuid.UUID
+	err := r.db.SelectContext(ctx, &gameIDs,
+		"SELECT game_id FROM user_preferred_games WHERE user_id = $1 ORDER BY position",
+		userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return gameIDs, nil
+}
+
+func (r *repository) SetPreferredGames(ctx context.Context, userID uuid.UUID, gameIDs []uuid.UUID) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_preferred_games WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to clear preferred games: %w", err)
+	}
+
+	for i, gameID := range gameIDs {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO user_preferred_games (user_id, game_id, position) VALUES ($1, $2, $3)",
+			userID, gameID, i)
+		if err != nil {
+			return fmt.Errorf("failed to add preferred game: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
 func (r *repository) UpdateLastLogin(ctx context.Context, userID uuid.UUID) error {
 	_, err := r.db.ExecContext(ctx, "UPDATE users SET last_login = CURRENT_TIMESTAMP WHERE id = $1", userID)
 	if err != nil {
@@ -114,3 +298,12 @@ func (r *repository) UpdateLastLogin(ctx context.Context, userID uuid.UUID) erro
 
 	return nil
 }
+
+func (r *repository) SetEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = $1 AND email_verified_at IS NULL", userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
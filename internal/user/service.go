@@ -2,32 +2,74 @@ package user
 
 import (
 	"context"
+	"core/internal/password"
+	"core/internal/storage"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type Service interface {
 	GetUser(ctx context.Context, id uuid.UUID) (*User, error)
 	GetUserByEmail(ctx context.Context, email string) (exists bool, user *User, err error)
-	CreateUser(ctx context.Context, email, name, hash string) (uuid.UUID, error)
-	DeleteUser(ctx context.Context, id uuid.UUID) error
+	GetUsers(ctx context.Context, ids []uuid.UUID) ([]User, error)
+	// CreateUser hashes the password. Without a password the account can only
+	// be logged into through a linked provider until a password is set.
+	CreateUser(ctx context.Context, email, name, password string) (uuid.UUID, error)
+	// ScheduleDeletion anonymizes the user once the grace period is over and
+	// returns when that will be.
+	ScheduleDeletion(ctx context.Context, userID uuid.UUID) (time.Time, error)
+	// CancelDeletion returns ErrDeletionNotScheduled unless a deletion is pending.
+	CancelDeletion(ctx context.Context, userID uuid.UUID) error
+	// PurgeDeletedUsers anonymizes the users whose grace period is over and
+	// returns their IDs.
+	PurgeDeletedUsers(ctx context.Context) ([]uuid.UUID, error)
 	UpdateUser(ctx context.Context, id uuid.UUID, email, name string) error
+	// VerifyPassword checks the user's password, replacing its hash if it was
+	// created under an outdated hashing policy.
+	VerifyPassword(ctx context.Context, user *User, password string) (bool, error)
+	// UpdatePassword returns ErrWrongPassword if the old password does not match.
 	UpdatePassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) error
+	// SetPassword replaces the password without knowing the old one, e.g. after a reset.
+	SetPassword(ctx context.Context, userID uuid.UUID, password string) error
 	UpdateLastLogin(ctx context.Context, userID uuid.UUID) error
+	SetEmailVerified(ctx context.Context, userID uuid.UUID) error
+	// UpdateProfile saves the display name, bio and visibility settings.
+	UpdateProfile(ctx context.Context, user *User) error
+	// GetPreferredGames returns the game IDs in the user's order.
+	GetPreferredGames(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	SetPreferredGames(ctx context.Context, userID uuid.UUID, gameIDs []uuid.UUID) error
+	// SetAvatar replaces the avatar, returning ErrInvalidAvatar unless data is
+	// a supported image of at most MaxAvatarSize bytes.
+	SetAvatar(ctx context.Context, userID uuid.UUID, data []byte) error
+	// GetAvatar returns the avatar and its content type, or ErrNotFound.
+	GetAvatar(ctx context.Context, user *User) ([]byte, string, error)
+	DeleteAvatar(ctx context.Context, userID uuid.UUID) error
 }
 
+// defaultDeletionGracePeriod is how long users have to change their mind
+// about deleting their account.
+const defaultDeletionGracePeriod = 30 * 24 * time.Hour
+
 type service struct {
-	repo   Repository
-	pepper string
+	repo                Repository
+	hasher              password.Hasher
+	blobs               storage.Storage
+	deletionGracePeriod time.Duration
 }
 
-func NewService(repo Repository, pepper string) Service {
+func NewService(repo Repository, hasher password.Hasher, blobs storage.Storage, deletionGracePeriod time.Duration) Service {
+	if deletionGracePeriod <= 0 {
+		deletionGracePeriod = defaultDeletionGracePeriod
+	}
+
 	return &service{
-		repo:   repo,
-		pepper: pepper,
+		repo:                repo,
+		hasher:              hasher,
+		blobs:               blobs,
+		deletionGracePeriod: deletionGracePeriod,
 	}
 }
 
@@ -40,7 +82,16 @@ func (s *service) GetUser(ctx context.Context, id uuid.UUID) (*User, error) {
 	return user, nil
 }
 
-func (s *service) CreateUser(ctx context.Context, email, name, hash string) (uuid.UUID, error) {
+func (s *service) CreateUser(ctx context.Context, email, name, password string) (uuid.UUID, error) {
+	var hash string
+	if password != "" {
+		var err error
+		hash, err = s.hasher.Hash(password)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
 	user := &User{
 		Email: email,
 		Name:  name,
@@ -68,14 +119,49 @@ func (s *service) GetUserByEmail(ctx context.Context, email string) (bool, *User
 	return true, user, nil
 }
 
-func (s *service) DeleteUser(ctx context.Context, id uuid.UUID) error {
-	if err := s.repo.DeleteUser(ctx, id); err != nil {
-		return fmt.Errorf("failed to delete user with id %s: %w", id, err)
+func (s *service) ScheduleDeletion(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	at := time.Now().Add(s.deletionGracePeriod)
+	if err := s.repo.ScheduleDeletion(ctx, userID, at); err != nil {
+		return time.Time{}, fmt.Errorf("failed to schedule deletion of user with id %s: %w", userID, err)
+	}
+
+	return at, nil
+}
+
+func (s *service) CancelDeletion(ctx context.Context, userID uuid.UUID) error {
+	if err := s.repo.CancelDeletion(ctx, userID); err != nil {
+		if errors.Is(err, ErrDeletionNotScheduled) {
+			return ErrDeletionNotScheduled
+		}
+		return fmt.Errorf("failed to cancel deletion of user with id %s: %w", userID, err)
 	}
 
 	return nil
 }
 
+func (s *service) PurgeDeletedUsers(ctx context.Context) ([]uuid.UUID, error) {
+	userIDs, err := s.repo.GetUsersDueForDeletion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users due for deletion: %w", err)
+	}
+
+	for i, userID := range userIDs {
+		user, err := s.repo.GetUser(ctx, userID)
+		if err != nil {
+			return userIDs[:i], fmt.Errorf("failed to get user with id %s: %w", userID, err)
+		}
+		if err := s.deleteAvatarBlob(ctx, user.AvatarKey); err != nil {
+			return userIDs[:i], err
+		}
+
+		if err := s.repo.AnonymizeUser(ctx, userID); err != nil {
+			return userIDs[:i], fmt.Errorf("failed to anonymize user with id %s: %w", userID, err)
+		}
+	}
+
+	return userIDs, nil
+}
+
 func (s *service) UpdateUser(ctx context.Context, id uuid.UUID, email, name string) error {
 	user := &User{
 		ID:    id,
@@ -96,16 +182,42 @@ func (s *service) UpdatePassword(ctx context.Context, userID uuid.UUID, oldPassw
 		return fmt.Errorf("failed to get user with id %s: %w", userID, err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(u.Hash), []byte(oldPassword+s.pepper)); err != nil {
-		return fmt.Errorf("failed to compare password: %w", err)
+	match, _, err := s.hasher.Verify(oldPassword, u.Hash)
+	if err != nil {
+		return fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !match {
+		return ErrWrongPassword
+	}
+
+	return s.SetPassword(ctx, userID, newPassword)
+}
+
+func (s *service) VerifyPassword(ctx context.Context, user *User, password string) (bool, error) {
+	match, rehash, err := s.hasher.Verify(password, user.Hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !match {
+		return false, nil
+	}
+
+	if rehash {
+		if err := s.SetPassword(ctx, user.ID, password); err != nil {
+			return false, fmt.Errorf("failed to rehash password: %w", err)
+		}
 	}
 
-	hashedPasswordBytes, err := bcrypt.GenerateFromPassword([]byte(newPassword+s.pepper), bcrypt.DefaultCost)
+	return true, nil
+}
+
+func (s *service) SetPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	hash, err := s.hasher.Hash(password)
 	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
+		return err
 	}
 
-	if err := s.repo.UpdatePassword(ctx, userID, string(hashedPasswordBytes)); err != nil {
+	if err := s.repo.UpdatePassword(ctx, userID, hash); err != nil {
 		return fmt.Errorf("failed to update password: %w", err)
 	}
 
@@ -119,3 +231,11 @@ func (s *service) UpdateLastLogin(ctx context.Context, userID uuid.UUID) error {
 
 	return nil
 }
+
+func (s *service) SetEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	if err := s.repo.SetEmailVerified(ctx, userID); err != nil {
+		return fmt.Errorf("failed to set email verified for user with id %s: %w", userID, err)
+	}
+
+	return nil
+}
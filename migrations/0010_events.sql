@@ -0,0 +1,48 @@
+-- +goose up
+CREATE TABLE IF NOT EXISTS events (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    club_id UUID NOT NULL REFERENCES clubs(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS event_attendances (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    member_id UUID NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+    checked_in_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (event_id, member_id)
+);
+
+CREATE TABLE IF NOT EXISTS attendance_statistics (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    member_id UUID NOT NULL UNIQUE REFERENCES members(id) ON DELETE CASCADE,
+    sessions INT NOT NULL DEFAULT 0,
+    streak INT NOT NULL DEFAULT 0,
+    longest_streak INT NOT NULL DEFAULT 0,
+    last_event_id UUID REFERENCES events(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_club_id ON events(club_id);
+CREATE INDEX IF NOT EXISTS idx_events_starts_at ON events(starts_at);
+CREATE INDEX IF NOT EXISTS idx_event_attendances_event_id ON event_attendances(event_id);
+CREATE INDEX IF NOT EXISTS idx_event_attendances_member_id ON event_attendances(member_id);
+
+CREATE TRIGGER update_attendance_statistics_updated_at
+    BEFORE UPDATE ON attendance_statistics
+    FOR EACH ROW
+    EXECUTE FUNCTION update_modified_column();
+
+-- +goose down
+DROP TRIGGER IF EXISTS update_attendance_statistics_updated_at ON attendance_statistics;
+DROP INDEX IF EXISTS idx_event_attendances_member_id;
+DROP INDEX IF EXISTS idx_event_attendances_event_id;
+DROP INDEX IF EXISTS idx_events_starts_at;
+DROP INDEX IF EXISTS idx_events_club_id;
+
+DROP TABLE IF EXISTS attendance_statistics;
+DROP TABLE IF EXISTS event_attendances;
+DROP TABLE IF EXISTS events;
@@ -0,0 +1,25 @@
+-- +goose up
+ALTER TABLE club_invites ADD COLUMN IF NOT EXISTS role role DEFAULT 'member';
+
+CREATE TABLE IF NOT EXISTS club_invite_links (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    club_id UUID NOT NULL REFERENCES clubs(id) ON DELETE CASCADE,
+    code TEXT NOT NULL UNIQUE,
+    role role DEFAULT 'member',
+    max_uses INT,
+    uses INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_club_invite_links_club_id ON club_invite_links(club_id);
+CREATE INDEX IF NOT EXISTS idx_club_invite_links_code ON club_invite_links(code);
+
+-- +goose down
+DROP INDEX IF EXISTS idx_club_invite_links_code;
+DROP INDEX IF EXISTS idx_club_invite_links_club_id;
+
+DROP TABLE IF EXISTS club_invite_links;
+
+ALTER TABLE club_invites DROP COLUMN IF EXISTS role;
@@ -0,0 +1,15 @@
+-- +goose up
+CREATE TYPE join_policy AS ENUM ('open', 'approval', 'invite');
+
+ALTER TABLE clubs ADD COLUMN IF NOT EXISTS join_policy join_policy NOT NULL DEFAULT 'invite';
+ALTER TABLE clubs ADD COLUMN IF NOT EXISTS location TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_clubs_join_policy ON clubs(join_policy);
+
+-- +goose down
+DROP INDEX IF EXISTS idx_clubs_join_policy;
+
+ALTER TABLE clubs DROP COLUMN IF EXISTS location;
+ALTER TABLE clubs DROP COLUMN IF EXISTS join_policy;
+
+DROP TYPE IF EXISTS join_policy;
@@ -0,0 +1,17 @@
+-- +goose up
+CREATE TABLE IF NOT EXISTS club_role_permissions (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    club_id UUID NOT NULL REFERENCES clubs(id) ON DELETE CASCADE,
+    role role NOT NULL,
+    permission TEXT NOT NULL,
+    granted BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (club_id, role, permission)
+);
+
+CREATE INDEX IF NOT EXISTS idx_club_role_permissions_club_id ON club_role_permissions(club_id);
+
+-- +goose down
+DROP INDEX IF EXISTS idx_club_role_permissions_club_id;
+
+DROP TABLE IF EXISTS club_role_permissions;
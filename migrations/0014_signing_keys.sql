@@ -0,0 +1,16 @@
+-- +goose up
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    algorithm TEXT NOT NULL,
+    private_key BYTEA NOT NULL,
+    public_key BYTEA NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_signing_keys_expires_at ON signing_keys(expires_at);
+
+-- +goose down
+DROP INDEX IF EXISTS idx_signing_keys_expires_at;
+
+DROP TABLE IF EXISTS signing_keys;
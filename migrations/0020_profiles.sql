@@ -0,0 +1,31 @@
+-- +goose up
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bio TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_key TEXT;
+-- Who may see the profile and the statistics on it: public, clubs (users sharing a club) or private
+ALTER TABLE users ADD COLUMN IF NOT EXISTS profile_visibility TEXT NOT NULL DEFAULT 'clubs'
+    CHECK (profile_visibility IN ('public', 'clubs', 'private'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS stats_visibility TEXT NOT NULL DEFAULT 'clubs'
+    CHECK (stats_visibility IN ('public', 'clubs', 'private'));
+
+CREATE TABLE IF NOT EXISTS user_preferred_games (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, game_id)
+);
+
+-- Nicknames override the user's display name within one club
+ALTER TABLE members ADD COLUMN IF NOT EXISTS nickname TEXT;
+
+-- +goose down
+ALTER TABLE members DROP COLUMN IF EXISTS nickname;
+
+DROP TABLE IF EXISTS user_preferred_games;
+
+ALTER TABLE users DROP COLUMN IF EXISTS stats_visibility;
+ALTER TABLE users DROP COLUMN IF EXISTS profile_visibility;
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_key;
+ALTER TABLE users DROP COLUMN IF EXISTS bio;
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
@@ -0,0 +1,10 @@
+-- +goose up
+-- Guests are club members without an account, known only by their nickname
+ALTER TABLE members ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE members ADD CONSTRAINT members_guest_nickname CHECK (user_id IS NOT NULL OR nickname IS NOT NULL);
+
+-- +goose down
+DELETE FROM members WHERE user_id IS NULL;
+
+ALTER TABLE members DROP CONSTRAINT IF EXISTS members_guest_nickname;
+ALTER TABLE members ALTER COLUMN user_id SET NOT NULL;
@@ -0,0 +1,17 @@
+-- +goose up
+CREATE TABLE IF NOT EXISTS audit_log (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    club_id UUID NOT NULL REFERENCES clubs(id) ON DELETE CASCADE,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    target_id UUID,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_club_id_created_at ON audit_log(club_id, created_at);
+
+-- +goose down
+DROP INDEX IF EXISTS idx_audit_log_club_id_created_at;
+
+DROP TABLE IF EXISTS audit_log;
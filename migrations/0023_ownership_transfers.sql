@@ -0,0 +1,13 @@
+-- +goose up
+-- A club has at most one pending transfer, which the receiving member accepts
+CREATE TABLE IF NOT EXISTS ownership_transfers (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    club_id UUID NOT NULL UNIQUE REFERENCES clubs(id) ON DELETE CASCADE,
+    from_member_id UUID NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+    to_member_id UUID NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose down
+DROP TABLE IF EXISTS ownership_transfers;
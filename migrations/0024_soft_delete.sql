@@ -0,0 +1,14 @@
+-- +goose up
+-- Deleted clubs and games are kept for a retention period so they can be restored
+ALTER TABLE clubs ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE games ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_clubs_deleted_at ON clubs(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_games_deleted_at ON games(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose down
+DROP INDEX IF EXISTS idx_games_deleted_at;
+DROP INDEX IF EXISTS idx_clubs_deleted_at;
+
+ALTER TABLE games DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE clubs DROP COLUMN IF EXISTS deleted_at;
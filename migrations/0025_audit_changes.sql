@@ -0,0 +1,30 @@
+-- +goose up
+-- Entries record the state of their target before and after the action
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS before JSONB;
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS after JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_club_id_action ON audit_log(club_id, action);
+
+-- Entries can't be changed or removed, except by cascades from deleted clubs and users
+CREATE OR REPLACE FUNCTION prevent_audit_log_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF pg_trigger_depth() < 2 THEN
+        RAISE EXCEPTION 'audit log is append-only';
+    END IF;
+    RETURN COALESCE(NEW, OLD);
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION prevent_audit_log_changes();
+
+-- +goose down
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+DROP FUNCTION IF EXISTS prevent_audit_log_changes();
+
+DROP INDEX IF EXISTS idx_audit_log_club_id_action;
+
+ALTER TABLE audit_log DROP COLUMN IF EXISTS after;
+ALTER TABLE audit_log DROP COLUMN IF EXISTS before;
@@ -0,0 +1,27 @@
+-- +goose up
+-- Win streaks remember their best, personal bests are measured against it
+ALTER TABLE statistics ADD COLUMN IF NOT EXISTS longest_streak INT NOT NULL DEFAULT 0;
+UPDATE statistics SET longest_streak = GREATEST(streak, 0);
+
+-- Activities make up the club and user feeds, newest first by their time ordered IDs
+CREATE TABLE IF NOT EXISTS activities (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    club_id UUID NOT NULL REFERENCES clubs(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    member_id UUID REFERENCES members(id) ON DELETE CASCADE,
+    game_id UUID REFERENCES games(id) ON DELETE CASCADE,
+    subject_id UUID,
+    data JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_activities_club_id_id ON activities(club_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_activities_member_id ON activities(member_id);
+
+-- +goose down
+DROP INDEX IF EXISTS idx_activities_member_id;
+DROP INDEX IF EXISTS idx_activities_club_id_id;
+
+DROP TABLE IF EXISTS activities;
+
+ALTER TABLE statistics DROP COLUMN IF EXISTS longest_streak;
@@ -0,0 +1,7 @@
+-- +goose up
+-- Personal access tokens created from a session that passed a second factor
+-- satisfy clubs requiring it
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS two_factor BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose down
+ALTER TABLE api_keys DROP COLUMN IF EXISTS two_factor;